@@ -1,71 +1,417 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"github.com/Waelson/lock-manager-service/lock-manager-api/internal/cache"
+	"github.com/Waelson/lock-manager-service/lock-manager-api/internal/config"
 	"github.com/Waelson/lock-manager-service/lock-manager-api/internal/handler"
 	"github.com/Waelson/lock-manager-service/lock-manager-api/internal/locker"
+	"github.com/Waelson/lock-manager-service/lock-manager-api/internal/metrics"
+	auth "github.com/Waelson/lock-manager-service/lock-manager-api/internal/middleware"
+	"github.com/Waelson/lock-manager-service/lock-manager-api/internal/ratelimit"
+	"github.com/Waelson/lock-manager-service/lock-manager-api/internal/rpc"
+	"github.com/Waelson/lock-manager-service/lock-manager-api/pkg/lockpb"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"sync"
+	"syscall"
 	"text/tabwriter"
+	"time"
 )
 
 func main() {
-	redisAddresses := strings.TrimSpace(os.Getenv("REDIS_ADDRESSES"))
+	cfg := config.Load()
+	if err := cfg.CheckTLS(); err != nil {
+		panic(err)
+	}
+	if err := cfg.CheckGRPC(); err != nil {
+		panic(err)
+	}
+
+	// Cancelled on SIGINT/SIGTERM, starting the graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
 	// Initiate Redis clients
-	redisNodes, err := CreateRedisClients(redisAddresses)
+	redisNodes, err := NewRedisNodes(cfg)
 	if err != nil {
 		panic(err)
 	}
 
+	// Leveled logger for the lock operations
+	logLevel, err := locker.ParseLogLevel(cfg.LogLevel)
+	if err != nil {
+		panic(err)
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: logLevel}))
+
 	// Initiate locker
-	redisLocker := locker.NewLocker(redisNodes)
+	lockerOpts := []locker.Option{
+		locker.WithNodeTimeout(cfg.NodeTimeout),
+		locker.WithLogger(logger),
+		locker.WithRetry(cfg.AcquireRetryCount, cfg.AcquireRetryDelay),
+		locker.WithMaxResourceLength(cfg.MaxResourceLength),
+		locker.WithNodeWeights(cfg.RedisNodeWeights...),
+	}
+	if cfg.VerifiedAcquire {
+		lockerOpts = append(lockerOpts, locker.WithVerifiedAcquire())
+	}
+	canonicalize, err := locker.NewCanonicalizer(cfg.Canonicalization)
+	if err != nil {
+		panic(err)
+	}
+	if canonicalize != nil {
+		lockerOpts = append(lockerOpts, locker.WithCanonicalizer(canonicalize))
+	}
+	if cfg.Namespace != "" {
+		lockerOpts = append(lockerOpts, locker.WithNamespace(cfg.Namespace))
+	}
+	// Maps a resource to the key actually stored, for the components reading lock keys on their own
+	keyOf := locker.NamespacedCanonicalizer(cfg.Namespace, canonicalize)
+	redisLocker, err := locker.NewLocker(redisNodes, lockerOpts...)
+	if err != nil {
+		panic(err)
+	}
+	// The stores kept beside the locks vote with the same weights, node timeout and logger as the locker
+	nodeSet, err := locker.NewNodeSet(redisNodes, lockerOpts...)
+	if err != nil {
+		panic(err)
+	}
 
-	lockHandler := handler.NewLockHandler(redisLocker)
+	// Refuse to start while the nodes up can't form a quorum, instead of accepting traffic it can't serve
+	if cfg.StartupCheckTimeout > 0 {
+		if err := WaitForRedisNodes(ctx, redisNodes, cfg.RedisNodeWeights, cfg.StartupCheckTimeout); err != nil {
+			panic(err)
+		}
+	}
+
+	clampTTL, err := handler.ParseMaxTTLPolicy(cfg.MaxTTLPolicy)
+	if err != nil {
+		panic(err)
+	}
+
+	handlerOpts := []handler.Option{
+		handler.WithMinTTL(cfg.MinTTL),
+		handler.WithMaxTTL(cfg.MaxTTL, clampTTL),
+		handler.WithMaxResourceLength(cfg.MaxResourceLength),
+		handler.WithCanonicalizer(canonicalize),
+	}
+
+	// Subscribe to keyspace notifications so waiters and /events streams learn immediately when a lock disappears
+	if cfg.KeyspaceNotifications {
+		notifier := locker.NewReleaseNotifier(nodeSet, keyOf)
+		if err := notifier.Start(ctx); err != nil {
+			panic(fmt.Sprintf("Error subscribing to keyspace notifications: %v", err))
+		}
+		handlerOpts = append(handlerOpts, handler.WithReleaseNotifier(notifier))
+	}
+
+	// Cache recent acquisitions so retries carrying the same idempotency key skip Redis
+	if cfg.IdempotencyCacheSize > 0 {
+		idempotencyCache := cache.NewLRU[handler.AcquireLockResponse](cfg.IdempotencyCacheSize, cfg.IdempotencyCacheTTL)
+		idempotencyIndex := cache.NewLRU[string](cfg.IdempotencyCacheSize, cfg.IdempotencyCacheTTL)
+		handlerOpts = append(handlerOpts, handler.WithIdempotencyCache(idempotencyCache, idempotencyIndex))
+	}
+
+	// Require a nonce on release/refresh requests and reject replays of one already used
+	if cfg.RequireNonce {
+		nonceStore := locker.NewNonceStore(nodeSet)
+		handlerOpts = append(handlerOpts, handler.WithNonceStore(nonceStore, cfg.NonceTTL))
+	}
+
+	// Let fair=true acquisitions wait their turn in a per-resource queue
+	handlerOpts = append(handlerOpts, handler.WithFairQueue(locker.NewFairQueue(nodeSet, keyOf)))
+
+	// Throttle the acquire attempts on each resource
+	if cfg.AcquireRateLimit > 0 {
+		limiter := ratelimit.NewKeyedLimiter(cfg.AcquireRateLimit, cfg.AcquireRateBurst)
+		handlerOpts = append(handlerOpts, handler.WithAcquireRateLimit(limiter))
+	}
+
+	// Cap how many locks each client may hold at once
+	var quotaStore locker.QuotaStore
+	if cfg.MaxLocksPerOwner > 0 {
+		quotaStore = locker.NewQuotaStore(nodeSet, cfg.MaxLocksPerOwner)
+		handlerOpts = append(handlerOpts, handler.WithQuotaStore(quotaStore))
+	}
+
+	// Sample acquired resources and look for nodes holding different tokens for them
+	var consistencyChecker locker.ConsistencyChecker
+	if cfg.SplitBrainSampleRate > 0 {
+		consistencyChecker = locker.NewConsistencyChecker(nodeSet, keyOf, cfg.SplitBrainSampleRate, cfg.SplitBrainInterval)
+		consistencyChecker.Start(ctx)
+		handlerOpts = append(handlerOpts, handler.WithConsistencyChecker(consistencyChecker))
+	}
+
+	drainableLocker := locker.NewDrainableLocker(redisLocker)
+	lockHandler := handler.NewLockHandler(drainableLocker, handlerOpts...)
+	drainHandler := handler.NewDrainHandler(drainableLocker)
+
+	// Initiate in-process latency tracker
+	latency := metrics.NewLatencyTracker(cfg.LatencyWindow)
+	statsHandler := handler.NewStatsHandler(latency, quotaStore, consistencyChecker)
+
+	configHandler := handler.NewConfigHandler(cfg)
+	healthHandler := handler.NewHealthHandler(redisNodes, cfg.RedisNodeWeights)
+
+	// Lock-specific access log, distinct from the generic HTTP log
+	var accessLog auth.AccessLogger
+	if cfg.AccessLog {
+		accessLog, err = auth.NewAccessLogger(os.Stdout, cfg.AccessLogFormat)
+		if err != nil {
+			panic(err)
+		}
+	}
 
 	// Set router
 	r := chi.NewRouter()
 	r.Use(middleware.Logger)
 
+	// Per-operation middlewares: tracing, latency tracking, the access log when enabled and, when API keys
+	// are configured, their check. Spans go to the global OpenTelemetry provider, a no-op until an
+	// exporter registers one.
+	tracerProvider := otel.GetTracerProvider()
+	instrument := func(operation string) chi.Router {
+		middlewares := []func(http.Handler) http.Handler{
+			auth.Tracing(tracerProvider, operation),
+			latency.Middleware(operation),
+		}
+		if accessLog != nil {
+			middlewares = append(middlewares, accessLog.Middleware(operation))
+		}
+		middlewares = append(middlewares, auth.RequireAPIKey(cfg.APIKeys...))
+		return r.With(middlewares...)
+	}
+
+	// Endpoints receiving a lock token refuse plaintext requests when TLS is required
+	var tokenBearing []func(http.Handler) http.Handler
+	if cfg.RequireTLS {
+		tokenBearing = append(tokenBearing, auth.RequireTLS(cfg.TrustForwardedProto))
+	}
+
 	// Endpoints
-	r.Post("/lock", lockHandler.AcquireLockHandler)
-	r.Post("/unlock", lockHandler.ReleaseLockHandler)
-	r.Post("/refresh", lockHandler.RefreshLockHandler)
-	r.Get("/ttl", lockHandler.TTLHandler)
+	instrument("acquire").Post("/lock", lockHandler.AcquireLockHandler)
+	instrument("acquire").Post("/lock/exclusive", lockHandler.AcquireLockHandler)
+	instrument("acquire_shared").Post("/lock/shared", lockHandler.AcquireSharedHandler)
+	instrument("release").With(tokenBearing...).Post("/unlock", lockHandler.ReleaseLockHandler)
+	instrument("release_by_token").With(tokenBearing...).Post("/unlock-all", lockHandler.ReleaseByTokenHandler)
+	instrument("refresh").With(tokenBearing...).Post("/refresh", lockHandler.RefreshLockHandler)
+	instrument("ttl").With(tokenBearing...).Get("/ttl", lockHandler.TTLHandler)
+	instrument("ttl_batch").With(tokenBearing...).Post("/ttl/batch", lockHandler.TTLBatchHandler)
+	instrument("acquire_any").Post("/lock/any", lockHandler.AcquireAnyHandler)
+	instrument("acquire_batch").Post("/lock/batch", lockHandler.AcquireBatchHandler)
+	instrument("validate_fence").Get("/fence/validate", lockHandler.ValidateFenceHandler)
+	instrument("events").Get("/events", lockHandler.EventsHandler)
+	r.Get("/stats", statsHandler.SummaryHandler)
+	r.Get("/stats/latency", statsHandler.LatencyHandler)
+
+	// Probes for orchestrators
+	r.Get("/health/live", healthHandler.LiveHandler)
+	r.Get("/health/ready", healthHandler.ReadyHandler)
+
+	// Quorum gauges for Prometheus, refreshed in the background regardless of the lock traffic
+	if cfg.QuorumProbeInterval > 0 {
+		prober := locker.NewQuorumProber(redisNodes, cfg.RedisNodeWeights, cfg.QuorumProbeInterval)
+		prober.Start(ctx)
+		r.Get("/metrics", handler.NewMetricsHandler(prober).MetricsHandler)
+	}
+
+	// Admin endpoints
+	r.With(auth.RequireToken(cfg.AdminToken)).Get("/config", configHandler.EffectiveConfigHandler)
+	r.With(auth.RequireToken(cfg.AdminToken)).Get("/locks", lockHandler.ListLocksHandler)
+	r.With(auth.RequireToken(cfg.AdminToken)).Post("/admin/drain", drainHandler.DrainHandler)
+	r.With(auth.RequireToken(cfg.AdminToken)).Post("/admin/undrain", drainHandler.UndrainHandler)
 
 	// Print Redis and endpoint details
 	PrintServerDetails(redisNodes)
 
-	// Start web server
-	fmt.Println("\nServer started at http://localhost:8181")
-	if err := http.ListenAndServe(":8181", r); err != nil {
-		panic(fmt.Sprintf("Error starting server: %v", err))
+	// Start web server. Binding before serving reports the actual port when SERVER_PORT is 0.
+	listener, err := net.Listen("tcp", cfg.ListenAddress())
+	if err != nil {
+		panic(fmt.Sprintf("Error listening on %s: %v", cfg.ListenAddress(), err))
+	}
+	server := &http.Server{Handler: r}
+	go func() {
+		var err error
+		if cfg.ServesTLS() {
+			fmt.Printf("\nServer started at https://%s\n", listener.Addr())
+			err = server.ServeTLS(listener, cfg.TLSCertFile, cfg.TLSKeyFile)
+		} else {
+			fmt.Printf("\nServer started at http://%s\n", listener.Addr())
+			err = server.Serve(listener)
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			panic(fmt.Sprintf("Error starting server: %v", err))
+		}
+	}()
+
+	// Serve the same lock operations over gRPC on a separate port
+	var grpcServer *grpc.Server
+	if address := cfg.GRPCListenAddress(); address != "" {
+		grpcListener, err := net.Listen("tcp", address)
+		if err != nil {
+			panic(fmt.Sprintf("Error listening on %s: %v", address, err))
+		}
+		grpcOpts := []grpc.ServerOption{grpc.UnaryInterceptor(rpc.RequireAPIKey(cfg.APIKeys...))}
+		if cfg.ServesTLS() {
+			creds, err := credentials.NewServerTLSFromFile(cfg.TLSCertFile, cfg.TLSKeyFile)
+			if err != nil {
+				panic(fmt.Sprintf("Error loading the gRPC TLS certificate: %v", err))
+			}
+			grpcOpts = append(grpcOpts, grpc.Creds(creds))
+		}
+		grpcServer = grpc.NewServer(grpcOpts...)
+		lockpb.RegisterLockServiceServer(grpcServer, rpc.NewLockServer(drainableLocker, cfg.MinTTL, cfg.MaxTTL, clampTTL))
+		go func() {
+			fmt.Printf("gRPC server started at %s\n", grpcListener.Addr())
+			if err := grpcServer.Serve(grpcListener); err != nil {
+				panic(fmt.Sprintf("Error starting gRPC server: %v", err))
+			}
+		}()
 	}
+
+	<-ctx.Done()
+	stop()
+	shutdown(server, grpcServer, redisLocker, cfg.ShutdownTimeout)
 }
 
-// CreateRedisClients creates Redis clients from a comma-separated string of addresses
-func CreateRedisClients(addresses string) ([]*redis.Client, error) {
+// shutdown stops accepting connections and lets in-flight lock operations finish within timeout, then
+// closes the Redis connections
+func shutdown(server *http.Server, grpcServer *grpc.Server, redlock locker.RedLocker, timeout time.Duration) {
+	log.Printf("shutting down, waiting up to %s for in-flight requests\n", timeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	grpcStopped := make(chan struct{})
+	go func() {
+		if grpcServer != nil {
+			grpcServer.GracefulStop()
+		}
+		close(grpcStopped)
+	}()
+
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("error shutting down server: %v\n", err)
+	}
+
+	// GracefulStop has no deadline of its own, so cut the remaining gRPC calls once the timeout is over
+	select {
+	case <-grpcStopped:
+	case <-ctx.Done():
+		if grpcServer != nil {
+			grpcServer.Stop()
+		}
+		<-grpcStopped
+	}
+
+	if err := redlock.Close(); err != nil {
+		log.Printf("error closing Redis connections: %v\n", err)
+	}
+}
+
+// NewRedisNodes creates the clients of the Redlock nodes in the configured REDIS_MODE. Cluster mode is
+// refused: a Redis Cluster shards one keyspace, so each lock lives on a single master and Redlock's
+// majority of independent masters can't be formed from it.
+func NewRedisNodes(cfg config.Config) ([]*redis.Client, error) {
+	switch cfg.RedisMode {
+	case config.RedisModeStandalone:
+		return CreateRedisClients(cfg.RedisAddresses, cfg.RedisUsername, cfg.RedisPassword)
+	case config.RedisModeSentinel:
+		return CreateSentinelClients(cfg.RedisAddresses, cfg.SentinelMasters, cfg.RedisUsername, cfg.RedisPassword)
+	case config.RedisModeCluster:
+		return nil, errors.New("REDIS_MODE=cluster is not supported: Redlock needs independent masters, list the masters of separate deployments in standalone mode instead")
+	default:
+		return nil, fmt.Errorf("unknown REDIS_MODE '%s', expected '%s' or '%s'", cfg.RedisMode, config.RedisModeStandalone, config.RedisModeSentinel)
+	}
+}
+
+// CreateSentinelClients creates one failover client per master name, each following its master through
+// the Sentinels at addresses, so a promoted replica takes over as that Redlock node. Credentials embedded
+// in the addresses authenticate to the Sentinels and must be the same on all of them; username and
+// password authenticate to the masters.
+func CreateSentinelClients(addresses string, masters []string, username string, password string) ([]*redis.Client, error) {
 	if strings.TrimSpace(addresses) == "" {
-		return nil, errors.New("input string of Redis addresses is empty")
+		return nil, errors.New("input string of Redis Sentinel addresses is empty")
+	}
+	if len(masters) == 0 {
+		return nil, errors.New("REDIS_SENTINEL_MASTERS is empty, list the master name of each Redlock node")
 	}
 
-	addrList := strings.Split(addresses, ",")
-	if len(addrList) <= 2 {
-		return nil, errors.New("number of Redis servers must be greater than 2")
+	sentinels := make([]string, 0)
+	var sentinelUsername, sentinelPassword string
+	for i, addr := range strings.Split(addresses, ",") {
+		host, nodeUsername, nodePassword := config.ParseRedisAddress(addr)
+		if host == "" {
+			return nil, fmt.Errorf("Redis Sentinel address #%d has no host", i+1) // Not echoed, it may hold a password
+		}
+		if i > 0 && (nodeUsername != sentinelUsername || nodePassword != sentinelPassword) {
+			return nil, errors.New("Redis Sentinel addresses carry different credentials")
+		}
+		sentinelUsername, sentinelPassword = nodeUsername, nodePassword
+		sentinels = append(sentinels, host)
+	}
+
+	seen := make(map[string]bool, len(masters))
+	clients := make([]*redis.Client, 0, len(masters))
+	for _, master := range masters {
+		if seen[master] {
+			return nil, fmt.Errorf("master '%s' listed twice in REDIS_SENTINEL_MASTERS", master)
+		}
+		seen[master] = true
+
+		client := redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       master,
+			SentinelAddrs:    sentinels,
+			SentinelUsername: sentinelUsername,
+			SentinelPassword: sentinelPassword,
+			Username:         username,
+			Password:         password,
+		})
+		// The Sentinel dialer ignores Addr, so it only names the node in logs, health checks and reports
+		client.Options().Addr = "sentinel/" + master
+		clients = append(clients, client)
 	}
-	if len(addrList)%2 == 0 {
-		return nil, errors.New("number of Redis servers must be odd")
+
+	return clients, nil
+}
+
+// CreateRedisClients creates Redis clients from a comma-separated string of addresses. Each address may
+// carry its own credentials as [[user]:password@]host:port; the others use username and password.
+func CreateRedisClients(addresses string, username string, password string) ([]*redis.Client, error) {
+	if strings.TrimSpace(addresses) == "" {
+		return nil, errors.New("input string of Redis addresses is empty")
 	}
 
+	addrList := strings.Split(addresses, ",")
+
 	clients := make([]*redis.Client, 0, len(addrList))
-	for _, addr := range addrList {
+	for i, addr := range addrList {
+		host, nodeUsername, nodePassword := config.ParseRedisAddress(addr)
+		if host == "" {
+			return nil, fmt.Errorf("Redis address #%d has no host", i+1) // Not echoed, it may hold a password
+		}
+		if nodeUsername == "" && nodePassword == "" {
+			nodeUsername, nodePassword = username, password
+		}
+
 		client := redis.NewClient(&redis.Options{
-			Addr: addr,
+			Addr:     host,
+			Username: nodeUsername,
+			Password: nodePassword,
 		})
 		clients = append(clients, client)
 	}
@@ -73,6 +419,72 @@ func CreateRedisClients(addresses string) ([]*redis.Client, error) {
 	return clients, nil
 }
 
+// WaitForRedisNodes pings the Redis nodes, retrying the unreachable ones with exponential backoff, until
+// the nodes up hold a quorum of votes or window elapses. Which nodes answered is logged either way.
+func WaitForRedisNodes(ctx context.Context, redisNodes []*redis.Client, weights []int, window time.Duration) error {
+	quorum := locker.WeightedQuorum(len(redisNodes), weights)
+	deadline := time.Now().Add(window)
+	backoff := 200 * time.Millisecond
+	up := make([]bool, len(redisNodes))
+	lastErrs := make([]error, len(redisNodes))
+
+	for {
+		var wg sync.WaitGroup
+		for i, node := range redisNodes {
+			if up[i] {
+				continue
+			}
+			wg.Add(1)
+			go func(i int, node *redis.Client) {
+				defer wg.Done()
+
+				pingCtx, cancel := context.WithTimeout(ctx, handler.HealthPingTimeout)
+				defer cancel()
+
+				lastErrs[i] = node.Ping(pingCtx).Err()
+				up[i] = lastErrs[i] == nil
+			}(i, node)
+		}
+		wg.Wait()
+
+		votes := 0
+		for i := range redisNodes {
+			if up[i] {
+				votes += nodeWeight(weights, i)
+			}
+		}
+
+		remaining := time.Until(deadline)
+		if votes >= quorum || remaining <= 0 || ctx.Err() != nil {
+			for i, node := range redisNodes {
+				if up[i] {
+					log.Printf("Redis node %s is reachable\n", node.Options().Addr)
+				} else {
+					log.Printf("Redis node %s is unreachable: %v\n", node.Options().Addr, lastErrs[i])
+				}
+			}
+			if votes < quorum {
+				return fmt.Errorf("only %d of the %d votes needed for quorum are reachable after %s", votes, quorum, window)
+			}
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(min(backoff, remaining)):
+		}
+		backoff = min(backoff*2, 5*time.Second)
+	}
+}
+
+// nodeWeight returns the votes of node i, one when no weights are configured
+func nodeWeight(weights []int, i int) int {
+	if i < len(weights) {
+		return weights[i]
+	}
+	return 1
+}
+
 // PrintServerDetails prints Redis servers and endpoints in a professional table format
 func PrintServerDetails(redisNodes []*redis.Client) {
 	fmt.Println("\n==========================")
@@ -99,8 +511,25 @@ func PrintServerDetails(redisNodes []*redis.Client) {
 	fmt.Fprintln(writer, "--------\t------")
 	fmt.Fprintln(writer, "/lock\tPOST")
 	fmt.Fprintln(writer, "/unlock\tPOST")
+	fmt.Fprintln(writer, "/unlock-all\tPOST")
 	fmt.Fprintln(writer, "/refresh\tPOST")
 	fmt.Fprintln(writer, "/ttl\tGET")
+	fmt.Fprintln(writer, "/ttl/batch\tPOST")
+	fmt.Fprintln(writer, "/lock/any\tPOST")
+	fmt.Fprintln(writer, "/lock/batch\tPOST")
+	fmt.Fprintln(writer, "/fence/validate\tGET")
+	fmt.Fprintln(writer, "/events\tGET")
+	fmt.Fprintln(writer, "/lock/exclusive\tPOST")
+	fmt.Fprintln(writer, "/lock/shared\tPOST")
+	fmt.Fprintln(writer, "/stats\tGET")
+	fmt.Fprintln(writer, "/stats/latency\tGET")
+	fmt.Fprintln(writer, "/config\tGET")
+	fmt.Fprintln(writer, "/locks\tGET")
+	fmt.Fprintln(writer, "/admin/drain\tPOST")
+	fmt.Fprintln(writer, "/admin/undrain\tPOST")
+	fmt.Fprintln(writer, "/health/live\tGET")
+	fmt.Fprintln(writer, "/health/ready\tGET")
+	fmt.Fprintln(writer, "/metrics\tGET")
 	writer.Flush()
 
 	fmt.Println("\n=========================")
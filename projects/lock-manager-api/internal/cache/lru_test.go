@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRUEvictsTheLeastRecentlyUsed(t *testing.T) {
+	c := NewLRU[int](2, time.Minute)
+	c.Add("a", 1, 0)
+	c.Add("b", 2, 0)
+
+	// Reading "a" leaves "b" as the least recently used
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Fatalf("Get(a) = %d, %t, want 1", v, ok)
+	}
+	c.Add("c", 3, 0)
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("b was kept past the capacity")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Error("a was evicted despite being used last")
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", c.Len())
+	}
+}
+
+func TestLRUAddReplacesTheValue(t *testing.T) {
+	c := NewLRU[string](2, time.Minute)
+	c.Add("a", "first", 0)
+	c.Add("a", "second", 0)
+
+	if v, _ := c.Get("a"); v != "second" {
+		t.Errorf("Get(a) = %q, want second", v)
+	}
+	if c.Len() != 1 {
+		t.Errorf("Len() = %d, want 1", c.Len())
+	}
+}
+
+func TestLRUEntriesExpire(t *testing.T) {
+	c := NewLRU[int](10, time.Minute)
+	c.Add("short", 1, 10*time.Millisecond)
+	c.Add("default", 2, 0)
+
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := c.Get("short"); ok {
+		t.Error("an entry was returned past its TTL")
+	}
+	if _, ok := c.Get("default"); !ok {
+		t.Error("an entry with the default TTL expired early")
+	}
+}
+
+func TestLRUCapsTheTTLToTheDefault(t *testing.T) {
+	c := NewLRU[int](10, 10*time.Millisecond)
+	c.Add("a", 1, time.Hour)
+
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := c.Get("a"); ok {
+		t.Error("an entry outlived the default TTL of the cache")
+	}
+}
+
+func TestLRURemove(t *testing.T) {
+	c := NewLRU[int](0, time.Minute)
+	c.Add("a", 1, 0)
+	c.Remove("a")
+	c.Remove("missing")
+
+	if _, ok := c.Get("a"); ok {
+		t.Error("a removed entry was returned")
+	}
+	if c.Len() != 0 {
+		t.Errorf("Len() = %d, want 0", c.Len())
+	}
+}
@@ -0,0 +1,269 @@
+package config
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Ways of reaching the Redis nodes, chosen by REDIS_MODE
+const (
+	RedisModeStandalone = "standalone" // Every address is an independent master
+	RedisModeSentinel   = "sentinel"   // Every master name is followed through the Sentinels at the addresses
+	RedisModeCluster    = "cluster"    // Refused: a cluster is a single keyspace, not independent masters
+)
+
+// Config holds the effective runtime configuration of the lock manager, loaded from environment variables
+type Config struct {
+	ServerAddr            string // Host or IP to listen on, empty for every interface
+	ServerPort            string // 0 picks a random free port
+	GRPCPort              string // Empty disables the gRPC server, 0 picks a random free port
+	RedisMode             string
+	RedisAddresses        string   // May embed per-node credentials as user:pass@host:port
+	SentinelMasters       []string // Master names followed through the Sentinels, each one a Redlock node
+	RedisUsername         string
+	RedisPassword         string // Secret: default password of every node without embedded credentials
+	NodeTimeout           time.Duration
+	StartupCheckTimeout   time.Duration // How long to wait at startup for a quorum of nodes to answer, 0 to skip the check
+	RedisNodeWeights      []int         // Votes of each node toward the quorum, in the order of RedisAddresses
+	MinTTL                time.Duration
+	MaxTTL                time.Duration // Longest TTL granted on acquire and refresh, 0 for no cap
+	MaxTTLPolicy          string        // "clamp" lowers a longer TTL to MaxTTL, "reject" answers 400
+	MaxResourceLength     int           // Longest resource name accepted, in bytes
+	AcquireRateLimit      float64       // Acquire attempts per second allowed on each resource, 0 for no limit
+	AcquireRateBurst      int
+	AcquireRetryCount     int
+	AcquireRetryDelay     time.Duration
+	KeyspaceNotifications bool
+	VerifiedAcquire       bool
+	Canonicalization      string
+	Namespace             string // Prefix isolating this instance's keys from other applications on the same nodes
+	IdempotencyCacheSize  int
+	IdempotencyCacheTTL   time.Duration
+	NonceTTL              time.Duration
+	RequireNonce          bool
+	MaxLocksPerOwner      int
+	LatencyWindow         int
+	AccessLog             bool
+	AccessLogFormat       string
+	RequireTLS            bool
+	TrustForwardedProto   bool
+	TLSCertFile           string // With TLSKeyFile, serves HTTP and gRPC over TLS
+	TLSKeyFile            string
+	SplitBrainSampleRate  float64
+	SplitBrainInterval    time.Duration
+	QuorumProbeInterval   time.Duration // How often the nodes are pinged for the /metrics gauges, 0 to disable
+	ShutdownTimeout       time.Duration
+	LogLevel              string
+	APIKeys               []string // Secret: keys accepted on the lock endpoints, none leaves them open
+	AdminToken            string   // Secret: guards the admin endpoints, never exposed
+}
+
+// Load reads the configuration from the environment, applying defaults for unset variables
+func Load() Config {
+	return Config{
+		ServerAddr:            getEnv("SERVER_ADDR", ""),
+		ServerPort:            getEnv("SERVER_PORT", "8181"),
+		GRPCPort:              getEnv("GRPC_PORT", ""),
+		RedisMode:             strings.ToLower(getEnv("REDIS_MODE", RedisModeStandalone)),
+		RedisAddresses:        strings.TrimSpace(os.Getenv("REDIS_ADDRESSES")),
+		SentinelMasters:       getEnvAsList("REDIS_SENTINEL_MASTERS"),
+		RedisUsername:         os.Getenv("REDIS_USERNAME"),
+		RedisPassword:         os.Getenv("REDIS_PASSWORD"),
+		NodeTimeout:           getEnvAsDuration("REDIS_NODE_TIMEOUT", 2*time.Second),
+		StartupCheckTimeout:   getEnvAsDuration("REDIS_STARTUP_CHECK_TIMEOUT", 0),
+		RedisNodeWeights:      getEnvAsIntList("REDIS_NODE_WEIGHTS"),
+		MinTTL:                getEnvAsDuration("MIN_TTL", 100*time.Millisecond),
+		MaxTTL:                getEnvAsDuration("MAX_TTL", 5*time.Minute),
+		MaxTTLPolicy:          getEnv("MAX_TTL_POLICY", "clamp"),
+		MaxResourceLength:     getEnvAsInt("MAX_RESOURCE_LENGTH", 512),
+		AcquireRateLimit:      getEnvAsFloat("ACQUIRE_RATE_LIMIT", 0),
+		AcquireRateBurst:      getEnvAsInt("ACQUIRE_RATE_BURST", 10),
+		AcquireRetryCount:     getEnvAsInt("ACQUIRE_RETRY_COUNT", 0),
+		AcquireRetryDelay:     getEnvAsDuration("ACQUIRE_RETRY_DELAY", 200*time.Millisecond),
+		KeyspaceNotifications: getEnvAsBool("REDIS_KEYSPACE_NOTIFICATIONS", false),
+		VerifiedAcquire:       getEnvAsBool("VERIFIED_ACQUIRE", false),
+		Canonicalization:      strings.TrimSpace(os.Getenv("RESOURCE_CANONICALIZATION")),
+		Namespace:             strings.TrimSpace(os.Getenv("LOCK_NAMESPACE")),
+		IdempotencyCacheSize:  getEnvAsInt("IDEMPOTENCY_CACHE_SIZE", 10000),
+		IdempotencyCacheTTL:   getEnvAsDuration("IDEMPOTENCY_CACHE_TTL", 30*time.Second),
+		NonceTTL:              getEnvAsDuration("NONCE_TTL", 10*time.Minute),
+		RequireNonce:          getEnvAsBool("REQUIRE_NONCE", false),
+		MaxLocksPerOwner:      getEnvAsInt("MAX_LOCKS_PER_OWNER", 0),
+		LatencyWindow:         getEnvAsInt("LATENCY_WINDOW", 1024),
+		AccessLog:             getEnvAsBool("ACCESS_LOG", false),
+		AccessLogFormat:       getEnv("ACCESS_LOG_FORMAT", "text"),
+		RequireTLS:            getEnvAsBool("REQUIRE_TLS", false),
+		TrustForwardedProto:   getEnvAsBool("TRUST_FORWARDED_PROTO", false),
+		TLSCertFile:           getEnv("TLS_CERT_FILE", ""),
+		TLSKeyFile:            getEnv("TLS_KEY_FILE", ""),
+		SplitBrainSampleRate:  getEnvAsFloat("SPLIT_BRAIN_SAMPLE_RATE", 0),
+		SplitBrainInterval:    getEnvAsDuration("SPLIT_BRAIN_CHECK_INTERVAL", 5*time.Second),
+		QuorumProbeInterval:   getEnvAsDuration("QUORUM_PROBE_INTERVAL", 5*time.Second),
+		ShutdownTimeout:       getEnvAsDuration("SHUTDOWN_TIMEOUT", 15*time.Second),
+		LogLevel:              getEnv("LOG_LEVEL", "info"),
+		APIKeys:               getEnvAsList("API_KEYS"),
+		AdminToken:            os.Getenv("ADMIN_TOKEN"),
+	}
+}
+
+// ListenAddress is the host:port the HTTP server binds to
+func (c Config) ListenAddress() string {
+	return net.JoinHostPort(c.ServerAddr, c.ServerPort)
+}
+
+// GRPCListenAddress is the host:port the gRPC server binds to, empty when it is disabled
+func (c Config) GRPCListenAddress() string {
+	if c.GRPCPort == "" {
+		return ""
+	}
+	return net.JoinHostPort(c.ServerAddr, c.GRPCPort)
+}
+
+// ServesTLS tells whether the service terminates TLS itself, with the configured certificate and key
+func (c Config) ServesTLS() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
+// CheckTLS refuses a TLS configuration that would leave the service unusable: a certificate without its
+// key, or REQUIRE_TLS with no way for a request to ever arrive over TLS
+func (c Config) CheckTLS() error {
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if c.RequireTLS && !c.ServesTLS() && !c.TrustForwardedProto {
+		return errors.New("REQUIRE_TLS needs TLS_CERT_FILE and TLS_KEY_FILE, or TRUST_FORWARDED_PROTO behind a TLS-terminating proxy")
+	}
+	return nil
+}
+
+// CheckGRPC refuses to serve gRPC alongside the guards only the HTTP handlers enforce, so enabling one
+// of them never leaves the gRPC port as a way around it
+func (c Config) CheckGRPC() error {
+	if c.GRPCListenAddress() == "" {
+		return nil
+	}
+
+	switch {
+	case c.RequireTLS && !c.ServesTLS():
+		// A proxy's X-Forwarded-Proto can't vouch for a gRPC connection
+		return errors.New("GRPC_PORT with REQUIRE_TLS needs TLS_CERT_FILE and TLS_KEY_FILE")
+	case c.RequireNonce:
+		return errors.New("GRPC_PORT is not supported with REQUIRE_NONCE")
+	case c.MaxLocksPerOwner > 0:
+		return errors.New("GRPC_PORT is not supported with MAX_LOCKS_PER_OWNER")
+	case c.AcquireRateLimit > 0:
+		return errors.New("GRPC_PORT is not supported with ACQUIRE_RATE_LIMIT")
+	}
+	return nil
+}
+
+// RedisNodeCount is how many Redlock nodes the configuration describes: one per address, or in
+// sentinel mode one per master name
+func (c Config) RedisNodeCount() int {
+	if c.RedisMode == RedisModeSentinel {
+		return len(c.SentinelMasters)
+	}
+
+	count := 0
+	for _, address := range strings.Split(c.RedisAddresses, ",") {
+		if host, _, _ := ParseRedisAddress(address); host != "" {
+			count++
+		}
+	}
+	return count
+}
+
+// ParseRedisAddress splits a node address in the form [[user]:password@]host:port into the host
+// and its credentials, empty when absent
+func ParseRedisAddress(address string) (host, username, password string) {
+	address = strings.TrimSpace(address)
+	at := strings.LastIndex(address, "@")
+	if at < 0 {
+		return address, "", ""
+	}
+
+	username, password, found := strings.Cut(address[:at], ":")
+	if !found {
+		// A single value before '@' is the password, as in redis://:password@host
+		return address[at+1:], "", username
+	}
+	return address[at+1:], username, password
+}
+
+// getEnv returns the environment variable or a default value
+func getEnv(key, defaultValue string) string {
+	if value, exists := os.LookupEnv(key); exists {
+		return strings.TrimSpace(value)
+	}
+	return defaultValue
+}
+
+// getEnvAsList returns the comma-separated values of the environment variable, skipping empty ones
+func getEnvAsList(key string) []string {
+	values := make([]string, 0)
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// getEnvAsIntList returns the comma-separated values of the environment variable as ints, empty when unset.
+// A value that isn't a number becomes 0, left for the caller's validation to reject.
+func getEnvAsIntList(key string) []int {
+	values := getEnvAsList(key)
+	if len(values) == 0 {
+		return nil
+	}
+
+	ints := make([]int, len(values))
+	for i, value := range values {
+		ints[i], _ = strconv.Atoi(value)
+	}
+	return ints
+}
+
+// getEnvAsInt returns the environment variable as int or a default value
+func getEnvAsInt(key string, defaultValue int) int {
+	if value, exists := os.LookupEnv(key); exists {
+		if intValue, err := strconv.Atoi(value); err == nil {
+			return intValue
+		}
+	}
+	return defaultValue
+}
+
+// getEnvAsBool returns the environment variable as bool or a default value
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value, exists := os.LookupEnv(key); exists {
+		if boolValue, err := strconv.ParseBool(strings.TrimSpace(value)); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
+// getEnvAsFloat returns the environment variable as float64 or a default value
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	if value, exists := os.LookupEnv(key); exists {
+		if floatValue, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
+// getEnvAsDuration returns the environment variable as time.Duration or a default value
+func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if duration, err := time.ParseDuration(value); err == nil {
+			return duration
+		}
+	}
+	return defaultValue
+}
@@ -0,0 +1,54 @@
+package config
+
+import "testing"
+
+func TestCheckTLS(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{name: "no TLS", config: Config{}},
+		{name: "certificate and key", config: Config{TLSCertFile: "cert.pem", TLSKeyFile: "key.pem"}},
+		{name: "certificate without key", config: Config{TLSCertFile: "cert.pem"}, wantErr: true},
+		{name: "key without certificate", config: Config{TLSKeyFile: "key.pem"}, wantErr: true},
+		{name: "required and served", config: Config{RequireTLS: true, TLSCertFile: "cert.pem", TLSKeyFile: "key.pem"}},
+		{name: "required behind a proxy", config: Config{RequireTLS: true, TrustForwardedProto: true}},
+		{name: "required and never served", config: Config{RequireTLS: true}, wantErr: true},
+	}
+	for _, tt := range tests {
+		if err := tt.config.CheckTLS(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: CheckTLS() = %v, want an error: %t", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestServesTLS(t *testing.T) {
+	if (Config{}).ServesTLS() {
+		t.Error("ServesTLS() = true without a certificate")
+	}
+	if !(Config{TLSCertFile: "cert.pem", TLSKeyFile: "key.pem"}).ServesTLS() {
+		t.Error("ServesTLS() = false with a certificate and its key")
+	}
+}
+
+func TestCheckGRPC(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{name: "gRPC disabled with every guard", config: Config{RequireTLS: true, RequireNonce: true, MaxLocksPerOwner: 5, AcquireRateLimit: 1}},
+		{name: "gRPC alone", config: Config{GRPCPort: "9090"}},
+		{name: "gRPC over TLS", config: Config{GRPCPort: "9090", RequireTLS: true, TLSCertFile: "cert.pem", TLSKeyFile: "key.pem"}},
+		{name: "gRPC behind a proxy", config: Config{GRPCPort: "9090", RequireTLS: true, TrustForwardedProto: true}, wantErr: true},
+		{name: "gRPC with nonces", config: Config{GRPCPort: "9090", RequireNonce: true}, wantErr: true},
+		{name: "gRPC with a quota", config: Config{GRPCPort: "9090", MaxLocksPerOwner: 5}, wantErr: true},
+		{name: "gRPC with a rate limit", config: Config{GRPCPort: "9090", AcquireRateLimit: 1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		if err := tt.config.CheckGRPC(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: CheckGRPC() = %v, want an error: %t", tt.name, err, tt.wantErr)
+		}
+	}
+}
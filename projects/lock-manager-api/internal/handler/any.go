@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Waelson/lock-manager-service/lock-manager-api/internal/locker"
+	auth "github.com/Waelson/lock-manager-service/lock-manager-api/internal/middleware"
+	"golang.org/x/net/context"
+	"net/http"
+	"time"
+)
+
+type AcquireAnyRequest struct {
+	Resources []string `json:"resources"`
+	N         int      `json:"n"`
+	Ttl       string   `json:"ttl"`
+}
+
+type AcquireAnyResponse struct {
+	Code     int                   `json:"code"`
+	Acquired bool                  `json:"acquired"`
+	Locks    []AcquireLockResponse `json:"locks,omitempty"`
+	MaxTtl   string                `json:"max_ttl,omitempty"` // Set when the requested TTL was clamped to this maximum
+}
+
+// AcquireAnyHandler locks any n of the given resources, failing with 409 if fewer than n are available
+func (l *lockerHandler) AcquireAnyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), RequestTimeout)
+	defer cancel()
+
+	var req AcquireAnyRequest
+	if !decodeBatchBody(w, r, &req) {
+		return
+	}
+
+	if len(req.Resources) == 0 {
+		jsonError(w, "missing 'resources'", http.StatusBadRequest)
+		return
+	}
+	if len(req.Resources) > MaxAcquireBatch {
+		jsonError(w, fmt.Sprintf("at most %d resources per request", MaxAcquireBatch), http.StatusBadRequest)
+		return
+	}
+
+	seen := make(map[string]bool, len(req.Resources))
+	for _, resource := range req.Resources {
+		if resource == "" {
+			jsonError(w, "empty resource in 'resources'", http.StatusBadRequest)
+			return
+		}
+		if err := l.checkResource(resource); err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if seen[resource] {
+			jsonError(w, "duplicate resource in 'resources'", http.StatusBadRequest)
+			return
+		}
+		seen[resource] = true
+	}
+
+	if req.N <= 0 || req.N > len(req.Resources) {
+		jsonError(w, "'n' must be between 1 and the number of resources", http.StatusBadRequest)
+		return
+	}
+
+	if req.Ttl == "" {
+		req.Ttl = "10s"
+	}
+	requested, err := time.ParseDuration(req.Ttl)
+	duration := requested
+	if err == nil {
+		_, err = positiveTTL(requested)
+	}
+	if err == nil {
+		err = l.checkMinTTL(requested)
+	}
+	if err == nil {
+		duration, err = l.capTTL(requested)
+	}
+	if err != nil {
+		jsonError(w, fmt.Sprintf("invalid 'ttl' value: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Every resource named is an acquisition attempt, charged as one made through /lock would be
+	if !l.allowAcquire(w, r, req.Resources...) {
+		return
+	}
+
+	// Reserve a quota slot for the 'n' locks before acquiring, bound to the locks acquired or given back
+	client := auth.ClientID(r)
+	reservations, ok := l.reserveQuota(ctx, w, client, "", req.N, duration)
+	if !ok {
+		return
+	}
+	defer func() { l.releaseReservations(ctx, client, reservations) }()
+
+	locks, err := l.redlock.AcquireAnyN(ctx, req.Resources, req.N, duration)
+	if err != nil {
+		if errors.Is(err, locker.AcquireLockError) {
+			jsonError(w, "fewer than 'n' resources available", http.StatusConflict)
+		} else if errors.Is(err, locker.TTLTooShortError) || errors.Is(err, locker.DrainingError) ||
+			errors.Is(err, locker.QuorumUnavailableError) {
+			jsonError(w, err.Error(), http.StatusServiceUnavailable)
+		} else {
+			jsonError(w, "internal error while acquiring locks", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	l.bindReservations(ctx, client, reservations, locks, duration)
+	reservations = nil
+
+	response := AcquireAnyResponse{
+		Code:     http.StatusOK,
+		Acquired: true,
+		Locks:    make([]AcquireLockResponse, 0, len(locks)),
+		MaxTtl:   maxTTLApplied(requested, duration),
+	}
+	for _, lock := range locks {
+		response.Locks = append(response.Locks, AcquireLockResponse{
+			Code:     http.StatusOK,
+			Token:    lock.Token,
+			Resource: lock.Resource,
+			Ttl:      validity(lock),
+			TtlMs:    lock.TtlMs,
+			Fence:    lock.Fence,
+			Acquired: true,
+		})
+	}
+
+	jsonResponse(w, response, http.StatusOK)
+}
@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Waelson/lock-manager-service/lock-manager-api/internal/locker"
+	auth "github.com/Waelson/lock-manager-service/lock-manager-api/internal/middleware"
+	"golang.org/x/net/context"
+	"net/http"
+	"time"
+)
+
+// MaxAcquireBatch bounds how many resources a single /lock/batch or /lock/any request may name
+const MaxAcquireBatch = 100
+
+// maxBatchBodySize bounds the JSON body accepted by the batch acquisition endpoints
+const maxBatchBodySize = 256 << 10
+
+type AcquireBatchRequest struct {
+	Resources []string `json:"resources"`
+	Ttl       string   `json:"ttl"`
+}
+
+type AcquireBatchResponse struct {
+	Code     int                   `json:"code"`
+	Acquired bool                  `json:"acquired"`
+	Token    string                `json:"token,omitempty"`
+	Locks    []AcquireLockResponse `json:"locks,omitempty"`
+	MaxTtl   string                `json:"max_ttl,omitempty"` // Set when the requested TTL was clamped to this maximum
+}
+
+// AcquireBatchHandler locks all the given resources under one token, failing with 409 and holding none
+// of them if any is unavailable
+func (l *lockerHandler) AcquireBatchHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), RequestTimeout)
+	defer cancel()
+
+	var req AcquireBatchRequest
+	if !decodeBatchBody(w, r, &req) {
+		return
+	}
+
+	if len(req.Resources) == 0 {
+		jsonError(w, "missing 'resources'", http.StatusBadRequest)
+		return
+	}
+	if len(req.Resources) > MaxAcquireBatch {
+		jsonError(w, fmt.Sprintf("at most %d resources per request", MaxAcquireBatch), http.StatusBadRequest)
+		return
+	}
+
+	seen := make(map[string]bool, len(req.Resources))
+	for _, resource := range req.Resources {
+		if resource == "" {
+			jsonError(w, "empty resource in 'resources'", http.StatusBadRequest)
+			return
+		}
+		if err := l.checkResource(resource); err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if seen[resource] {
+			jsonError(w, "duplicate resource in 'resources'", http.StatusBadRequest)
+			return
+		}
+		seen[resource] = true
+	}
+
+	if req.Ttl == "" {
+		req.Ttl = "10s"
+	}
+	requested, err := time.ParseDuration(req.Ttl)
+	duration := requested
+	if err == nil {
+		_, err = positiveTTL(requested)
+	}
+	if err == nil {
+		err = l.checkMinTTL(requested)
+	}
+	if err == nil {
+		duration, err = l.capTTL(requested)
+	}
+	if err != nil {
+		jsonError(w, fmt.Sprintf("invalid 'ttl' value: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Every resource named is an acquisition attempt, charged as one made through /lock would be
+	if !l.allowAcquire(w, r, req.Resources...) {
+		return
+	}
+
+	// Reserve a quota slot for every lock of the batch before acquiring, bound to the locks acquired or given back
+	client := auth.ClientID(r)
+	reservations, ok := l.reserveQuota(ctx, w, client, "", len(req.Resources), duration)
+	if !ok {
+		return
+	}
+	defer func() { l.releaseReservations(ctx, client, reservations) }()
+
+	locks, err := l.redlock.AcquireMulti(ctx, req.Resources, duration)
+	if err != nil {
+		if errors.Is(err, locker.AcquireLockError) {
+			jsonError(w, "not every resource is available", http.StatusConflict)
+		} else if errors.Is(err, locker.TTLTooShortError) || errors.Is(err, locker.DrainingError) ||
+			errors.Is(err, locker.QuorumUnavailableError) {
+			jsonError(w, err.Error(), http.StatusServiceUnavailable)
+		} else {
+			jsonError(w, "internal error while acquiring locks", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	l.bindReservations(ctx, client, reservations, locks, duration)
+	reservations = nil
+
+	response := AcquireBatchResponse{
+		Code:     http.StatusOK,
+		Acquired: true,
+		Token:    locks[0].Token,
+		Locks:    make([]AcquireLockResponse, 0, len(locks)),
+		MaxTtl:   maxTTLApplied(requested, duration),
+	}
+	for _, lock := range locks {
+		response.Locks = append(response.Locks, AcquireLockResponse{
+			Code:     http.StatusOK,
+			Token:    lock.Token,
+			Resource: lock.Resource,
+			Ttl:      validity(lock),
+			TtlMs:    lock.TtlMs,
+			Fence:    lock.Fence,
+			Acquired: true,
+		})
+	}
+
+	jsonResponse(w, response, http.StatusOK)
+}
+
+// decodeBatchBody decodes a batch acquisition body into req, answering 413 when it exceeds
+// maxBatchBodySize and 400 when it isn't valid JSON. It returns false once it has answered.
+func decodeBatchBody(w http.ResponseWriter, r *http.Request, req interface{}) bool {
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBodySize)).Decode(req)
+	if err == nil {
+		return true
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		jsonError(w, fmt.Sprintf("request body larger than %d bytes", maxBatchBodySize), http.StatusRequestEntityTooLarge)
+	} else {
+		jsonError(w, "invalid request body", http.StatusBadRequest)
+	}
+	return false
+}
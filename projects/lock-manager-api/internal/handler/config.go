@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"github.com/Waelson/lock-manager-service/lock-manager-api/internal/config"
+	"github.com/Waelson/lock-manager-service/lock-manager-api/internal/locker"
+	"net/http"
+	"strings"
+)
+
+// redactedValue replaces secrets in the configuration response
+const redactedValue = "[REDACTED]"
+
+type ConfigResponse struct {
+	ListenAddress        string            `json:"listen_address"`
+	GRPCListenAddress    string            `json:"grpc_listen_address,omitempty"`
+	RedisMode            string            `json:"redis_mode"`
+	SentinelMasters      []string          `json:"sentinel_masters,omitempty"`
+	Nodes                int               `json:"nodes"`
+	Quorum               int               `json:"quorum"`
+	NodeWeights          []int             `json:"node_weights,omitempty"`
+	RedisAddresses       []string          `json:"redis_addresses"`
+	NodeTimeout          string            `json:"node_timeout"`
+	StartupCheckTimeout  string            `json:"startup_check_timeout"`
+	MinTTL               string            `json:"min_ttl"`
+	MaxTTL               string            `json:"max_ttl"`
+	MaxTTLPolicy         string            `json:"max_ttl_policy"`
+	MaxResourceLength    int               `json:"max_resource_length"`
+	RequestTimeout       string            `json:"request_timeout"`
+	KeyPrefixes          map[string]string `json:"key_prefixes"`
+	Canonicalization     string            `json:"resource_canonicalization"`
+	Namespace            string            `json:"namespace,omitempty"`
+	Features             map[string]bool   `json:"features"`
+	IdempotencyCacheSize int               `json:"idempotency_cache_size"`
+	IdempotencyCacheTTL  string            `json:"idempotency_cache_ttl"`
+	NonceTTL             string            `json:"nonce_ttl"`
+	MaxLocksPerOwner     int               `json:"max_locks_per_owner"`
+	LatencyWindow        int               `json:"latency_window"`
+	AccessLogFormat      string            `json:"access_log_format,omitempty"`
+	SplitBrainSampleRate float64           `json:"split_brain_sample_rate"`
+	SplitBrainInterval   string            `json:"split_brain_check_interval"`
+	QuorumProbeInterval  string            `json:"quorum_probe_interval"`
+	ShutdownTimeout      string            `json:"shutdown_timeout"`
+	LogLevel             string            `json:"log_level"`
+	AcquireRateLimit     float64           `json:"acquire_rate_limit"`
+	AcquireRateBurst     int               `json:"acquire_rate_burst"`
+	AcquireRetryCount    int               `json:"acquire_retry_count"`
+	AcquireRetryDelay    string            `json:"acquire_retry_delay"`
+	RedisUsername        string            `json:"redis_username,omitempty"`
+	RedisPassword        string            `json:"redis_password"`
+	APIKeys              int               `json:"api_keys"` // How many, never the keys themselves
+	AdminToken           string            `json:"admin_token"`
+}
+
+type configHandler struct {
+	cfg config.Config
+}
+
+type ConfigHandler interface {
+	EffectiveConfigHandler(w http.ResponseWriter, r *http.Request)
+}
+
+func NewConfigHandler(cfg config.Config) ConfigHandler {
+	return &configHandler{cfg: cfg}
+}
+
+// EffectiveConfigHandler returns the configuration in effect, with secrets redacted
+func (c *configHandler) EffectiveConfigHandler(w http.ResponseWriter, r *http.Request) {
+	jsonResponse(w, NewConfigResponse(c.cfg), http.StatusOK)
+}
+
+// NewConfigResponse builds the public view of the configuration, redacting every secret
+func NewConfigResponse(cfg config.Config) ConfigResponse {
+	addresses := make([]string, 0)
+	for _, addr := range strings.Split(cfg.RedisAddresses, ",") {
+		if host, _, _ := config.ParseRedisAddress(addr); host != "" {
+			addresses = append(addresses, host) // Embedded credentials are never exposed
+		}
+	}
+
+	return ConfigResponse{
+		ListenAddress:       cfg.ListenAddress(),
+		GRPCListenAddress:   cfg.GRPCListenAddress(),
+		RedisMode:           cfg.RedisMode,
+		SentinelMasters:     cfg.SentinelMasters,
+		Nodes:               cfg.RedisNodeCount(),
+		Quorum:              locker.WeightedQuorum(cfg.RedisNodeCount(), cfg.RedisNodeWeights),
+		NodeWeights:         cfg.RedisNodeWeights,
+		RedisAddresses:      addresses,
+		NodeTimeout:         cfg.NodeTimeout.String(),
+		StartupCheckTimeout: cfg.StartupCheckTimeout.String(),
+		MinTTL:              cfg.MinTTL.String(),
+		MaxTTL:              cfg.MaxTTL.String(),
+		MaxTTLPolicy:        cfg.MaxTTLPolicy,
+		MaxResourceLength:   cfg.MaxResourceLength,
+		RequestTimeout:      RequestTimeout.String(),
+		KeyPrefixes: map[string]string{
+			"nonce":   locker.NonceKeyPrefix,
+			"quota":   locker.QuotaKeyPrefix,
+			"fence":   locker.FenceKeyPrefix,
+			"shared":  locker.SharedKeyPrefix,
+			"reentry": locker.ReentryKeyPrefix,
+			"meta":    locker.MetaKeyPrefix,
+			"fair":    locker.FairQueueKeyPrefix,
+		},
+		Canonicalization: cfg.Canonicalization,
+		Namespace:        cfg.Namespace,
+		Features: map[string]bool{
+			"keyspace_notifications":  cfg.KeyspaceNotifications,
+			"idempotency_cache":       cfg.IdempotencyCacheSize > 0,
+			"nonce_replay_protection": cfg.RequireNonce,
+			"fair_queue":              true,
+			"verified_acquire":        cfg.VerifiedAcquire,
+			"owner_lock_cap":          cfg.MaxLocksPerOwner > 0,
+			"access_log":              cfg.AccessLog,
+			"require_tls":             cfg.RequireTLS,
+			"trust_forwarded_proto":   cfg.TrustForwardedProto,
+			"tls_listener":            cfg.ServesTLS(),
+			"split_brain_checker":     cfg.SplitBrainSampleRate > 0,
+			"api_key_auth":            len(cfg.APIKeys) > 0,
+		},
+		IdempotencyCacheSize: cfg.IdempotencyCacheSize,
+		IdempotencyCacheTTL:  cfg.IdempotencyCacheTTL.String(),
+		NonceTTL:             cfg.NonceTTL.String(),
+		MaxLocksPerOwner:     cfg.MaxLocksPerOwner,
+		LatencyWindow:        cfg.LatencyWindow,
+		AccessLogFormat:      accessLogFormat(cfg),
+		SplitBrainSampleRate: cfg.SplitBrainSampleRate,
+		SplitBrainInterval:   cfg.SplitBrainInterval.String(),
+		QuorumProbeInterval:  cfg.QuorumProbeInterval.String(),
+		ShutdownTimeout:      cfg.ShutdownTimeout.String(),
+		LogLevel:             cfg.LogLevel,
+		AcquireRateLimit:     cfg.AcquireRateLimit,
+		AcquireRateBurst:     cfg.AcquireRateBurst,
+		AcquireRetryCount:    cfg.AcquireRetryCount,
+		AcquireRetryDelay:    cfg.AcquireRetryDelay.String(),
+		RedisUsername:        cfg.RedisUsername,
+		RedisPassword:        redact(cfg.RedisPassword),
+		APIKeys:              len(cfg.APIKeys),
+		AdminToken:           redact(cfg.AdminToken),
+	}
+}
+
+// redact hides a secret while still telling whether it is set
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return redactedValue
+}
+
+// accessLogFormat is only meaningful while the access log is enabled
+func accessLogFormat(cfg config.Config) string {
+	if !cfg.AccessLog {
+		return ""
+	}
+	return cfg.AccessLogFormat
+}
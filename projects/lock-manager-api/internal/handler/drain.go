@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"github.com/Waelson/lock-manager-service/lock-manager-api/internal/locker"
+	"net/http"
+)
+
+type DrainResponse struct {
+	Code     int  `json:"code"`
+	Draining bool `json:"draining"`
+}
+
+type drainHandler struct {
+	redlock locker.DrainableLocker
+}
+
+type DrainHandler interface {
+	DrainHandler(w http.ResponseWriter, r *http.Request)
+	UndrainHandler(w http.ResponseWriter, r *http.Request)
+}
+
+func NewDrainHandler(redlock locker.DrainableLocker) DrainHandler {
+	return &drainHandler{redlock: redlock}
+}
+
+// DrainHandler refuses new acquisitions with 503 until undrained, e.g. before a Redis node goes down for
+// maintenance. Release, refresh and TTL keep working so the locks already held can finish.
+func (d *drainHandler) DrainHandler(w http.ResponseWriter, r *http.Request) {
+	d.redlock.Drain()
+	jsonResponse(w, DrainResponse{Code: http.StatusOK, Draining: true}, http.StatusOK)
+}
+
+// UndrainHandler accepts new acquisitions again
+func (d *drainHandler) UndrainHandler(w http.ResponseWriter, r *http.Request) {
+	d.redlock.Undrain()
+	jsonResponse(w, DrainResponse{Code: http.StatusOK, Draining: false}, http.StatusOK)
+}
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// eventsKeepAlive is how often an idle events stream sends a comment, so proxies don't close it
+const eventsKeepAlive = 15 * time.Second
+
+type LockEventResponse struct {
+	Resource string `json:"resource"`
+	Event    string `json:"event"`
+}
+
+// EventsHandler streams, as Server-Sent Events, each acquisition, expiry or release of the lock on 'resource'
+// until the client disconnects. Every frame is a 'data:' line holding a LockEventResponse. It requires
+// keyspace notifications, answering 503 without them.
+func (l *lockerHandler) EventsHandler(w http.ResponseWriter, r *http.Request) {
+	resource := r.URL.Query().Get("resource")
+	if resource == "" {
+		jsonError(w, "missing 'resource' parameter", http.StatusBadRequest)
+		return
+	}
+	if err := l.checkResource(resource); err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if l.notifier == nil {
+		jsonError(w, "lock events require keyspace notifications to be enabled", http.StatusServiceUnavailable)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		jsonError(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	events, unwatch := l.notifier.Watch(resource)
+	defer unwatch()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case event, ok := <-events:
+			if !ok {
+				return // The notifier stopped, e.g. on shutdown
+			}
+
+			// The resource is echoed as requested, not canonicalized, so clients can match it
+			frame, _ := json.Marshal(LockEventResponse{Resource: resource, Event: event})
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", frame); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
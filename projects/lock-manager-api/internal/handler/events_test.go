@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/Waelson/lock-manager-service/lock-manager-api/internal/locker"
+	"golang.org/x/net/context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeNotifier hands out watches whose events the test sends itself
+type fakeNotifier struct {
+	mu      sync.Mutex
+	watches map[string]chan string
+	watched chan string // Receives each resource as it gets watched
+}
+
+func newFakeNotifier() *fakeNotifier {
+	return &fakeNotifier{watches: make(map[string]chan string), watched: make(chan string, 1)}
+}
+
+func (f *fakeNotifier) Start(ctx context.Context) error {
+	return nil
+}
+
+func (f *fakeNotifier) Subscribe(resource string) (<-chan struct{}, func()) {
+	return make(chan struct{}), func() {}
+}
+
+func (f *fakeNotifier) Watch(resource string) (<-chan string, func()) {
+	ch := make(chan string, 1)
+	f.mu.Lock()
+	f.watches[resource] = ch
+	f.mu.Unlock()
+	f.watched <- resource
+	return ch, func() {}
+}
+
+// send passes an event on to the watch of resource
+func (f *fakeNotifier) send(resource string, event string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.watches[resource] <- event
+}
+
+func TestEventsHandlerStreamsTheEventsOfTheResource(t *testing.T) {
+	notifier := newFakeNotifier()
+	h := NewLockHandler(locker.NewInMemoryLocker(), WithReleaseNotifier(notifier))
+	server := httptest.NewServer(http.HandlerFunc(h.EventsHandler))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"?resource=item-1", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", contentType)
+	}
+
+	if resource := <-notifier.watched; resource != "item-1" {
+		t.Fatalf("watching %q, want item-1", resource)
+	}
+	notifier.send("item-1", locker.EventReleased)
+
+	lines := bufio.NewScanner(resp.Body)
+	if !lines.Scan() {
+		t.Fatalf("no frame received: %v", lines.Err())
+	}
+	data, ok := strings.CutPrefix(lines.Text(), "data: ")
+	if !ok {
+		t.Fatalf("frame = %q, want a data line", lines.Text())
+	}
+	var event LockEventResponse
+	if err := json.Unmarshal([]byte(data), &event); err != nil {
+		t.Fatalf("decoding %q: %v", data, err)
+	}
+	if event.Resource != "item-1" || event.Event != locker.EventReleased {
+		t.Errorf("event = %+v, want item-1 released", event)
+	}
+}
+
+func TestEventsHandlerRejectsAMissingResource(t *testing.T) {
+	h := NewLockHandler(locker.NewInMemoryLocker(), WithReleaseNotifier(newFakeNotifier()))
+
+	w := httptest.NewRecorder()
+	h.EventsHandler(w, httptest.NewRequest(http.MethodGet, "/events", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", w.Code)
+	}
+}
+
+func TestEventsHandlerNeedsKeyspaceNotifications(t *testing.T) {
+	h := NewLockHandler(locker.NewInMemoryLocker())
+
+	w := httptest.NewRecorder()
+	h.EventsHandler(w, httptest.NewRequest(http.MethodGet, "/events?resource=item-1", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", w.Code)
+	}
+}
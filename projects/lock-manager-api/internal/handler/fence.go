@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"golang.org/x/net/context"
+	"net/http"
+	"strconv"
+)
+
+type ValidateFenceResponse struct {
+	Code     int    `json:"code"`
+	Resource string `json:"resource"`
+	Fence    int64  `json:"fence"`
+	Valid    bool   `json:"valid"`
+}
+
+// ValidateFenceHandler tells a downstream resource whether a fencing token is still the latest issued
+// for the resource, so writes carrying an older one can be rejected
+func (l *lockerHandler) ValidateFenceHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), RequestTimeout)
+	defer cancel()
+
+	resource := r.URL.Query().Get("resource")
+	if resource == "" {
+		jsonError(w, "missing 'resource' parameter", http.StatusBadRequest)
+		return
+	}
+
+	fence, err := strconv.ParseInt(r.URL.Query().Get("fence"), 10, 64)
+	if err != nil || fence <= 0 {
+		jsonError(w, "'fence' must be a positive integer", http.StatusBadRequest)
+		return
+	}
+
+	valid, err := l.redlock.ValidateFence(ctx, resource, fence)
+	if err != nil {
+		jsonError(w, "internal error while validating fence", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, ValidateFenceResponse{
+		Code:     http.StatusOK,
+		Resource: resource,
+		Fence:    fence,
+		Valid:    valid,
+	}, http.StatusOK)
+}
@@ -1,246 +1,829 @@
 package handler
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/Waelson/lock-manager-service/lock-manager-api/internal/cache"
 	"github.com/Waelson/lock-manager-service/lock-manager-api/internal/locker"
+	auth "github.com/Waelson/lock-manager-service/lock-manager-api/internal/middleware"
+	"github.com/Waelson/lock-manager-service/lock-manager-api/internal/ratelimit"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/net/context"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// RequestTimeout bounds the Redis work done for a single HTTP request
+const RequestTimeout = 5 * time.Second
+
+// nonceClockSkew is how far in the future a nonce's creation time may be, for clients whose clock is ahead
+const nonceClockSkew = time.Minute
+
 type AcquireLockResponse struct {
-	Code     int    `json:"code,omitempty"`
-	Token    string `json:"token,omitempty"`
-	Resource string `json:"resource,omitempty"`
-	Ttl      string `json:"ttl,omitempty"`
-	Acquired bool   `json:"acquired"`
-	Message  string `json:"message,omitempty"`
+	Code         int    `json:"code,omitempty"`
+	Token        string `json:"token,omitempty"`
+	Resource     string `json:"resource,omitempty"`
+	Ttl          string `json:"ttl,omitempty"`
+	TtlMs        int64  `json:"ttl_ms,omitempty"` // TTL granted to the lock keys, in milliseconds
+	Fence        int64  `json:"fence,omitempty"`
+	Acquired     bool   `json:"acquired"`
+	HeldByTtl    string `json:"held_by_ttl,omitempty"`    // On conflict, how long the current holder keeps the lock
+	RetryAfterMs int64  `json:"retry_after_ms,omitempty"` // On conflict, the same in milliseconds: the earliest a retry may succeed
+	MaxTtl       string `json:"max_ttl,omitempty"`        // Set when the requested TTL was clamped to this maximum
+	*AcquireTiming
+}
+
+// AcquireTiming exposes the server-side Redlock timings, only sent when verbose=true
+type AcquireTiming struct {
+	ElapsedMs  int64 `json:"elapsed_ms"`
+	ValidityMs int64 `json:"validity_ms"`
+	NodesAcked int   `json:"nodes_acked"`
+}
+
+// ErrorResponse is the body of every error answered by the handlers, with the same code field as the
+// success responses. Resource and token are set when the error concerns a specific lock.
+type ErrorResponse struct {
+	Code         int    `json:"code"`
+	Error        string `json:"error"`
+	Resource     string `json:"resource,omitempty"`
+	Token        string `json:"token,omitempty"`
+	HeldByTtl    string `json:"held_by_ttl,omitempty"`    // On an acquisition conflict, how long the current holder keeps the lock
+	RetryAfterMs int64  `json:"retry_after_ms,omitempty"` // On an acquisition conflict, the same in milliseconds
 }
 
 type ReleaseLockResponse struct {
-	Code     int    `json:"code"`
-	Token    string `json:"token"`
-	Resource string `json:"resource"`
+	Code             int    `json:"code"`
+	Token            string `json:"token"`
+	Resource         string `json:"resource"`
+	RemainingHolders int    `json:"remaining_holders"` // Other shared holders left, 0 once the resource is free
 }
 
 type RefreshLockResponse struct {
-	Code      int    `json:"code"`
-	Token     string `json:"token"`
-	Resource  string `json:"resource"`
-	Ttl       string `json:"ttl"`
-	Refreshed bool   `json:"refreshed"`
-	Message   string `json:"message,omitempty"`
+	Code        int    `json:"code"`
+	Token       string `json:"token"`
+	Resource    string `json:"resource"`
+	Ttl         string `json:"ttl"`
+	Refreshed   bool   `json:"refreshed"`
+	RefreshedOn int    `json:"refreshed_on"`
+	MaxTtl      string `json:"max_ttl,omitempty"` // Set when the requested TTL was clamped to this maximum
+	Warning     string `json:"warning,omitempty"`
 }
 
 type TTLResponse struct {
-	Code     int    `json:"code"`
-	Resource string `json:"resource"`
-	Token    string `json:"token"`
-	Ttl      string `json:"ttl"`
-	Message  string `json:"message,omitempty"`
+	Code       int        `json:"code"`
+	Resource   string     `json:"resource"`
+	Token      string     `json:"token"`
+	Ttl        string     `json:"ttl"`
+	TtlMs      int64      `json:"ttl_ms"`
+	Owner      string     `json:"owner,omitempty"`
+	AcquiredAt *time.Time `json:"acquired_at,omitempty"` // Unset when the lock has no metadata, e.g. a shared lock
+}
+
+type ListLocksResponse struct {
+	Code  int               `json:"code"`
+	Count int               `json:"count"`
+	Locks []locker.LockInfo `json:"locks"`
 }
 
 type lockerHandler struct {
-	redlock locker.RedLocker
+	redlock   locker.RedLocker
+	notifier  locker.ReleaseNotifier
+	fairQueue locker.FairQueue
+	decisions cache.LRU[AcquireLockResponse]
+	decided   cache.LRU[string] // Lock token -> decisions key, to forget an acquisition once released
+	nonces    locker.NonceStore
+	nonceTTL  time.Duration
+	quota     locker.QuotaStore
+	checker   locker.ConsistencyChecker
+	minTTL    time.Duration
+	maxTTL    time.Duration
+	clampTTL  bool
+	limiter   ratelimit.KeyedLimiter
+
+	canonicalize      locker.Canonicalizer
+	maxResourceLength int
+}
+
+// Option defines a functional option for the lock handler
+type Option func(*lockerHandler)
+
+// WithMinTTL rejects acquisitions whose TTL is below minTTL, too short for the lock to be of any use
+func WithMinTTL(minTTL time.Duration) Option {
+	return func(l *lockerHandler) {
+		l.minTTL = minTTL
+	}
+}
+
+// WithMaxTTL caps the TTL of acquisitions and refreshes at maxTTL, clamping longer TTLs down to it when
+// clamp is set and rejecting them otherwise. A zero maxTTL leaves TTLs uncapped.
+func WithMaxTTL(maxTTL time.Duration, clamp bool) Option {
+	return func(l *lockerHandler) {
+		l.maxTTL = maxTTL
+		l.clampTTL = clamp
+	}
+}
+
+// WithAcquireRateLimit limits the acquire attempts on each resource, answering 429 with Retry-After
+// once its bucket is empty, so clients hammering a contended resource don't saturate Redis
+func WithAcquireRateLimit(limiter ratelimit.KeyedLimiter) Option {
+	return func(l *lockerHandler) {
+		l.limiter = limiter
+	}
+}
+
+// WithMaxResourceLength rejects resource names longer than maxLength bytes, locker.DefaultMaxResourceLength when unset
+func WithMaxResourceLength(maxLength int) Option {
+	return func(l *lockerHandler) {
+		if maxLength > 0 {
+			l.maxResourceLength = maxLength
+		}
+	}
+}
+
+// WithCanonicalizer validates resource names in the canonical form they are stored under. It must be the
+// one given to the locker, without the namespace.
+func WithCanonicalizer(canonicalize locker.Canonicalizer) Option {
+	return func(l *lockerHandler) {
+		l.canonicalize = canonicalize
+	}
+}
+
+// WithReleaseNotifier lets waiting acquisitions be woken up as soon as Redis reports the lock key is gone
+func WithReleaseNotifier(notifier locker.ReleaseNotifier) Option {
+	return func(l *lockerHandler) {
+		l.notifier = notifier
+	}
+}
+
+// WithFairQueue enables fair=true acquisitions, which wait their turn in a per-resource queue and are
+// granted the lock in arrival order
+func WithFairQueue(queue locker.FairQueue) Option {
+	return func(l *lockerHandler) {
+		l.fairQueue = queue
+	}
+}
+
+// WithIdempotencyCache keeps recent successful acquisitions by caller, resource and idempotency key so a
+// retried request whose response was lost gets the same lock back without a new fan-out to Redis.
+// decided indexes them by lock token so they are forgotten as soon as the lock is released.
+func WithIdempotencyCache(decisions cache.LRU[AcquireLockResponse], decided cache.LRU[string]) Option {
+	return func(l *lockerHandler) {
+		l.decisions = decisions
+		l.decided = decided
+	}
+}
+
+// WithNonceStore enables replay protection: release and refresh requests must then carry a 'nonce',
+// remembered for nonceTTL once the request succeeded, and a second request with it is rejected
+func WithNonceStore(nonces locker.NonceStore, nonceTTL time.Duration) Option {
+	return func(l *lockerHandler) {
+		l.nonces = nonces
+		l.nonceTTL = nonceTTL
+	}
+}
+
+// WithQuotaStore caps the number of locks held by each client, identified by its API key or address
+func WithQuotaStore(quota locker.QuotaStore) Option {
+	return func(l *lockerHandler) {
+		l.quota = quota
+	}
+}
+
+// WithConsistencyChecker hands acquired resources to the split-brain checker for sampling
+func WithConsistencyChecker(checker locker.ConsistencyChecker) Option {
+	return func(l *lockerHandler) {
+		l.checker = checker
+	}
 }
 
 type LockerHandler interface {
 	AcquireLockHandler(w http.ResponseWriter, r *http.Request)
 	ReleaseLockHandler(w http.ResponseWriter, r *http.Request)
+	ReleaseByTokenHandler(w http.ResponseWriter, r *http.Request)
 	RefreshLockHandler(w http.ResponseWriter, r *http.Request)
 	TTLHandler(w http.ResponseWriter, r *http.Request)
+	TTLBatchHandler(w http.ResponseWriter, r *http.Request)
+	AcquireAnyHandler(w http.ResponseWriter, r *http.Request)
+	AcquireBatchHandler(w http.ResponseWriter, r *http.Request)
+	AcquireSharedHandler(w http.ResponseWriter, r *http.Request)
+	ListLocksHandler(w http.ResponseWriter, r *http.Request)
+	ValidateFenceHandler(w http.ResponseWriter, r *http.Request)
+	EventsHandler(w http.ResponseWriter, r *http.Request)
+}
+
+// ListLocksHandler lists the active locks held by a quorum, optionally filtered by resource 'prefix'
+func (l *lockerHandler) ListLocksHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), RequestTimeout)
+	defer cancel()
+
+	locks, err := l.redlock.List(ctx, r.URL.Query().Get("prefix"))
+	if err != nil {
+		jsonError(w, "Erro interno ao listar os locks", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, ListLocksResponse{
+		Code:  http.StatusOK,
+		Count: len(locks),
+		Locks: locks,
+	}, http.StatusOK)
 }
 
 func (l *lockerHandler) TTLHandler(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), RequestTimeout)
 	defer cancel()
 
 	// Obtém os parâmetros da requisição
 	resource := r.URL.Query().Get("resource")
 	if resource == "" {
-		l.jsonError(w, "missing 'resource' parameter", http.StatusBadRequest)
+		jsonError(w, "missing 'resource' parameter", http.StatusBadRequest)
 		return
 	}
 
 	token := r.URL.Query().Get("token")
 	if token == "" {
-		l.jsonError(w, "missing 'token' parameter", http.StatusBadRequest)
+		jsonError(w, "missing 'token' parameter", http.StatusBadRequest)
 		return
 	}
 
 	// Verifica o tempo restante do lock
-	ttl, err := l.redlock.TTL(ctx, resource, token)
+	ttl, meta, err := l.redlock.TTL(ctx, resource, token)
 	if err != nil {
 		if errors.Is(err, locker.LockNotFoundError) {
-			l.jsonResponse(w, TTLResponse{
+			jsonResponse(w, ErrorResponse{
 				Code:     http.StatusNotFound,
+				Error:    "lock not found or expired",
 				Resource: resource,
 				Token:    token,
-				Ttl:      "0s",
-				Message:  "lock not found or expired",
 			}, http.StatusNotFound)
 		} else {
-			l.jsonError(w, "internal error while checking TTL", http.StatusInternalServerError)
+			jsonError(w, "internal error while checking TTL", http.StatusInternalServerError)
 		}
 		return
 	}
 
 	// Responde com sucesso
-	l.jsonResponse(w, TTLResponse{
+	response := TTLResponse{
 		Code:     http.StatusOK,
 		Resource: resource,
 		Token:    token,
 		Ttl:      ttl.String(),
-	}, http.StatusOK)
+		TtlMs:    ttl.Milliseconds(),
+		Owner:    meta.Owner,
+	}
+	if !meta.AcquiredAt.IsZero() {
+		response.AcquiredAt = &meta.AcquiredAt
+	}
+	jsonResponse(w, response, http.StatusOK)
 }
 
-func NewLockHandler(redlock locker.RedLocker) LockerHandler {
-	return &lockerHandler{redlock: redlock}
+func NewLockHandler(redlock locker.RedLocker, opts ...Option) LockerHandler {
+	l := &lockerHandler{redlock: redlock, maxResourceLength: locker.DefaultMaxResourceLength}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
 }
 
 func (l *lockerHandler) RefreshLockHandler(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	r, ok := withBodyParams(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), RequestTimeout)
 	defer cancel()
 
 	// Obtém os parâmetros da requisição
 	resource := r.URL.Query().Get("resource")
 	if resource == "" {
-		l.jsonError(w, "missing 'resource' parameter", http.StatusBadRequest)
+		jsonError(w, "missing 'resource' parameter", http.StatusBadRequest)
 		return
 	}
 
 	token := r.URL.Query().Get("token")
 	if token == "" {
-		l.jsonError(w, "missing 'token' parameter", http.StatusBadRequest)
+		jsonError(w, "missing 'token' parameter", http.StatusBadRequest)
 		return
 	}
 
-	ttl := r.URL.Query().Get("ttl")
-	if ttl == "" {
-		ttl = "10s" // TTL padrão
+	requested, err := parseTTL(r.URL.Query(), "10s") // TTL padrão
+	duration := requested
+	if err == nil {
+		duration, err = l.capTTL(requested)
 	}
-
-	duration, err := time.ParseDuration(ttl)
 	if err != nil {
-		l.jsonError(w, "invalid 'ttl' value", http.StatusBadRequest)
+		jsonError(w, fmt.Sprintf("invalid 'ttl' value: %v", err), http.StatusBadRequest)
+		return
+	}
+	ttl := duration.String()
+
+	nonce, ok := l.checkNonce(ctx, w, r, resource, token)
+	if !ok {
 		return
 	}
 
+	// Número de nós obtidos na aquisição (nodes_acked), usado para detectar um lock enfraquecido
+	acquiredOn := 0
+	if value := r.URL.Query().Get("acquired_on"); value != "" {
+		acquiredOn, err = strconv.Atoi(value)
+		if err != nil || acquiredOn <= 0 {
+			jsonError(w, "invalid 'acquired_on' value", http.StatusBadRequest)
+			return
+		}
+	}
+
 	// Tenta atualizar o lock
-	err = l.redlock.Refresh(ctx, resource, token, duration)
+	refreshedOn, err := l.redlock.Refresh(ctx, resource, token, duration)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("lock.nodes", refreshedOn))
 	if err != nil {
+		l.forgetNonce(ctx, nonce)
 		if errors.Is(err, locker.LockNotFoundError) {
-			l.jsonResponse(w, RefreshLockResponse{
-				Code:      http.StatusNotFound,
-				Resource:  resource,
-				Token:     token,
-				Ttl:       ttl,
-				Refreshed: false,
-				Message:   err.Error(),
+			jsonResponse(w, ErrorResponse{
+				Code:     http.StatusNotFound,
+				Error:    err.Error(),
+				Resource: resource,
+				Token:    token,
 			}, http.StatusNotFound)
 		} else {
-			l.jsonError(w, "internal error while refreshing lock", http.StatusInternalServerError)
+			jsonError(w, "internal error while refreshing lock", http.StatusInternalServerError)
 		}
 		return
 	}
 
+	// Mantém a entrada da cota do cliente viva enquanto o lock existir
+	if l.quota != nil {
+		if err := l.quota.Extend(ctx, auth.ClientID(r), l.quotaEntry(resource, token), duration); err != nil {
+			log.Printf("error extending quota entry of client '%s': %v\n", auth.ClientID(r), err)
+		}
+	}
+
+	response := RefreshLockResponse{
+		Code:        http.StatusOK,
+		Token:       token,
+		Resource:    resource,
+		Ttl:         ttl,
+		Refreshed:   true,
+		RefreshedOn: refreshedOn,
+		MaxTtl:      maxTTLApplied(requested, duration),
+	}
+
+	// Ainda em quórum, mas em menos nós do que na aquisição
+	if acquiredOn > 0 && refreshedOn < acquiredOn {
+		response.Warning = fmt.Sprintf("lock refreshed on %d nodes, fewer than the %d it was acquired on; consider re-acquiring", refreshedOn, acquiredOn)
+		log.Printf("resource '%s#%s' weakened: refreshed on %d of %d nodes\n", resource, token, refreshedOn, acquiredOn)
+	}
+
 	// Responde com sucesso
-	l.jsonResponse(w, RefreshLockResponse{
-		Code:      http.StatusOK,
-		Token:     token,
-		Resource:  resource,
-		Ttl:       ttl,
-		Refreshed: true,
-	}, http.StatusOK)
+	jsonResponse(w, response, http.StatusOK)
 }
 
+// draining reports whether the locker refuses new acquisitions, checked before replaying a cached one
+func (l *lockerHandler) draining() bool {
+	d, ok := l.redlock.(locker.DrainableLocker)
+	return ok && d.Draining()
+}
+
+// forgetDecision drops the cached acquisition of a lock, if any, so it is never replayed once released
+func (l *lockerHandler) forgetDecision(token string) {
+	if l.decided == nil {
+		return
+	}
+	if key, ok := l.decided.Get(token); ok {
+		l.decisions.Remove(key)
+		l.decided.Remove(token)
+	}
+}
+
+// acquireFunc is the locker operation behind an acquire endpoint, exclusive or shared
+type acquireFunc func(ctx context.Context, resource string, ttl time.Duration) (*locker.Locker, error)
+
+// AcquireLockHandler acquires an exclusive (write) lock, served by /lock and /lock/exclusive.
+// An 'owner' makes the lock reentrant: the same owner acquiring it again gets the same token back.
+// A 'token' chosen by the client makes retries idempotent: a resource already held with it is granted again.
 func (l *lockerHandler) AcquireLockHandler(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-	defer cancel()
+	r, ok := withBodyParams(w, r)
+	if !ok {
+		return
+	}
+
+	owner := r.URL.Query().Get("owner")
+	token := r.URL.Query().Get("token")
+	if owner != "" && token != "" {
+		jsonError(w, "'token' and 'owner' cannot be combined", http.StatusBadRequest)
+		return
+	}
+	if token != "" {
+		if err := locker.ValidateToken(token); err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		l.acquireLock(w, r, func(ctx context.Context, resource string, ttl time.Duration) (*locker.Locker, error) {
+			return l.redlock.AcquireWithToken(ctx, resource, token, ttl)
+		})
+		return
+	}
+	if owner == "" {
+		l.acquireLock(w, r, l.redlock.Acquire)
+		return
+	}
+
+	l.acquireLock(w, r, func(ctx context.Context, resource string, ttl time.Duration) (*locker.Locker, error) {
+		return l.redlock.AcquireReentrant(ctx, resource, owner, ttl)
+	})
+}
 
+// AcquireSharedHandler acquires a shared (read) lock, which coexists with other shared holders
+func (l *lockerHandler) AcquireSharedHandler(w http.ResponseWriter, r *http.Request) {
+	r, ok := withBodyParams(w, r)
+	if !ok {
+		return
+	}
+
+	l.acquireLock(w, r, l.redlock.AcquireShared)
+}
+
+func (l *lockerHandler) acquireLock(w http.ResponseWriter, r *http.Request, acquire acquireFunc) {
 	resource := r.URL.Query().Get("resource")
 	if resource == "" {
-		l.jsonError(w, "Faltando parâmetro 'resource'", http.StatusBadRequest)
+		jsonError(w, "Faltando parâmetro 'resource'", http.StatusBadRequest)
+		return
+	}
+	if err := l.checkResource(resource); err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	ttl := r.URL.Query().Get("ttl")
-	if ttl == "" {
-		ttl = "10ms"
+	// Tempo máximo que o servidor aguarda o lock ser liberado antes de responder 409
+	wait, err := parseWait(r.URL.Query())
+	if err != nil {
+		jsonError(w, fmt.Sprintf("Valor inválido para 'wait': %v", err), http.StatusBadRequest)
+		return
 	}
 
-	duration, err := time.ParseDuration(ttl)
+	// Aguarda a vez em uma fila por recurso, sendo atendido por ordem de chegada
+	fair := r.URL.Query().Get("fair") == "true"
+	if fair && l.fairQueue == nil {
+		jsonError(w, "Modo 'fair' não está habilitado", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), RequestTimeout+wait)
+	defer cancel()
+
+	requested, err := parseTTL(r.URL.Query(), "10s")
+	duration := requested
+	if err == nil {
+		err = l.checkMinTTL(requested)
+	}
+	if err == nil {
+		duration, err = l.capTTL(requested)
+	}
 	if err != nil {
-		l.jsonError(w, "Valor inválido para 'ttl'", http.StatusBadRequest)
+		jsonError(w, fmt.Sprintf("Valor inválido para 'ttl': %v", err), http.StatusBadRequest)
 		return
 	}
 
-	lock, err := l.redlock.Acquire(ctx, resource, duration)
+	if l.draining() {
+		jsonResponse(w, ErrorResponse{
+			Code:     http.StatusServiceUnavailable,
+			Error:    locker.DrainingError.Error(),
+			Resource: resource,
+		}, http.StatusServiceUnavailable)
+		return
+	}
+
+	if !l.allowAcquire(w, r, resource) {
+		return
+	}
+
+	// Reserva uma vaga na cota do cliente antes de adquirir, vinculada ao lock obtido ou devolvida se
+	// nenhum lock for obtido
+	client := auth.ClientID(r)
+	reservations, ok := l.reserveQuota(ctx, w, client, resource, 1, duration)
+	if !ok {
+		return
+	}
+	defer func() { l.releaseReservations(ctx, client, reservations) }()
+
+	// Replay the outcome of a recent acquisition made by the same caller with the same idempotency key.
+	// Only once the drain, rate limit and quota let the request through, so none can be bypassed with a replay.
+	var decisionKey string
+	if idempotencyKey := r.URL.Query().Get("idempotency_key"); idempotencyKey != "" && l.decisions != nil {
+		decisionKey = client + "\x00" + resource + "\x00" + idempotencyKey
+		if cached, ok := l.decisions.Get(decisionKey); ok {
+			jsonResponse(w, cached, http.StatusOK)
+			return
+		}
+	}
+
+	var lock *locker.Locker
+	if fair {
+		lock, err = l.acquireFair(ctx, acquire, resource, duration, wait)
+	} else {
+		lock, err = l.acquireWaiting(ctx, acquire, resource, duration, wait)
+	}
 	if err != nil {
 		if errors.Is(err, locker.AcquireLockError) {
-			l.jsonResponse(w, AcquireLockResponse{
+			response := ErrorResponse{
 				Code:     http.StatusConflict,
+				Error:    err.Error(),
+				Resource: resource,
+			}
+			var conflict *locker.ConflictError
+			if errors.As(err, &conflict) && conflict.HeldFor > 0 {
+				response.HeldByTtl = conflict.HeldFor.String()
+				response.RetryAfterMs = conflict.HeldFor.Milliseconds()
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(conflict.HeldFor.Seconds()))))
+			}
+			jsonResponse(w, response, http.StatusConflict)
+		} else if errors.Is(err, locker.TTLTooShortError) || errors.Is(err, locker.DrainingError) ||
+			errors.Is(err, locker.QuorumUnavailableError) {
+			jsonResponse(w, ErrorResponse{
+				Code:     http.StatusServiceUnavailable,
+				Error:    err.Error(),
 				Resource: resource,
-				Message:  err.Error(),
-				Acquired: false,
-			}, http.StatusConflict)
+			}, http.StatusServiceUnavailable)
 		} else {
-			l.jsonError(w, "Erro interno ao adquirir o lock", http.StatusInternalServerError)
+			jsonError(w, "Erro interno ao adquirir o lock", http.StatusInternalServerError)
 		}
 		return
 	}
 
-	l.jsonResponse(w, AcquireLockResponse{
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.String("lock.resource", lock.Resource),
+		attribute.Int("lock.nodes", lock.NodesAcked),
+		attribute.Int64("lock.fence", lock.Fence))
+
+	response := AcquireLockResponse{
 		Code:     http.StatusOK,
 		Token:    lock.Token,
 		Resource: lock.Resource,
-		Ttl:      ttl,
+		Ttl:      validity(lock), // The safe time left once the acquisition and clock drift are accounted for
+		TtlMs:    lock.TtlMs,
+		Fence:    lock.Fence,
 		Acquired: true,
-	}, http.StatusOK)
+		MaxTtl:   maxTTLApplied(requested, duration),
+	}
+
+	// A vaga reservada passa a contar o lock obtido; um lock reentrado já é contado uma única vez.
+	// Se o vínculo falhar, a reserva continua contando até expirar junto com o lock.
+	l.bindReservations(ctx, client, reservations, []*locker.Locker{lock}, duration)
+	reservations = nil
+
+	if l.checker != nil {
+		l.checker.Sample(lock.Resource)
+	}
+
+	if r.URL.Query().Get("verbose") == "true" {
+		response.AcquireTiming = &AcquireTiming{
+			ElapsedMs:  lock.Elapsed.Milliseconds(),
+			ValidityMs: lock.Validity.Milliseconds(),
+			NodesAcked: lock.NodesAcked,
+		}
+	}
+
+	// The cached outcome is useless once the lock itself has expired
+	if decisionKey != "" {
+		l.decisions.Add(decisionKey, response, duration)
+		l.decided.Add(lock.Token, decisionKey, duration)
+	}
+
+	jsonResponse(w, response, http.StatusOK)
 }
 
 func (l *lockerHandler) ReleaseLockHandler(w http.ResponseWriter, r *http.Request) {
+	r, ok := withBodyParams(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), RequestTimeout)
+	defer cancel()
+
 	resource := r.URL.Query().Get("resource")
 	if resource == "" {
-		l.jsonError(w, "missing 'resource' parameter", http.StatusBadRequest)
+		jsonError(w, "missing 'resource' parameter", http.StatusBadRequest)
 		return
 	}
 
 	token := r.URL.Query().Get("token")
 	if token == "" {
-		l.jsonError(w, "missing 'token' parameter", http.StatusBadRequest)
+		jsonError(w, "missing 'token' parameter", http.StatusBadRequest)
 		return
 	}
 
-	err := l.redlock.Release(context.Background(), resource, token)
+	nonce, ok := l.checkNonce(ctx, w, r, resource, token)
+	if !ok {
+		return
+	}
+
+	remaining, err := l.redlock.ReleaseRemaining(ctx, resource, token)
+	if err == nil || errors.Is(err, locker.LockNotFoundError) {
+		l.forgetDecision(token)
+	}
+	if err != nil {
+		l.forgetNonce(ctx, nonce)
+	}
+
+	// Deixa de contabilizar o lock para o cliente, liberado agora ou já expirado. Um lock reentrante
+	// que ainda tem aquisições externas continua contando.
+	if l.quota != nil && (err == nil || errors.Is(err, locker.LockNotFoundError)) {
+		stillHeld := false
+		if err == nil {
+			_, _, heldErr := l.redlock.TTL(ctx, resource, token)
+			stillHeld = heldErr == nil
+		}
+		if !stillHeld {
+			if quotaErr := l.quota.Release(ctx, auth.ClientID(r), l.quotaEntry(resource, token)); quotaErr != nil {
+				log.Printf("error releasing quota entry of client '%s': %v\n", auth.ClientID(r), quotaErr)
+			}
+		}
+	}
+
 	if err != nil {
 		if errors.Is(err, locker.LockNotFoundError) {
-			l.jsonResponse(w, map[string]interface{}{
-				"code":     http.StatusNotFound,
-				"resource": resource,
-				"token":    token,
-				"message":  "lock not found or expired",
+			jsonResponse(w, ErrorResponse{
+				Code:     http.StatusNotFound,
+				Error:    "lock not found or expired",
+				Resource: resource,
+				Token:    token,
 			}, http.StatusNotFound)
 			return
 		} else if errors.Is(err, locker.InternalError) {
-			l.jsonError(w, "internal error while releasing lock", http.StatusInternalServerError)
+			jsonError(w, "internal error while releasing lock", http.StatusInternalServerError)
 			return
 		} else {
-			l.jsonError(w, fmt.Sprintf("unexpected error: %v", err), http.StatusInternalServerError)
+			jsonError(w, fmt.Sprintf("unexpected error: %v", err), http.StatusInternalServerError)
 			return
 		}
 	}
 
-	l.jsonResponse(w, ReleaseLockResponse{
-		Code:     http.StatusOK,
-		Token:    token,
-		Resource: resource,
+	jsonResponse(w, ReleaseLockResponse{
+		Code:             http.StatusOK,
+		Token:            token,
+		Resource:         resource,
+		RemainingHolders: remaining,
 	}, http.StatusOK)
 }
 
-func (l *lockerHandler) jsonResponse(w http.ResponseWriter, content interface{}, code int) {
+// checkNonce validates the 'nonce' parameter, required when replay protection is enabled, and reserves it
+// bound to the lock the request is about, before the request runs, so concurrent replays can't both pass.
+// A request that then fails gives its nonce back with forgetNonce. A nonce starts with the Unix time in seconds it was created at, as "<seconds>.<random>", so a request
+// replayed after NONCE_TTL, once its nonce was forgotten, is refused as stale. Writes an error response
+// and returns false when the request must not proceed.
+func (l *lockerHandler) checkNonce(ctx context.Context, w http.ResponseWriter, r *http.Request, resource string, token string) (nonceClaim, bool) {
+	if l.nonces == nil {
+		return nonceClaim{}, true
+	}
+
+	nonce := r.URL.Query().Get("nonce")
+	if nonce == "" {
+		jsonError(w, "missing 'nonce' parameter", http.StatusBadRequest)
+		return nonceClaim{}, false
+	}
+	created, random, found := strings.Cut(nonce, ".")
+	seconds, err := strconv.ParseInt(created, 10, 64)
+	if !found || random == "" || err != nil {
+		jsonError(w, "'nonce' must be '<unix seconds>.<random>'", http.StatusBadRequest)
+		return nonceClaim{}, false
+	}
+	if age := time.Since(time.Unix(seconds, 0)); age > l.nonceTTL || age < -nonceClockSkew {
+		jsonError(w, "'nonce' is stale or from the future", http.StatusBadRequest)
+		return nonceClaim{}, false
+	}
+
+	// Kept for as long as the nonce would be accepted
+	claim := nonceClaim{bound: boundNonce(resource, token, nonce)}
+	claim.reservation, err = l.nonces.Reserve(ctx, claim.bound, l.nonceTTL+nonceClockSkew)
+	if errors.Is(err, locker.NonceReplayError) {
+		jsonError(w, "'nonce' already used", http.StatusConflict)
+		return nonceClaim{}, false
+	} else if err != nil {
+		jsonError(w, "internal error while checking nonce", http.StatusInternalServerError)
+		return nonceClaim{}, false
+	}
+	return claim, true
+}
+
+// nonceClaim is a nonce reserved by checkNonce, empty when replay protection is disabled
+type nonceClaim struct {
+	bound       string
+	reservation string
+}
+
+// allowAcquire charges one acquisition attempt to the rate limit of each resource, answering 429 with the
+// first resource over its limit. It returns false once it has answered.
+func (l *lockerHandler) allowAcquire(w http.ResponseWriter, r *http.Request, resources ...string) bool {
+	if l.limiter == nil {
+		return true
+	}
+
+	for _, resource := range resources {
+		if allowed, retryAfter := l.limiter.Allow(resource); !allowed {
+			auth.SetOutcome(r.Context(), auth.OutcomeRateLimited)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			jsonResponse(w, ErrorResponse{
+				Code:     http.StatusTooManyRequests,
+				Error:    "Muitas tentativas de aquisição neste recurso",
+				Resource: resource,
+			}, http.StatusTooManyRequests)
+			return false
+		}
+	}
+	return true
+}
+
+// reserveQuota reserves n slots of the client's quota for the locks about to be acquired, answering 429
+// when they would take it past the cap. It returns no reservation without a quota store, and false once
+// it has answered.
+func (l *lockerHandler) reserveQuota(ctx context.Context, w http.ResponseWriter, client string, resource string, n int, ttl time.Duration) ([]string, bool) {
+	if l.quota == nil {
+		return nil, true
+	}
+
+	reservations := make([]string, n)
+	for i := range reservations {
+		reservations[i] = uuid.New().String()
+	}
+	if err := l.quota.Reserve(ctx, client, ttl, reservations...); err != nil {
+		if errors.Is(err, locker.QuotaExceededError) {
+			auth.SetOutcome(ctx, auth.OutcomeOverQuota)
+			jsonResponse(w, ErrorResponse{
+				Code:     http.StatusTooManyRequests,
+				Error:    err.Error(),
+				Resource: resource,
+			}, http.StatusTooManyRequests)
+		} else {
+			jsonError(w, "Erro interno ao verificar a cota do cliente", http.StatusInternalServerError)
+		}
+		return nil, false
+	}
+	return reservations, true
+}
+
+// bindReservations makes the reservations count the locks acquired under them, one lock each. Those left
+// over, when fewer locks were acquired, are released.
+func (l *lockerHandler) bindReservations(ctx context.Context, client string, reservations []string, locks []*locker.Locker, ttl time.Duration) {
+	if len(reservations) == 0 {
+		return
+	}
+
+	bindings := make(map[string]string, len(locks))
+	for i, lock := range locks {
+		bindings[reservations[i]] = locker.QuotaEntry(lock.Resource, lock.Token)
+	}
+	if err := l.quota.Bind(ctx, client, ttl, bindings); err != nil {
+		log.Printf("error binding quota reservation of client '%s': %v\n", client, err)
+	}
+	l.releaseReservations(ctx, client, reservations[len(locks):])
+}
+
+// releaseReservations gives back reservations no lock was acquired under
+func (l *lockerHandler) releaseReservations(ctx context.Context, client string, reservations []string) {
+	if len(reservations) == 0 {
+		return
+	}
+	if err := l.quota.Release(ctx, client, reservations...); err != nil {
+		log.Printf("error releasing quota reservation of client '%s': %v\n", client, err)
+	}
+}
+
+// quotaEntry is the quota entry of the lock held on resource, as named in a request, with token
+func (l *lockerHandler) quotaEntry(resource string, token string) string {
+	if l.canonicalize != nil {
+		resource = l.canonicalize(resource)
+	}
+	return locker.QuotaEntry(resource, token)
+}
+
+// forgetNonce gives back the nonce of a request that failed, so the client may retry with it
+func (l *lockerHandler) forgetNonce(ctx context.Context, claim nonceClaim) {
+	if claim.reservation == "" {
+		return
+	}
+	if err := l.nonces.Forget(ctx, claim.bound, claim.reservation); err != nil {
+		log.Printf("error forgetting nonce: %v\n", err)
+	}
+}
+
+// boundNonce binds a nonce to the resource and token it was sent with, so it only ever matches a replay
+// of the same request
+func boundNonce(resource string, token string, nonce string) string {
+	sum := sha256.Sum256([]byte(resource + "\x00" + token + "\x00" + nonce))
+	return hex.EncodeToString(sum[:])
+}
+
+func jsonResponse(w http.ResponseWriter, content interface{}, code int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
 
@@ -250,6 +833,6 @@ func (l *lockerHandler) jsonResponse(w http.ResponseWriter, content interface{},
 }
 
 // Função auxiliar para responder erros JSON
-func (l *lockerHandler) jsonError(w http.ResponseWriter, message string, code int) {
-	l.jsonResponse(w, map[string]string{"error": message}, code)
+func jsonError(w http.ResponseWriter, message string, code int) {
+	jsonResponse(w, ErrorResponse{Code: code, Error: message}, code)
 }
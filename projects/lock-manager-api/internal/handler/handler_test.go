@@ -0,0 +1,219 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/Waelson/lock-manager-service/lock-manager-api/internal/cache"
+	"github.com/Waelson/lock-manager-service/lock-manager-api/internal/locker"
+	"github.com/Waelson/lock-manager-service/lock-manager-api/internal/ratelimit"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serve runs a request through the handler and returns the recorded response
+func serve(handler http.HandlerFunc, method string, target string, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+// newTestNodeSet returns a node set over three in-memory Redis servers, for the stores built on it
+func newTestNodeSet(t *testing.T) *locker.NodeSet {
+	t.Helper()
+
+	clients := make([]*redis.Client, 3)
+	for i := range clients {
+		clients[i] = redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+		t.Cleanup(func() { _ = clients[i].Close() })
+	}
+	nodes, err := locker.NewNodeSet(clients)
+	if err != nil {
+		t.Fatalf("NewNodeSet: %v", err)
+	}
+	return nodes
+}
+
+// decode reads the JSON response recorded by serve
+func decode[T any](t *testing.T, w *httptest.ResponseRecorder) T {
+	t.Helper()
+
+	var response T
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("decoding the response: %v", err)
+	}
+	return response
+}
+
+func TestAcquireReplaysTheDecisionOfAnIdempotencyKey(t *testing.T) {
+	h := NewLockHandler(locker.NewInMemoryLocker(),
+		WithIdempotencyCache(cache.NewLRU[AcquireLockResponse](10, time.Minute), cache.NewLRU[string](10, time.Minute)))
+
+	w := serve(h.AcquireLockHandler, http.MethodPost, "/lock?resource=item-1&ttl=1s&idempotency_key=k-1", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("acquire status = %d, want 200", w.Code)
+	}
+	first := decode[AcquireLockResponse](t, w)
+
+	// The response was lost: the retry gets the same lock instead of a conflict
+	w = serve(h.AcquireLockHandler, http.MethodPost, "/lock?resource=item-1&ttl=1s&idempotency_key=k-1", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("retried acquire status = %d, want 200", w.Code)
+	}
+	if retried := decode[AcquireLockResponse](t, w); retried.Token != first.Token {
+		t.Errorf("retried token = %q, want %q", retried.Token, first.Token)
+	}
+
+	if w := serve(h.AcquireLockHandler, http.MethodPost, "/lock?resource=item-1&ttl=1s&idempotency_key=k-2", ""); w.Code != http.StatusConflict {
+		t.Errorf("acquire with another key status = %d, want 409", w.Code)
+	}
+}
+
+func TestReleaseForgetsTheDecisionOfAnIdempotencyKey(t *testing.T) {
+	h := NewLockHandler(locker.NewInMemoryLocker(),
+		WithIdempotencyCache(cache.NewLRU[AcquireLockResponse](10, time.Minute), cache.NewLRU[string](10, time.Minute)))
+
+	w := serve(h.AcquireLockHandler, http.MethodPost, "/lock?resource=item-1&ttl=1s&idempotency_key=k-1", "")
+	first := decode[AcquireLockResponse](t, w)
+	if w := serve(h.ReleaseLockHandler, http.MethodPost, "/unlock?resource=item-1&token="+first.Token, ""); w.Code != http.StatusOK {
+		t.Fatalf("release status = %d, want 200", w.Code)
+	}
+
+	// A released lock is never replayed, the key acquires it anew
+	w = serve(h.AcquireLockHandler, http.MethodPost, "/lock?resource=item-1&ttl=1s&idempotency_key=k-1", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("acquire status = %d, want 200", w.Code)
+	}
+	if again := decode[AcquireLockResponse](t, w); again.Token == first.Token {
+		t.Error("the released lock was replayed")
+	}
+}
+
+func TestAcquireIsRateLimitedPerResource(t *testing.T) {
+	h := NewLockHandler(locker.NewInMemoryLocker(), WithAcquireRateLimit(ratelimit.NewKeyedLimiter(0.5, 1)))
+
+	if w := serve(h.AcquireLockHandler, http.MethodPost, "/lock?resource=item-1&ttl=1s", ""); w.Code != http.StatusOK {
+		t.Fatalf("first acquire status = %d, want 200", w.Code)
+	}
+
+	// Limited before the conflict with the lock just taken is even checked
+	w := serve(h.AcquireLockHandler, http.MethodPost, "/lock?resource=item-1&ttl=1s", "")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second acquire status = %d, want 429", w.Code)
+	}
+	if retryAfter := w.Header().Get("Retry-After"); retryAfter != "2" {
+		t.Errorf("Retry-After = %q, want 2 at one token every 2s", retryAfter)
+	}
+	if response := decode[ErrorResponse](t, w); response.Resource != "item-1" {
+		t.Errorf("response = %+v, want the limited resource", response)
+	}
+
+	if w := serve(h.AcquireLockHandler, http.MethodPost, "/lock?resource=item-2&ttl=1s", ""); w.Code != http.StatusOK {
+		t.Errorf("acquire of another resource status = %d, want 200", w.Code)
+	}
+}
+
+func TestAcquireBatchIsRateLimitedOnEachResource(t *testing.T) {
+	h := NewLockHandler(locker.NewInMemoryLocker(), WithAcquireRateLimit(ratelimit.NewKeyedLimiter(0.5, 1)))
+
+	if w := serve(h.AcquireLockHandler, http.MethodPost, "/lock?resource=item-2&ttl=1s", ""); w.Code != http.StatusOK {
+		t.Fatalf("acquire status = %d, want 200", w.Code)
+	}
+
+	// A batch can't be used to get around the limit of one of its resources
+	w := serve(h.AcquireBatchHandler, http.MethodPost, "/lock/batch", `{"resources":["item-1","item-2"],"ttl":"1s"}`)
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("batch status = %d, want 429", w.Code)
+	}
+}
+
+func TestAcquireBatchCountsEveryLockAgainstTheQuota(t *testing.T) {
+	h := NewLockHandler(locker.NewInMemoryLocker(), WithQuotaStore(locker.NewQuotaStore(newTestNodeSet(t), 2)))
+
+	// Refused as a whole, without taking any slot
+	w := serve(h.AcquireBatchHandler, http.MethodPost, "/lock/batch", `{"resources":["item-1","item-2","item-3"],"ttl":"1s"}`)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("batch of 3 status = %d, want 429", w.Code)
+	}
+
+	w = serve(h.AcquireBatchHandler, http.MethodPost, "/lock/batch", `{"resources":["item-1","item-2"],"ttl":"1s"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("batch of 2 status = %d, want 200", w.Code)
+	}
+	batch := decode[AcquireBatchResponse](t, w)
+
+	if w := serve(h.AcquireLockHandler, http.MethodPost, "/lock?resource=item-3&ttl=1s", ""); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("acquire past the quota status = %d, want 429", w.Code)
+	}
+	if w := serve(h.ReleaseLockHandler, http.MethodPost, "/unlock?resource=item-1&token="+batch.Token, ""); w.Code != http.StatusOK {
+		t.Fatalf("release status = %d, want 200", w.Code)
+	}
+	if w := serve(h.AcquireLockHandler, http.MethodPost, "/lock?resource=item-3&ttl=1s", ""); w.Code != http.StatusOK {
+		t.Errorf("acquire once a lock was released status = %d, want 200", w.Code)
+	}
+}
+
+func TestAcquireAnyReservesTheLocksItTakes(t *testing.T) {
+	h := NewLockHandler(locker.NewInMemoryLocker(), WithQuotaStore(locker.NewQuotaStore(newTestNodeSet(t), 2)))
+
+	w := serve(h.AcquireAnyHandler, http.MethodPost, "/lock/any", `{"resources":["slot-1","slot-2","slot-3"],"n":2,"ttl":"1s"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("acquire any status = %d, want 200", w.Code)
+	}
+	if w := serve(h.AcquireLockHandler, http.MethodPost, "/lock?resource=item-1&ttl=1s", ""); w.Code != http.StatusTooManyRequests {
+		t.Errorf("acquire past the quota status = %d, want 429", w.Code)
+	}
+}
+
+func TestRefreshRefusesAReplayedNonce(t *testing.T) {
+	h := NewLockHandler(locker.NewInMemoryLocker(), WithNonceStore(locker.NewNonceStore(newTestNodeSet(t)), time.Minute))
+
+	lock := decode[AcquireLockResponse](t, serve(h.AcquireLockHandler, http.MethodPost, "/lock?resource=item-1&ttl=10s", ""))
+	refresh := "/refresh?resource=item-1&ttl=10s&token=" + lock.Token
+
+	nonce := strconv.FormatInt(time.Now().Unix(), 10) + ".a1b2c3"
+	if w := serve(h.RefreshLockHandler, http.MethodPost, refresh+"&nonce="+nonce, ""); w.Code != http.StatusOK {
+		t.Fatalf("refresh status = %d, want 200", w.Code)
+	}
+	if w := serve(h.RefreshLockHandler, http.MethodPost, refresh+"&nonce="+nonce, ""); w.Code != http.StatusConflict {
+		t.Errorf("replayed refresh status = %d, want 409", w.Code)
+	}
+
+	stale := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10) + ".d4e5f6"
+	invalid := map[string]string{
+		"missing":   refresh,
+		"malformed": refresh + "&nonce=a1b2c3",
+		"stale":     refresh + "&nonce=" + stale,
+	}
+	for name, target := range invalid {
+		if w := serve(h.RefreshLockHandler, http.MethodPost, target, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("refresh with a %s nonce status = %d, want 400", name, w.Code)
+		}
+	}
+}
+
+func TestReleaseGivesBackTheNonceOfAFailedRequest(t *testing.T) {
+	h := NewLockHandler(locker.NewInMemoryLocker(), WithNonceStore(locker.NewNonceStore(newTestNodeSet(t)), time.Minute))
+
+	lock := decode[AcquireLockResponse](t, serve(h.AcquireLockHandler, http.MethodPost, "/lock?resource=item-1&ttl=10s", ""))
+	nonce := strconv.FormatInt(time.Now().Unix(), 10) + ".a1b2c3"
+
+	// Refused for the wrong token, so the nonce may be used again
+	if w := serve(h.ReleaseLockHandler, http.MethodPost, "/unlock?resource=item-1&token=not-the-token&nonce="+nonce, ""); w.Code == http.StatusOK {
+		t.Fatal("release with another token succeeded")
+	}
+	if w := serve(h.ReleaseLockHandler, http.MethodPost, "/unlock?resource=item-1&token=not-the-token&nonce="+nonce, ""); w.Code == http.StatusConflict {
+		t.Error("the nonce of a failed release was kept")
+	}
+	if w := serve(h.ReleaseLockHandler, http.MethodPost, "/unlock?resource=item-1&token="+lock.Token+"&nonce="+nonce, ""); w.Code != http.StatusOK {
+		t.Errorf("release status = %d, want 200", w.Code)
+	}
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"github.com/Waelson/lock-manager-service/lock-manager-api/internal/locker"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/net/context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HealthPingTimeout bounds the PING sent to each node by the readiness probe
+const HealthPingTimeout = 500 * time.Millisecond
+
+type NodeHealth struct {
+	Address string `json:"address"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+}
+
+type ReadinessResponse struct {
+	Status string       `json:"status"`
+	Quorum int          `json:"quorum"`
+	Up     int          `json:"up"` // Votes of the nodes up, their count unless node weights are set
+	Nodes  []NodeHealth `json:"nodes"`
+}
+
+type healthHandler struct {
+	redisNodes []*redis.Client
+	weights    []int
+}
+
+type HealthHandler interface {
+	ReadyHandler(w http.ResponseWriter, r *http.Request)
+	LiveHandler(w http.ResponseWriter, r *http.Request)
+}
+
+// NewHealthHandler creates the liveness and readiness probes. weights are the node weights given to the
+// locker, or nil for one vote per node.
+func NewHealthHandler(redisNodes []*redis.Client, weights []int) HealthHandler {
+	return &healthHandler{redisNodes: redisNodes, weights: weights}
+}
+
+// ReadyHandler pings every Redis node and answers 200 only while a quorum responds, 503 otherwise
+func (h *healthHandler) ReadyHandler(w http.ResponseWriter, r *http.Request) {
+	var wg sync.WaitGroup
+	nodes := make([]NodeHealth, len(h.redisNodes))
+
+	// Parallelize the ping of each Redis node
+	for i, node := range h.redisNodes {
+		wg.Add(1)
+		go func(i int, node *redis.Client) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(r.Context(), HealthPingTimeout)
+			defer cancel()
+
+			nodes[i] = NodeHealth{Address: node.Options().Addr, Status: "UP"}
+			if err := node.Ping(ctx).Err(); err != nil {
+				nodes[i].Status = "DOWN"
+				nodes[i].Error = err.Error()
+			}
+		}(i, node)
+	}
+
+	wg.Wait()
+
+	response := ReadinessResponse{
+		Status: "UP",
+		Quorum: locker.WeightedQuorum(len(h.redisNodes), h.weights),
+		Nodes:  nodes,
+	}
+	for i, node := range nodes {
+		if node.Status != "UP" {
+			continue
+		}
+		if len(h.weights) == len(nodes) {
+			response.Up += h.weights[i]
+		} else {
+			response.Up++
+		}
+	}
+
+	code := http.StatusOK
+	if response.Up < response.Quorum {
+		response.Status = "DOWN"
+		code = http.StatusServiceUnavailable
+	}
+
+	jsonResponse(w, response, code)
+}
+
+// LiveHandler always answers 200 while the process is able to serve requests
+func (h *healthHandler) LiveHandler(w http.ResponseWriter, r *http.Request) {
+	jsonResponse(w, map[string]string{"status": "UP"}, http.StatusOK)
+}
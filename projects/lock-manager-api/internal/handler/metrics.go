@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"fmt"
+	"github.com/Waelson/lock-manager-service/lock-manager-api/internal/locker"
+	"net/http"
+	"strings"
+)
+
+type metricsHandler struct {
+	prober locker.QuorumProber
+}
+
+type MetricsHandler interface {
+	MetricsHandler(w http.ResponseWriter, r *http.Request)
+}
+
+func NewMetricsHandler(prober locker.QuorumProber) MetricsHandler {
+	return &metricsHandler{prober: prober}
+}
+
+// MetricsHandler exposes the quorum gauges of the last probe in the Prometheus text format
+func (m *metricsHandler) MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	status := m.prober.Status()
+
+	var b strings.Builder
+	gauge(&b, "lock_manager_quorum_available", "Whether the Redis nodes up hold a quorum of votes (1) or not (0).")
+	fmt.Fprintf(&b, "lock_manager_quorum_available %d\n", boolGauge(status.Available))
+	gauge(&b, "lock_manager_quorum_votes", "Votes needed for a quorum.")
+	fmt.Fprintf(&b, "lock_manager_quorum_votes %d\n", status.Quorum)
+	gauge(&b, "lock_manager_up_votes", "Votes of the Redis nodes that answered the last probe.")
+	fmt.Fprintf(&b, "lock_manager_up_votes %d\n", status.UpVotes)
+	gauge(&b, "lock_manager_node_up", "Whether each Redis node answered the last probe (1) or not (0).")
+	for _, node := range status.Nodes {
+		fmt.Fprintf(&b, "lock_manager_node_up{node=\"%s\"} %d\n", escapeLabel(node.Addr), boolGauge(node.Up))
+	}
+	if !status.CheckedAt.IsZero() {
+		gauge(&b, "lock_manager_quorum_checked_timestamp_seconds", "Unix time of the last probe.")
+		fmt.Fprintf(&b, "lock_manager_quorum_checked_timestamp_seconds %d\n", status.CheckedAt.Unix())
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(b.String()))
+}
+
+// gauge writes the HELP and TYPE lines of a gauge
+func gauge(b *strings.Builder, name string, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+}
+
+func boolGauge(value bool) int {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+// escapeLabel escapes a label value as the Prometheus text format requires
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Waelson/lock-manager-service/lock-manager-api/internal/locker"
+	auth "github.com/Waelson/lock-manager-service/lock-manager-api/internal/middleware"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ttlUnits are the accepted values of the 'ttl_unit' parameter
+var ttlUnits = map[string]time.Duration{
+	"ms": time.Millisecond,
+	"s":  time.Second,
+}
+
+// parseTTL reads the 'ttl' parameter, falling back to defaultTTL when absent.
+// A bare number (e.g. ttl=50) requires 'ttl_unit' (ms or s); a duration string (e.g. ttl=50ms)
+// is accepted as is, and rejected if 'ttl_unit' names a different unit.
+func parseTTL(query url.Values, defaultTTL string) (time.Duration, error) {
+	ttl := strings.TrimSpace(query.Get("ttl"))
+	unit := strings.TrimSpace(query.Get("ttl_unit"))
+
+	unitDuration, ok := ttlUnits[unit]
+	if unit != "" && !ok {
+		return 0, fmt.Errorf("unsupported 'ttl_unit' %q, use ms or s", unit)
+	}
+
+	if ttl == "" {
+		ttl = defaultTTL
+	}
+
+	// Bare number: the unit must be explicit
+	if number, err := strconv.ParseFloat(ttl, 64); err == nil {
+		if unit == "" {
+			return 0, errors.New("numeric 'ttl' requires 'ttl_unit' (ms or s)")
+		}
+		return positiveTTL(time.Duration(number * float64(unitDuration)))
+	}
+
+	duration, err := time.ParseDuration(ttl)
+	if err != nil {
+		return 0, err
+	}
+
+	if unit != "" && strings.TrimLeft(ttl, "0123456789.+-") != unit {
+		return 0, fmt.Errorf("'ttl' %q contradicts 'ttl_unit' %q", ttl, unit)
+	}
+
+	return positiveTTL(duration)
+}
+
+// positiveTTL rejects zero and negative TTLs, which Redis would either refuse or turn into a lock that never expires
+func positiveTTL(ttl time.Duration) (time.Duration, error) {
+	if ttl <= 0 {
+		return 0, errors.New("'ttl' must be greater than zero")
+	}
+	return ttl, nil
+}
+
+// checkMinTTL rejects a lock TTL below the configured minimum. A TTL shorter than the time needed to
+// reach the quorum expires before the lock is even confirmed.
+func (l *lockerHandler) checkMinTTL(ttl time.Duration) error {
+	if ttl < l.minTTL {
+		return fmt.Errorf("'ttl' %s is below the minimum of %s", ttl, l.minTTL)
+	}
+	return nil
+}
+
+// Ways of enforcing the maximum TTL, see CapTTL
+const (
+	MaxTTLClamp  = "clamp"  // Grant the maximum instead of a longer TTL
+	MaxTTLReject = "reject" // Answer 400 to a longer TTL
+)
+
+// ParseMaxTTLPolicy reports whether TTLs above the maximum are clamped (MaxTTLClamp) rather than
+// rejected (MaxTTLReject). An empty policy clamps.
+func ParseMaxTTLPolicy(policy string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(policy)) {
+	case "", MaxTTLClamp:
+		return true, nil
+	case MaxTTLReject:
+		return false, nil
+	default:
+		return false, fmt.Errorf("unknown max TTL policy '%s', expected '%s' or '%s'", policy, MaxTTLClamp, MaxTTLReject)
+	}
+}
+
+// CapTTL enforces maxTTL on a lock TTL, so a client that crashes can't keep a resource for hours. A
+// longer TTL is lowered to maxTTL when clamp is set, and rejected otherwise. A zero maxTTL disables the cap.
+func CapTTL(ttl time.Duration, maxTTL time.Duration, clamp bool) (time.Duration, error) {
+	if maxTTL <= 0 || ttl <= maxTTL {
+		return ttl, nil
+	}
+	if clamp {
+		return maxTTL, nil
+	}
+	return 0, fmt.Errorf("'ttl' %s is above the maximum of %s", ttl, maxTTL)
+}
+
+// capTTL applies the handler's maximum TTL, see CapTTL
+func (l *lockerHandler) capTTL(ttl time.Duration) (time.Duration, error) {
+	return CapTTL(ttl, l.maxTTL, l.clampTTL)
+}
+
+// maxTTLApplied is the cap to report in a response, empty unless the requested TTL was clamped to it
+func maxTTLApplied(requested time.Duration, granted time.Duration) string {
+	if granted < requested {
+		return granted.String()
+	}
+	return ""
+}
+
+// checkResource rejects resource names unfit to become Redis keys, see locker.ValidateResource. The
+// canonical name is checked, since it is the one that becomes the key.
+func (l *lockerHandler) checkResource(resource string) error {
+	if l.canonicalize != nil {
+		resource = l.canonicalize(resource)
+	}
+	return locker.ValidateResource(resource, l.maxResourceLength)
+}
+
+// maxBodyParamsSize bounds the JSON body accepted by withBodyParams
+const maxBodyParamsSize = 64 << 10
+
+// withBodyParams lets a request carry its parameters (resource, token, ttl, ...) in a JSON body with
+// Content-Type: application/json, keeping them out of URLs and therefore out of access and proxy logs.
+// The body fields are merged over the query parameters of a copy of the request. On an invalid body
+// it answers 400 and returns false.
+func withBodyParams(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	mediaType, _, _ := strings.Cut(r.Header.Get("Content-Type"), ";")
+	if !strings.EqualFold(strings.TrimSpace(mediaType), "application/json") || r.ContentLength == 0 {
+		return r, true
+	}
+
+	fields := make(map[string]json.RawMessage)
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyParamsSize)).Decode(&fields); err != nil {
+		jsonError(w, fmt.Sprintf("invalid JSON body: %v", err), http.StatusBadRequest)
+		return r, false
+	}
+
+	query := r.URL.Query()
+	for name, raw := range fields {
+		value, err := bodyParamValue(raw)
+		if err != nil {
+			jsonError(w, fmt.Sprintf("invalid JSON body field '%s': %v", name, err), http.StatusBadRequest)
+			return r, false
+		}
+		query.Set(name, value)
+	}
+
+	clone := r.Clone(r.Context())
+	clone.URL.RawQuery = query.Encode()
+	auth.SetResource(clone.Context(), query.Get("resource"))
+	return clone, true
+}
+
+// bodyParamValue renders a JSON string, number or boolean as the equivalent query parameter value
+func bodyParamValue(raw json.RawMessage) (string, error) {
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", err
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case float64, bool:
+		return string(raw), nil
+	default:
+		return "", errors.New("must be a string, number or boolean")
+	}
+}
+
+// validity formats the time a freshly acquired lock can safely be relied on, at millisecond precision
+func validity(lock *locker.Locker) string {
+	return lock.Validity.Truncate(time.Millisecond).String()
+}
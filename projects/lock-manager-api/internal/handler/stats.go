@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"github.com/Waelson/lock-manager-service/lock-manager-api/internal/locker"
+	"github.com/Waelson/lock-manager-service/lock-manager-api/internal/metrics"
+	"golang.org/x/net/context"
+	"net/http"
+)
+
+type StatsResponse struct {
+	Latency     map[string]metrics.LatencySummary `json:"latency"`
+	HeldLocks   map[string]int                    `json:"held_locks,omitempty"`
+	Consistency *locker.ConsistencyStats          `json:"consistency,omitempty"`
+}
+
+type statsHandler struct {
+	latency     metrics.LatencyTracker
+	quota       locker.QuotaStore
+	consistency locker.ConsistencyChecker
+}
+
+type StatsHandler interface {
+	SummaryHandler(w http.ResponseWriter, r *http.Request)
+	LatencyHandler(w http.ResponseWriter, r *http.Request)
+}
+
+// NewStatsHandler creates the stats endpoints; quota and consistency may be nil when clients are not
+// capped or the split-brain checker is disabled
+func NewStatsHandler(latency metrics.LatencyTracker, quota locker.QuotaStore, consistency locker.ConsistencyChecker) StatsHandler {
+	return &statsHandler{latency: latency, quota: quota, consistency: consistency}
+}
+
+// SummaryHandler returns the endpoint latencies and, when enabled, the locks held by each client and
+// the split-brain checker counters
+func (s *statsHandler) SummaryHandler(w http.ResponseWriter, r *http.Request) {
+	response := StatsResponse{Latency: s.latency.Snapshot()}
+
+	if s.quota != nil {
+		ctx, cancel := context.WithTimeout(r.Context(), RequestTimeout)
+		defer cancel()
+
+		counts, err := s.quota.Counts(ctx)
+		if err != nil {
+			jsonError(w, "internal error while counting held locks", http.StatusInternalServerError)
+			return
+		}
+		response.HeldLocks = counts
+	}
+
+	if s.consistency != nil {
+		stats := s.consistency.Stats()
+		response.Consistency = &stats
+	}
+
+	jsonResponse(w, response, http.StatusOK)
+}
+
+// LatencyHandler returns the p50/p90/p99 latencies of each lock endpoint over the rolling window
+func (s *statsHandler) LatencyHandler(w http.ResponseWriter, r *http.Request) {
+	jsonResponse(w, s.latency.Snapshot(), http.StatusOK)
+}
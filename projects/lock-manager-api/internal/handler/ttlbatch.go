@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/Waelson/lock-manager-service/lock-manager-api/internal/locker"
+	"golang.org/x/net/context"
+	"net/http"
+)
+
+// MaxTTLBatch bounds how many locks a single /ttl/batch request may query
+const MaxTTLBatch = 100
+
+type TTLBatchResult struct {
+	Resource string `json:"resource"`
+	Found    bool   `json:"found"`
+	Ttl      string `json:"ttl"`
+	TtlMs    int64  `json:"ttl_ms"`
+}
+
+type TTLBatchResponse struct {
+	Code    int              `json:"code"`
+	Results []TTLBatchResult `json:"results"`
+}
+
+// TTLBatchHandler checks the remaining TTL of every lock in a JSON array of {resource, token}, answering
+// one result per entry, in the same order. A lock that expired or isn't held with its token is reported
+// with found false.
+func (l *lockerHandler) TTLBatchHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), RequestTimeout)
+	defer cancel()
+
+	var items []locker.TTLQuery
+	if err := json.NewDecoder(r.Body).Decode(&items); err != nil {
+		jsonError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if len(items) == 0 {
+		jsonError(w, "empty request body", http.StatusBadRequest)
+		return
+	}
+	if len(items) > MaxTTLBatch {
+		jsonError(w, fmt.Sprintf("at most %d locks per request", MaxTTLBatch), http.StatusBadRequest)
+		return
+	}
+
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		if item.Resource == "" || item.Token == "" {
+			jsonError(w, "every entry needs a 'resource' and a 'token'", http.StatusBadRequest)
+			return
+		}
+		if seen[item.Resource] {
+			jsonError(w, "duplicate resource in request body", http.StatusBadRequest)
+			return
+		}
+		seen[item.Resource] = true
+	}
+
+	ttls, err := l.redlock.TTLMulti(ctx, items)
+	if err != nil {
+		jsonError(w, "internal error while checking TTLs", http.StatusInternalServerError)
+		return
+	}
+
+	results := make([]TTLBatchResult, 0, len(items))
+	for _, item := range items {
+		ttl, found := ttls[item.Resource]
+		results = append(results, TTLBatchResult{
+			Resource: item.Resource,
+			Found:    found,
+			Ttl:      ttl.String(),
+			TtlMs:    ttl.Milliseconds(),
+		})
+	}
+
+	jsonResponse(w, TTLBatchResponse{Code: http.StatusOK, Results: results}, http.StatusOK)
+}
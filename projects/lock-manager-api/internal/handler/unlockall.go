@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"errors"
+	"github.com/Waelson/lock-manager-service/lock-manager-api/internal/locker"
+	auth "github.com/Waelson/lock-manager-service/lock-manager-api/internal/middleware"
+	"golang.org/x/net/context"
+	"log"
+	"net/http"
+)
+
+type ReleaseByTokenResponse struct {
+	Code     int    `json:"code"`
+	Token    string `json:"token"`
+	Released int    `json:"released"`
+}
+
+// ReleaseByTokenHandler releases every lock held with 'token', to clean up after a worker that died
+// holding locks it can no longer release one by one
+func (l *lockerHandler) ReleaseByTokenHandler(w http.ResponseWriter, r *http.Request) {
+	r, ok := withBodyParams(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), RequestTimeout)
+	defer cancel()
+
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		jsonError(w, "missing 'token' parameter", http.StatusBadRequest)
+		return
+	}
+
+	nonce, ok := l.checkNonce(ctx, w, r, "", token)
+	if !ok {
+		return
+	}
+
+	released, err := l.redlock.ReleaseByToken(ctx, token)
+	if err != nil {
+		l.forgetNonce(ctx, nonce)
+		if errors.Is(err, locker.InternalError) {
+			jsonError(w, "internal error while releasing locks", http.StatusInternalServerError)
+		} else {
+			jsonError(w, "unexpected error while releasing locks", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	l.forgetDecision(token)
+
+	// None of the locks held with the token count toward the client's quota any longer
+	if l.quota != nil {
+		if quotaErr := l.quota.ReleaseToken(ctx, auth.ClientID(r), token); quotaErr != nil {
+			log.Printf("error releasing quota entry of client '%s': %v\n", auth.ClientID(r), quotaErr)
+		}
+	}
+
+	jsonResponse(w, ReleaseByTokenResponse{
+		Code:     http.StatusOK,
+		Token:    token,
+		Released: released,
+	}, http.StatusOK)
+}
@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Waelson/lock-manager-service/lock-manager-api/internal/locker"
+	"golang.org/x/net/context"
+	"log"
+	"math/rand"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// MaxWait bounds the 'wait' parameter so a request can't pin a server goroutine indefinitely
+const MaxWait = 30 * time.Second
+
+// Backoff between server-side acquire attempts, the same defaults the SDK uses
+const (
+	waitInitialBackoff = 100 * time.Millisecond
+	waitMaxBackoff     = 5 * time.Second
+	waitMaxJitter      = 500 * time.Millisecond
+)
+
+// fairPollInterval is how often a fair acquisition checks whether its turn came, when not woken up earlier
+const fairPollInterval = 100 * time.Millisecond
+
+// parseWait reads the optional 'wait' duration; zero means fail immediately on conflict
+func parseWait(query url.Values) (time.Duration, error) {
+	value := strings.TrimSpace(query.Get("wait"))
+	if value == "" {
+		return 0, nil
+	}
+
+	wait, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if wait < 0 || wait > MaxWait {
+		return 0, fmt.Errorf("'wait' must be between 0s and %s", MaxWait)
+	}
+	return wait, nil
+}
+
+// acquireWaiting retries a conflicting acquisition with exponential backoff until wait elapses, waking
+// up early when the release notifier reports the lock key is gone. Other errors end the loop at once,
+// and so does ctx, in which case the last conflict is returned.
+func (l *lockerHandler) acquireWaiting(ctx context.Context, acquire acquireFunc, resource string, ttl time.Duration, wait time.Duration) (*locker.Locker, error) {
+	deadline := time.Now().Add(wait)
+	backoff := waitInitialBackoff
+
+	for {
+		// Subscribe before the attempt so a release right after the conflict isn't missed
+		var released <-chan struct{}
+		unsubscribe := func() {}
+		if l.notifier != nil && wait > 0 {
+			released, unsubscribe = l.notifier.Subscribe(resource)
+		}
+
+		lock, err := acquire(ctx, resource, ttl)
+		remaining := time.Until(deadline)
+		if err == nil || !errors.Is(err, locker.AcquireLockError) || remaining <= 0 {
+			unsubscribe()
+			return lock, err
+		}
+
+		delay := backoff + time.Duration(rand.Int63n(int64(waitMaxJitter)))
+		if delay > remaining {
+			delay = remaining
+		}
+		backoff = min(backoff*2, waitMaxBackoff)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			unsubscribe()
+			return nil, err
+		case <-released:
+		case <-timer.C:
+		}
+		timer.Stop()
+		unsubscribe()
+	}
+}
+
+// acquireFair queues the acquisition behind the ones that arrived first and only attempts it once its
+// ticket reaches the head of the queue, so waiters are granted the lock in arrival order rather than by
+// luck. It waits up to wait like acquireWaiting; the ticket leaves the queue however the wait ends.
+// Acquisitions made without fair=true don't queue and may still take the lock first.
+func (l *lockerHandler) acquireFair(ctx context.Context, acquire acquireFunc, resource string, ttl time.Duration, wait time.Duration) (*locker.Locker, error) {
+	ticket, err := l.fairQueue.Join(ctx, resource, RequestTimeout+wait)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		// Leave even when the request was cancelled, or the waiters behind would stall until the ticket expires
+		leaveCtx, cancel := context.WithTimeout(context.Background(), RequestTimeout)
+		defer cancel()
+		if err := l.fairQueue.Leave(leaveCtx, resource, ticket); err != nil {
+			log.Printf("error leaving the fair queue of '%s': %v\n", resource, err)
+		}
+	}()
+
+	deadline := time.Now().Add(wait)
+	var conflict error = &locker.ConflictError{Resource: resource} // Waiters are queued ahead
+
+	for {
+		// Subscribe before checking so a release right after it isn't missed
+		var released <-chan struct{}
+		unsubscribe := func() {}
+		if l.notifier != nil && wait > 0 {
+			released, unsubscribe = l.notifier.Subscribe(resource)
+		}
+
+		next, err := l.fairQueue.IsNext(ctx, resource, ticket)
+		if err != nil {
+			unsubscribe()
+			return nil, err
+		}
+		if next {
+			lock, err := acquire(ctx, resource, ttl)
+			if err == nil || !errors.Is(err, locker.AcquireLockError) {
+				unsubscribe()
+				return lock, err
+			}
+			conflict = err
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			unsubscribe()
+			return nil, conflict
+		}
+
+		timer := time.NewTimer(min(fairPollInterval, remaining))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			unsubscribe()
+			return nil, conflict
+		case <-released:
+		case <-timer.C:
+		}
+		timer.Stop()
+		unsubscribe()
+	}
+}
@@ -0,0 +1,92 @@
+package locker
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/net/context"
+	"sync"
+	"time"
+)
+
+// maxAnyParallelism bounds how many resources AcquireAnyN tries to lock at the same time
+const maxAnyParallelism = 16
+
+// AcquireAnyN tries to lock every resource and succeeds once n of them are acquired, releasing any
+// lock obtained beyond n. Useful for capacity-style locking over interchangeable resources. When fewer
+// than n are acquired because of anything other than a conflict (e.g. QuorumUnavailableError), that error
+// is returned instead of AcquireLockError.
+func (l *redLock) AcquireAnyN(ctx context.Context, resources []string, n int, ttl time.Duration) ([]*Locker, error) {
+	if n <= 0 || n > len(resources) {
+		return nil, fmt.Errorf("n must be between 1 and %d", len(resources))
+	}
+	for _, resource := range resources {
+		if err := l.validResource(resource); err != nil {
+			return nil, err
+		}
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var failure error
+	acquired := make([]*Locker, len(resources))
+	slots := make(chan struct{}, maxAnyParallelism)
+
+	// Parallelize the acquisition of each resource, a bounded number at a time
+	for i, resource := range resources {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, resource string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			lock, err := l.Acquire(ctx, resource, ttl)
+			if err != nil {
+				if !errors.Is(err, AcquireLockError) {
+					mu.Lock()
+					if failure == nil {
+						failure = err
+					}
+					mu.Unlock()
+				}
+				return
+			}
+			acquired[i] = lock
+		}(i, resource)
+	}
+
+	wg.Wait()
+
+	// Keep the first n acquired locks, in the order the resources were given
+	kept := make([]*Locker, 0, n)
+	extras := make([]*Locker, 0)
+	for _, lock := range acquired {
+		if lock == nil {
+			continue
+		}
+		if len(kept) < n {
+			kept = append(kept, lock)
+		} else {
+			extras = append(extras, lock)
+		}
+	}
+
+	if len(kept) < n {
+		extras = append(extras, kept...)
+		kept = nil
+	}
+
+	for _, lock := range extras {
+		if _, err := l.releaseKey(ctx, l.namespace+lock.Resource, lock.Token); err != nil {
+			l.logger.Warn("error releasing extra lock", "resource", lock.Resource, "token", lock.Token, "error", err)
+		}
+	}
+
+	if kept == nil {
+		if failure != nil {
+			return nil, failure
+		}
+		return nil, AcquireLockError
+	}
+
+	return kept, nil
+}
@@ -0,0 +1,121 @@
+package locker
+
+import (
+	"errors"
+	"golang.org/x/net/context"
+	"testing"
+	"time"
+)
+
+func TestNewCanonicalizerAppliesRulesInOrder(t *testing.T) {
+	canonicalize, err := NewCanonicalizer("trim, lower, nfc")
+	if err != nil {
+		t.Fatalf("NewCanonicalizer: %v", err)
+	}
+
+	tests := map[string]string{
+		"  Item-42 ":     "item-42",
+		"ORDER":          "order",
+		"cafe\u0301":     "caf\u00e9", // A decomposed é becomes the composed one
+		"already-canon":  "already-canon",
+		"\tTabbed\tName": "tabbed\tname",
+	}
+	for in, want := range tests {
+		if got := canonicalize(in); got != want {
+			t.Errorf("canonicalize(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestNewCanonicalizerWithoutRules(t *testing.T) {
+	canonicalize, err := NewCanonicalizer(" , ")
+	if err != nil {
+		t.Fatalf("NewCanonicalizer: %v", err)
+	}
+	if canonicalize != nil {
+		t.Error("an empty rule list must keep resource names as given")
+	}
+}
+
+func TestNewCanonicalizerRejectsAnUnknownRule(t *testing.T) {
+	if _, err := NewCanonicalizer("trim,upper"); err == nil {
+		t.Error("the unknown rule 'upper' was accepted")
+	}
+}
+
+func TestAliasesShareOneLock(t *testing.T) {
+	canonicalize, _ := NewCanonicalizer("trim,lower")
+	l, servers := newTestLocker(t, 3, WithCanonicalizer(canonicalize))
+	ctx := context.Background()
+
+	lock, err := l.Acquire(ctx, "Item-42", time.Second)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	if lock.Resource != "item-42" {
+		t.Errorf("Resource = %q, want the canonical name", lock.Resource)
+	}
+	if !servers[0].Exists("item-42") {
+		t.Error("the lock isn't stored under the canonical name")
+	}
+
+	if _, err := l.Acquire(ctx, " ITEM-42", time.Second); !errors.Is(err, AcquireLockError) {
+		t.Errorf("Acquire of an alias error = %v, want AcquireLockError", err)
+	}
+	if err := l.Release(ctx, "item-42 ", lock.Token); err != nil {
+		t.Errorf("Release through an alias: %v", err)
+	}
+}
+
+func TestNamespaceIsolatesTheKeys(t *testing.T) {
+	l, servers := newTestLocker(t, 3, WithNamespace("tenant-a"))
+	ctx := context.Background()
+
+	lock, err := l.Acquire(ctx, "item-42", time.Second)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	if lock.Resource != "item-42" {
+		t.Errorf("Resource = %q, want it reported without the namespace", lock.Resource)
+	}
+	if !servers[0].Exists("tenant-a:item-42") || servers[0].Exists("item-42") {
+		t.Error("the lock isn't stored under the namespaced key")
+	}
+}
+
+func TestNamespacedCanonicalizer(t *testing.T) {
+	canonicalize, _ := NewCanonicalizer("lower")
+	if got := NamespacedCanonicalizer("tenant-a", canonicalize)("Item"); got != "tenant-a:item" {
+		t.Errorf("namespaced canonical name = %q, want %q", got, "tenant-a:item")
+	}
+	if NamespacedCanonicalizer("", nil) != nil {
+		t.Error("no namespace and no canonicalizer must stay a no-op")
+	}
+}
+
+func TestReservedPrefixIsCheckedOnTheCanonicalName(t *testing.T) {
+	canonicalize, _ := NewCanonicalizer("trim")
+	l, _ := newTestLocker(t, 3, WithCanonicalizer(canonicalize))
+
+	// Accepted as given, but trimmed into the key of a fencing counter
+	_, err := l.Acquire(context.Background(), " "+FenceKeyPrefix+"item-42", time.Second)
+	if !errors.Is(err, InvalidResourceError) {
+		t.Errorf("Acquire error = %v, want InvalidResourceError", err)
+	}
+}
+
+func TestValidateResource(t *testing.T) {
+	valid := []string{"item-42", "orders/2024/ß", "fencer"}
+	for _, resource := range valid {
+		if err := ValidateResource(resource, 32); err != nil {
+			t.Errorf("ValidateResource(%q): %v", resource, err)
+		}
+	}
+
+	invalid := []string{"", "line\nbreak", "\xff", "FENCE:item", "nonce:x", "a-name-longer-than-thirty-two-bytes"}
+	for _, resource := range invalid {
+		if err := ValidateResource(resource, 32); !errors.Is(err, InvalidResourceError) {
+			t.Errorf("ValidateResource(%q) error = %v, want InvalidResourceError", resource, err)
+		}
+	}
+}
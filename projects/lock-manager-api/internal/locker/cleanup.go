@@ -0,0 +1,80 @@
+package locker
+
+import (
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/net/context"
+	"sort"
+	"sync"
+)
+
+// ReleaseByToken releases every exclusive lock held with token, e.g. after the worker holding them
+// died, and returns how many were released. The lock keys of every node are scanned, so a lock left on
+// a minority of nodes is released there too, though only those freed on a quorum are counted.
+func (l *redLock) ReleaseByToken(ctx context.Context, token string) (int, error) {
+	if token == "" {
+		return 0, errors.New("token must not be empty")
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	resources := make(map[string]bool)
+	errs := make([]error, 0)
+	answered := 0 // Votes of the nodes scanned
+
+	// Parallelize the scan of each Redis node
+	for _, node := range l.redisNodes {
+		wg.Add(1)
+		go func(node *redis.Client) {
+			defer wg.Done()
+
+			nodeCtx, cancel := context.WithTimeout(ctx, l.nodeTimeout) // Timeout per node
+			defer cancel()
+
+			locks, err := scanLocks(nodeCtx, node, l.namespace)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("error scanning locks on node %v: %w", node.Options().Addr, err))
+				return
+			}
+			answered += l.weight(node)
+			for _, lock := range locks {
+				if lock.token == token {
+					resources[l.resourceName(lock.resource)] = true
+				}
+			}
+		}(node)
+	}
+
+	wg.Wait()
+
+	if len(errs) > 0 {
+		l.logger.Warn("errors while scanning locks", "token", token, "errors", errs)
+	}
+
+	// Not enough nodes answered to be sure every lock of the token was found
+	if answered < l.quorum {
+		return 0, InternalError
+	}
+
+	sorted := make([]string, 0, len(resources))
+	for resource := range resources {
+		sorted = append(sorted, resource)
+	}
+	sort.Strings(sorted)
+
+	released := 0
+	for _, resource := range sorted {
+		err := l.Release(ctx, resource, token)
+		if err == nil {
+			released++
+		} else if !errors.Is(err, LockNotFoundError) {
+			l.logger.Warn("error releasing lock by token", "resource", resource, "token", token, "error", err)
+		}
+	}
+
+	return released, nil
+}
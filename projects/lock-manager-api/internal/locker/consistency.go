@@ -0,0 +1,166 @@
+package locker
+
+import (
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/net/context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// maxTrackedResources bounds the resources the consistency checker follows at once
+const maxTrackedResources = 1024
+
+// ConsistencyStats summarizes what the consistency checker has observed so far
+type ConsistencyStats struct {
+	SampleRate            float64 `json:"sample_rate"`
+	Tracked               int     `json:"tracked"`
+	Checks                int64   `json:"checks"`
+	Divergent             int64   `json:"divergent"`
+	LastDivergentResource string  `json:"last_divergent_resource,omitempty"`
+}
+
+type consistencyChecker struct {
+	nodes        *NodeSet
+	canonicalize Canonicalizer
+	sampleRate   float64
+	interval     time.Duration
+
+	mu        sync.Mutex
+	tracked   map[string]bool // resource -> divergence seen on the previous pass
+	checks    int64
+	divergent int64
+	last      string
+}
+
+type ConsistencyChecker interface {
+	Sample(resource string)
+	Start(ctx context.Context)
+	Stats() ConsistencyStats
+}
+
+// NewConsistencyChecker creates a checker that follows sampleRate (0..1) of the acquired resources and,
+// every interval, reads their token from all nodes looking for nodes holding different tokens.
+// canonicalize maps a resource to its key, like for NewReleaseNotifier, and may be nil.
+func NewConsistencyChecker(nodes *NodeSet, canonicalize Canonicalizer, sampleRate float64, interval time.Duration) ConsistencyChecker {
+	return &consistencyChecker{
+		nodes:        nodes,
+		canonicalize: canonicalize,
+		sampleRate:   sampleRate,
+		interval:     interval,
+		tracked:      make(map[string]bool),
+	}
+}
+
+// Sample starts following resource with probability sampleRate
+func (c *consistencyChecker) Sample(resource string) {
+	if rand.Float64() >= c.sampleRate {
+		return
+	}
+	if c.canonicalize != nil {
+		resource = c.canonicalize(resource)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.tracked[resource]; !exists && len(c.tracked) < maxTrackedResources {
+		c.tracked[resource] = false
+	}
+}
+
+// Start checks the followed resources every interval until ctx is done
+func (c *consistencyChecker) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				c.checkAll(ctx)
+			}
+		}
+	}()
+}
+
+func (c *consistencyChecker) Stats() ConsistencyStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return ConsistencyStats{
+		SampleRate:            c.sampleRate,
+		Tracked:               len(c.tracked),
+		Checks:                c.checks,
+		Divergent:             c.divergent,
+		LastDivergentResource: c.last,
+	}
+}
+
+func (c *consistencyChecker) checkAll(ctx context.Context) {
+	c.mu.Lock()
+	resources := make([]string, 0, len(c.tracked))
+	for resource := range c.tracked {
+		resources = append(resources, resource)
+	}
+	c.mu.Unlock()
+
+	for _, resource := range resources {
+		tokens, gone := c.readTokens(ctx, resource)
+		c.record(resource, tokens, gone)
+	}
+}
+
+// readTokens returns the token each answering node holds for resource, and whether the key is gone everywhere
+func (c *consistencyChecker) readTokens(ctx context.Context, resource string) (map[string]string, bool) {
+	var mu sync.Mutex
+	tokens := make(map[string]string)
+
+	_, failed := c.nodes.run(ctx, "checking consistency", func(ctx context.Context, node *redis.Client) (bool, error) {
+		token, err := node.Get(ctx, resource).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return false, err
+		}
+		if token != "" {
+			mu.Lock()
+			tokens[node.Options().Addr] = token
+			mu.Unlock()
+		}
+		return true, nil
+	})
+
+	return tokens, failed == 0 && len(tokens) == 0
+}
+
+// record reports a divergence only when it shows up on two consecutive passes, since a contended
+// acquisition briefly leaves the loser's token on some nodes until it releases them
+func (c *consistencyChecker) record(resource string, tokens map[string]string, gone bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.checks++
+	if gone {
+		delete(c.tracked, resource)
+		return
+	}
+
+	distinct := make(map[string]struct{})
+	for _, token := range tokens {
+		distinct[token] = struct{}{}
+	}
+
+	if len(distinct) <= 1 {
+		c.tracked[resource] = false
+		return
+	}
+
+	if !c.tracked[resource] {
+		c.tracked[resource] = true
+		return
+	}
+
+	c.divergent++
+	c.last = resource
+	c.nodes.logger.Error("split-brain suspected", "resource", resource, "tokens", len(distinct), "nodes", tokens)
+}
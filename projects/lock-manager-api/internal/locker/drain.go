@@ -0,0 +1,86 @@
+package locker
+
+import (
+	"errors"
+	"golang.org/x/net/context"
+	"sync/atomic"
+	"time"
+)
+
+var DrainingError = errors.New("lock manager is draining, new acquisitions are refused")
+
+type drainableLocker struct {
+	RedLocker
+	draining atomic.Bool
+}
+
+// DrainableLocker is a RedLocker that can be put in drain mode for maintenance: new acquisitions fail
+// with DrainingError while the locks already held can still be released, refreshed and queried
+type DrainableLocker interface {
+	RedLocker
+	Drain()
+	Undrain()
+	Draining() bool
+}
+
+// NewDrainableLocker wraps redlock with a drain switch, initially off
+func NewDrainableLocker(redlock RedLocker) DrainableLocker {
+	return &drainableLocker{RedLocker: redlock}
+}
+
+// Drain starts refusing new acquisitions
+func (d *drainableLocker) Drain() {
+	d.draining.Store(true)
+}
+
+// Undrain accepts new acquisitions again
+func (d *drainableLocker) Undrain() {
+	d.draining.Store(false)
+}
+
+// Draining reports whether new acquisitions are refused
+func (d *drainableLocker) Draining() bool {
+	return d.draining.Load()
+}
+
+func (d *drainableLocker) Acquire(ctx context.Context, resource string, ttl time.Duration) (*Locker, error) {
+	if d.Draining() {
+		return nil, DrainingError
+	}
+	return d.RedLocker.Acquire(ctx, resource, ttl)
+}
+
+func (d *drainableLocker) AcquireWithToken(ctx context.Context, resource string, token string, ttl time.Duration) (*Locker, error) {
+	if d.Draining() {
+		return nil, DrainingError
+	}
+	return d.RedLocker.AcquireWithToken(ctx, resource, token, ttl)
+}
+
+func (d *drainableLocker) AcquireAnyN(ctx context.Context, resources []string, n int, ttl time.Duration) ([]*Locker, error) {
+	if d.Draining() {
+		return nil, DrainingError
+	}
+	return d.RedLocker.AcquireAnyN(ctx, resources, n, ttl)
+}
+
+func (d *drainableLocker) AcquireMulti(ctx context.Context, resources []string, ttl time.Duration) ([]*Locker, error) {
+	if d.Draining() {
+		return nil, DrainingError
+	}
+	return d.RedLocker.AcquireMulti(ctx, resources, ttl)
+}
+
+func (d *drainableLocker) AcquireShared(ctx context.Context, resource string, ttl time.Duration) (*Locker, error) {
+	if d.Draining() {
+		return nil, DrainingError
+	}
+	return d.RedLocker.AcquireShared(ctx, resource, ttl)
+}
+
+func (d *drainableLocker) AcquireReentrant(ctx context.Context, resource string, owner string, ttl time.Duration) (*Locker, error) {
+	if d.Draining() {
+		return nil, DrainingError
+	}
+	return d.RedLocker.AcquireReentrant(ctx, resource, owner, ttl)
+}
@@ -0,0 +1,53 @@
+package locker
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ConflictError reports an acquisition refused because another client holds the resource.
+// HeldFor estimates how long it stays held, zero when unknown. errors.Is(err, AcquireLockError) holds for it.
+type ConflictError struct {
+	Resource string
+	HeldFor  time.Duration
+}
+
+func (e *ConflictError) Error() string {
+	return AcquireLockError.Error()
+}
+
+func (e *ConflictError) Unwrap() error {
+	return AcquireLockError
+}
+
+// NodeRelease is the outcome of a release on a single node
+type NodeRelease struct {
+	Addr     string
+	Released bool  // The node held the token and deleted it
+	Err      error // Set when the node could not be reached or failed the command
+}
+
+// ReleaseError reports a release that failed on some nodes. ReachedQuorum tells whether the lock is
+// nevertheless gone from a quorum of nodes (released there or already absent), in which case no one
+// else can be denied the resource because of it. errors.Is(err, InternalError) holds for it.
+type ReleaseError struct {
+	Resource      string
+	Nodes         []NodeRelease
+	ReachedQuorum bool
+}
+
+func (e *ReleaseError) Error() string {
+	failed := make([]string, 0)
+	for _, node := range e.Nodes {
+		if node.Err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", node.Addr, node.Err))
+		}
+	}
+	return fmt.Sprintf("release of '%s' failed on %d of %d nodes (quorum cleared: %t): %s",
+		e.Resource, len(failed), len(e.Nodes), e.ReachedQuorum, strings.Join(failed, "; "))
+}
+
+func (e *ReleaseError) Unwrap() error {
+	return InternalError
+}
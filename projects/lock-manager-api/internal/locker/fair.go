@@ -0,0 +1,125 @@
+package locker
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/net/context"
+	"strconv"
+	"time"
+)
+
+// FairQueueKeyPrefix namespaces the per-resource sorted sets of waiting tickets, scored by arrival (µs)
+const FairQueueKeyPrefix = "fairq:"
+
+// joinScript adds a ticket to the queue and keeps the queue alive at least until the ticket expires.
+// KEYS[1] = queue, ARGV[1] = arrival (µs), ARGV[2] = ticket, ARGV[3] = ticket expiry (ms from now)
+var joinScript = redis.NewScript(`
+redis.call("zadd", KEYS[1], ARGV[1], ARGV[2])
+if redis.call("pttl", KEYS[1]) < tonumber(ARGV[3]) then
+	redis.call("pexpire", KEYS[1], ARGV[3])
+end
+return 1
+`)
+
+// headScript drops the expired tickets at the head of the queue, left by waiters that died, and returns
+// the first live one, or false when the queue is empty. Tickets start with their expiry (ms).
+// KEYS[1] = queue, ARGV[1] = now (ms)
+var headScript = redis.NewScript(`
+while true do
+	local head = redis.call("zrange", KEYS[1], 0, 0)[1]
+	if not head then
+		return false
+	end
+	local expiry = tonumber(string.match(head, "^(%d+):"))
+	if expiry and expiry > tonumber(ARGV[1]) then
+		return head
+	end
+	redis.call("zrem", KEYS[1], head)
+end
+`)
+
+type fairQueue struct {
+	nodes        *NodeSet
+	canonicalize Canonicalizer
+}
+
+// FairQueue orders the clients waiting for a resource by arrival, so they can be granted the lock one
+// after the other instead of racing for it whenever it frees
+type FairQueue interface {
+	Join(ctx context.Context, resource string, expiry time.Duration) (string, error)
+	IsNext(ctx context.Context, resource string, ticket string) (bool, error)
+	Leave(ctx context.Context, resource string, ticket string) error
+}
+
+// NewFairQueue creates a Redis-backed queue kept on every node. Each ticket is written with the same
+// arrival score everywhere, so the nodes agree on the order and a node that missed a ticket is outvoted.
+// canonicalize must be the one given to the locker, or nil, so every spelling of a resource shares a queue.
+func NewFairQueue(nodes *NodeSet, canonicalize Canonicalizer) FairQueue {
+	return &fairQueue{
+		nodes:        nodes,
+		canonicalize: canonicalize,
+	}
+}
+
+// key returns the queue key of a resource
+func (q *fairQueue) key(resource string) string {
+	if q.canonicalize != nil {
+		resource = q.canonicalize(resource)
+	}
+	return FairQueueKeyPrefix + resource
+}
+
+// Join appends a new ticket to the resource's queue and returns it. The ticket is dropped once expiry
+// elapses even if Leave is never called, so a waiter that died doesn't block the queue for good.
+func (q *fairQueue) Join(ctx context.Context, resource string, expiry time.Duration) (string, error) {
+	now := time.Now()
+	ticket := strconv.FormatInt(now.Add(expiry).UnixMilli(), 10) + ":" + uuid.New().String()
+	key := q.key(resource)
+
+	joined, _ := q.nodes.run(ctx, "joining fair queue", func(ctx context.Context, node *redis.Client) (bool, error) {
+		return true, joinScript.Run(ctx, node, []string{key}, now.UnixMicro(), ticket, expiry.Milliseconds()).Err()
+	})
+	if joined < q.nodes.quorum {
+		_ = q.Leave(ctx, resource, ticket)
+		return "", InternalError
+	}
+	return ticket, nil
+}
+
+// IsNext reports whether ticket is at the head of the resource's queue on a quorum of nodes
+func (q *fairQueue) IsNext(ctx context.Context, resource string, ticket string) (bool, error) {
+	key := q.key(resource)
+
+	heads, failed := q.nodes.run(ctx, "reading fair queue", func(ctx context.Context, node *redis.Client) (bool, error) {
+		head, err := headScript.Run(ctx, node, []string{key}, time.Now().UnixMilli()).Text()
+		if errors.Is(err, redis.Nil) {
+			return false, nil // Empty queue
+		} else if err != nil {
+			return false, err
+		}
+		return head == ticket, nil
+	})
+
+	if heads >= q.nodes.quorum {
+		return true, nil
+	}
+	// Not enough nodes answered to tell whose turn it is
+	if !q.nodes.answered(failed) {
+		return false, InternalError
+	}
+	return false, nil
+}
+
+// Leave removes the ticket from the resource's queue, letting the next waiter in
+func (q *fairQueue) Leave(ctx context.Context, resource string, ticket string) error {
+	key := q.key(resource)
+
+	left, _ := q.nodes.run(ctx, "leaving fair queue", func(ctx context.Context, node *redis.Client) (bool, error) {
+		return true, node.ZRem(ctx, key, ticket).Err()
+	})
+	if left < q.nodes.quorum {
+		return InternalError
+	}
+	return nil
+}
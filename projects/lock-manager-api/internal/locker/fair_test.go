@@ -0,0 +1,105 @@
+package locker
+
+import (
+	"errors"
+	"golang.org/x/net/context"
+	"testing"
+	"time"
+)
+
+func TestFairQueueServesTicketsByArrival(t *testing.T) {
+	nodes, _ := newTestNodeSet(t, 3)
+	queue := NewFairQueue(nodes, nil)
+	ctx := context.Background()
+
+	first, err := queue.Join(ctx, "item-1", time.Minute)
+	if err != nil {
+		t.Fatalf("Join: %v", err)
+	}
+	time.Sleep(time.Millisecond) // Arrivals are scored in microseconds
+	second, err := queue.Join(ctx, "item-1", time.Minute)
+	if err != nil {
+		t.Fatalf("Join: %v", err)
+	}
+
+	assertNext(t, queue, "item-1", first, true)
+	assertNext(t, queue, "item-1", second, false)
+
+	if err := queue.Leave(ctx, "item-1", first); err != nil {
+		t.Fatalf("Leave: %v", err)
+	}
+	assertNext(t, queue, "item-1", second, true)
+}
+
+func TestFairQueueSkipsExpiredTickets(t *testing.T) {
+	nodes, _ := newTestNodeSet(t, 3)
+	queue := NewFairQueue(nodes, nil)
+	ctx := context.Background()
+
+	// A waiter that died without leaving
+	if _, err := queue.Join(ctx, "item-1", 20*time.Millisecond); err != nil {
+		t.Fatalf("Join: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+	second, err := queue.Join(ctx, "item-1", time.Minute)
+	if err != nil {
+		t.Fatalf("Join: %v", err)
+	}
+	assertNext(t, queue, "item-1", second, false)
+
+	time.Sleep(30 * time.Millisecond)
+	assertNext(t, queue, "item-1", second, true)
+}
+
+func TestFairQueueIsSharedByTheAliasesOfAResource(t *testing.T) {
+	canonicalize, _ := NewCanonicalizer("lower")
+	nodes, _ := newTestNodeSet(t, 3)
+	queue := NewFairQueue(nodes, canonicalize)
+	ctx := context.Background()
+
+	first, err := queue.Join(ctx, "Item-1", time.Minute)
+	if err != nil {
+		t.Fatalf("Join: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+	second, err := queue.Join(ctx, "ITEM-1", time.Minute)
+	if err != nil {
+		t.Fatalf("Join: %v", err)
+	}
+
+	assertNext(t, queue, "item-1", first, true)
+	assertNext(t, queue, "item-1", second, false)
+}
+
+func TestFairQueueNeedsAQuorum(t *testing.T) {
+	nodes, servers := newTestNodeSet(t, 3)
+	queue := NewFairQueue(nodes, nil)
+	ctx := context.Background()
+
+	ticket, err := queue.Join(ctx, "item-1", time.Minute)
+	if err != nil {
+		t.Fatalf("Join: %v", err)
+	}
+	servers[1].SetError("node down")
+	servers[2].SetError("node down")
+
+	if _, err := queue.IsNext(ctx, "item-1", ticket); !errors.Is(err, InternalError) {
+		t.Errorf("IsNext without a quorum error = %v, want InternalError", err)
+	}
+	if _, err := queue.Join(ctx, "item-1", time.Minute); !errors.Is(err, InternalError) {
+		t.Errorf("Join without a quorum error = %v, want InternalError", err)
+	}
+}
+
+// assertNext checks whether ticket is at the head of the resource's queue
+func assertNext(t *testing.T, queue FairQueue, resource string, ticket string, want bool) {
+	t.Helper()
+
+	next, err := queue.IsNext(context.Background(), resource, ticket)
+	if err != nil {
+		t.Fatalf("IsNext: %v", err)
+	}
+	if next != want {
+		t.Errorf("IsNext(%s) = %t, want %t", ticket, next, want)
+	}
+}
@@ -0,0 +1,155 @@
+package locker
+
+import (
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/net/context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// listScanCount is the SCAN page size used when listing locks
+const listScanCount = 500
+
+// internalKeyPrefixes are the string keys the service stores next to the locks, never listed as locks
+var internalKeyPrefixes = []string{NonceKeyPrefix, FenceKeyPrefix}
+
+// LockInfo describes an active lock as seen by a quorum of nodes
+type LockInfo struct {
+	Resource string        `json:"resource"`
+	Token    string        `json:"token"`
+	Ttl      time.Duration `json:"-"`
+	TtlMs    int64         `json:"ttl_ms"`
+	Nodes    int           `json:"nodes"`
+
+	votes int // Weight of the nodes holding it
+}
+
+type nodeLock struct {
+	resource string
+	token    string
+	ttl      time.Duration
+}
+
+// List returns the exclusive locks whose resource starts with prefix and that are held with the same
+// token by a quorum of nodes, sorted by resource. The reported TTL is the lowest among those nodes.
+func (l *redLock) List(ctx context.Context, prefix string) ([]LockInfo, error) {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	seen := make(map[string]map[string]*LockInfo) // resource -> token -> lock
+	errs := make([]error, 0)
+	answered := 0 // Votes of the nodes scanned
+
+	// Parallelize the scan of each Redis node
+	for _, node := range l.redisNodes {
+		wg.Add(1)
+		go func(node *redis.Client) {
+			defer wg.Done()
+
+			nodeCtx, cancel := context.WithTimeout(ctx, l.nodeTimeout) // Timeout per node
+			defer cancel()
+
+			locks, err := scanLocks(nodeCtx, node, l.namespace+prefix)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("error listing locks on node %v: %w", node.Options().Addr, err))
+				return
+			}
+			answered += l.weight(node)
+			for _, lock := range locks {
+				if seen[lock.resource] == nil {
+					seen[lock.resource] = make(map[string]*LockInfo)
+				}
+				info, ok := seen[lock.resource][lock.token]
+				if !ok {
+					info = &LockInfo{Resource: l.resourceName(lock.resource), Token: lock.token, Ttl: lock.ttl}
+					seen[lock.resource][lock.token] = info
+				}
+				info.Nodes++
+				info.votes += l.weight(node)
+				if lock.ttl < info.Ttl {
+					info.Ttl = lock.ttl
+				}
+			}
+		}(node)
+	}
+
+	wg.Wait()
+
+	// Log errors if any
+	if len(errs) > 0 {
+		l.logger.Warn("errors while listing locks", "prefix", prefix, "errors", errs)
+	}
+
+	// Not enough nodes answered to tell which locks hold a quorum
+	if answered < l.quorum {
+		return nil, InternalError
+	}
+
+	locks := make([]LockInfo, 0)
+	for _, tokens := range seen {
+		for _, info := range tokens {
+			if info.votes >= l.quorum {
+				info.TtlMs = info.Ttl.Milliseconds()
+				locks = append(locks, *info)
+			}
+		}
+	}
+	sort.Slice(locks, func(i, j int) bool {
+		return locks[i].Resource < locks[j].Resource
+	})
+
+	return locks, nil
+}
+
+// scanLocks reads the lock keys of a single node with SCAN, then their token and TTL in one pipeline
+func scanLocks(ctx context.Context, node *redis.Client, prefix string) ([]nodeLock, error) {
+	keys := make([]string, 0)
+	iter := node.ScanType(ctx, 0, prefix+"*", listScanCount, "string").Iterator()
+	for iter.Next(ctx) {
+		if !isInternalKey(iter.Val()) {
+			keys = append(keys, iter.Val())
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+
+	pipe := node.Pipeline()
+	gets := make([]*redis.StringCmd, len(keys))
+	ttls := make([]*redis.DurationCmd, len(keys))
+	for i, key := range keys {
+		gets[i] = pipe.Get(ctx, key)
+		ttls[i] = pipe.PTTL(ctx, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	locks := make([]nodeLock, 0, len(keys))
+	for i, key := range keys {
+		token, err := gets[i].Result()
+		ttl := ttls[i].Val()
+		if err != nil || ttl <= 0 {
+			continue // Expired or deleted since the scan, or not a lock (no TTL)
+		}
+		locks = append(locks, nodeLock{resource: key, token: token, ttl: ttl})
+	}
+
+	return locks, nil
+}
+
+// isInternalKey reports whether key is one of the service's own bookkeeping keys
+func isInternalKey(key string) bool {
+	for _, prefix := range internalKeyPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -6,7 +6,9 @@ import (
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 	"golang.org/x/net/context"
-	"log"
+	"golang.org/x/sync/singleflight"
+	"log/slog"
+	"math/rand"
 	"sync"
 	"time"
 )
@@ -15,32 +17,235 @@ var (
 	AcquireLockError  = errors.New("lock already acquired")
 	LockNotFoundError = errors.New("lock not found or expired")
 	InternalError     = errors.New("error connecting to one or more nodes")
+	TTLTooShortError  = errors.New("lock ttl expired before quorum was confirmed, use a larger ttl")
+
+	// QuorumUnavailableError reports an acquisition that missed the quorum because nodes failed, not
+	// because the resource is held: the service is degraded and the resource may well be free
+	QuorumUnavailableError = errors.New("not enough nodes available to reach quorum")
 )
 
+// DefaultNodeTimeout bounds each Redis call so a stalled node can't hold the whole quorum
+const DefaultNodeTimeout = 2 * time.Second
+
+// SharedQueryTimeout bounds a fan-out shared by concurrent identical queries. It runs detached from
+// the callers' contexts, so one caller giving up doesn't fail the query for the others.
+const SharedQueryTimeout = 5 * time.Second
+
+// DefaultRetryDelay is the base delay between acquisition attempts when retries are enabled
+const DefaultRetryDelay = 200 * time.Millisecond
+
+// Clock drift allowance subtracted from the validity time, as in the Redlock algorithm:
+// drift = ttl * ClockDriftFactor + MinClockDrift
+const (
+	ClockDriftFactor = 0.01
+	MinClockDrift    = 2 * time.Millisecond
+)
+
+// acquireScript takes the exclusive lock only while the resource has no live shared holder. It returns
+// {1} when taken, or {0, holder token, holder pttl} when refused; shared holders are reported without a token.
+// A lock already held with the caller's token counts as taken and is extended to the new TTL, so retrying
+// an acquisition with a client-supplied token is idempotent.
+// The acquisition time is recorded in the metadata hash, which lives as long as the lock.
+// KEYS[1] = resource, KEYS[2] = shared holders set, KEYS[3] = metadata hash,
+// ARGV[1] = token, ARGV[2] = ttl (ms), ARGV[3] = now (ms)
+var acquireScript = redis.NewScript(`
+redis.call("zremrangebyscore", KEYS[2], "-inf", ARGV[3])
+if redis.call("zcard", KEYS[2]) > 0 then
+	return {0, "", redis.call("pttl", KEYS[2])}
+end
+if redis.call("set", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	redis.call("del", KEYS[3])
+	redis.call("hset", KEYS[3], "token", ARGV[1], "acquired_at", ARGV[3])
+	redis.call("pexpire", KEYS[3], ARGV[2])
+	return {1}
+end
+local current = redis.call("get", KEYS[1])
+if current == ARGV[1] then
+	redis.call("pexpire", KEYS[1], ARGV[2])
+	redis.call("pexpire", KEYS[3], ARGV[2])
+	return {1}
+end
+return {0, current, redis.call("pttl", KEYS[1])}
+`)
+
+// releaseScript deletes the key, or removes the shared holder, only if it belongs to the caller's token,
+// so a lock that expired and was re-acquired by someone else is never deleted by the previous holder.
+// A reentrant lock is only deleted once its owner released it as many times as it acquired it. Each
+// release carries its own id, recorded in the reentry hash, so running the same release twice on a node
+// (e.g. retried after a dropped connection) gives back a single level.
+// Returns {released, live shared holders left}, the latter always 0 for an exclusive lock.
+// KEYS[1] = resource, KEYS[2] = shared holders set, KEYS[3] = reentry hash, KEYS[4] = metadata hash,
+// ARGV[1] = token, ARGV[2] = now (ms), ARGV[3] = release id
+var releaseScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	if redis.call("hget", KEYS[3], "token") == ARGV[1] then
+		if redis.call("hget", KEYS[3], "release") == ARGV[3] then
+			return {1, 0}
+		end
+		redis.call("hset", KEYS[3], "release", ARGV[3])
+		if redis.call("hincrby", KEYS[3], "count", -1) > 0 then
+			return {1, 0}
+		end
+	end
+	redis.call("del", KEYS[3], KEYS[4])
+	return {redis.call("del", KEYS[1]), 0}
+end
+local removed = redis.call("zrem", KEYS[2], ARGV[1])
+return {removed, redis.call("zcount", KEYS[2], "(" .. ARGV[2], "+inf")}
+`)
+
+// refreshScript extends the TTL, with millisecond precision, of the exclusive key or shared holder
+// owned by the caller's token. KEYS[1] = resource, KEYS[2] = shared holders set, KEYS[3] = reentry hash,
+// KEYS[4] = metadata hash, ARGV[1] = token, ARGV[2] = ttl (ms), ARGV[3] = now (ms)
+var refreshScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	if redis.call("hget", KEYS[3], "token") == ARGV[1] then
+		redis.call("pexpire", KEYS[3], ARGV[2])
+	end
+	if redis.call("hget", KEYS[4], "token") == ARGV[1] then
+		redis.call("pexpire", KEYS[4], ARGV[2])
+	end
+	return redis.call("pexpire", KEYS[1], ARGV[2])
+end
+local expiry = redis.call("zscore", KEYS[2], ARGV[1])
+if not expiry or tonumber(expiry) <= tonumber(ARGV[3]) then
+	return 0
+end
+redis.call("zadd", KEYS[2], "XX", tonumber(ARGV[3]) + tonumber(ARGV[2]), ARGV[1])
+local last = redis.call("zrange", KEYS[2], -1, -1, "WITHSCORES")
+redis.call("pexpireat", KEYS[2], last[2])
+return 1
+`)
+
+// lockKeys lists the keys a resource's lock may live in: the exclusive key, the shared holders set,
+// the reentry hash and the metadata hash
+func lockKeys(resource string) []string {
+	return []string{resource, SharedKeyPrefix + resource, ReentryKeyPrefix + resource, MetaKeyPrefix + resource}
+}
+
 type Locker struct {
-	Ttl      int64
-	Token    string
-	Resource string
+	TtlMs      int64 // TTL granted to the lock keys, in milliseconds
+	Token      string
+	Resource   string
+	Elapsed    time.Duration // Time spent reaching quorum
+	Validity   time.Duration // Time the lock is still safe to hold after acquisition, net of clock drift
+	NodesAcked int           // Number of nodes that granted the lock
+	Fence      int64         // Fencing token, strictly increasing across acquisitions of the resource
+}
+
+// TTL returns the TTL granted to the lock keys
+func (l *Locker) TTL() time.Duration {
+	return time.Duration(l.TtlMs) * time.Millisecond
 }
 
 type redLock struct {
-	redisNodes []*redis.Client
-	quorum     int
+	redisNodes    []*redis.Client
+	quorum        int // Votes needed, a majority of the total node weight
+	nodeWeights   []int
+	weights       map[*redis.Client]int
+	nodeTimeout   time.Duration
+	retryCount    int
+	retryDelay    time.Duration
+	logger        Logger
+	verifyAcquire bool
+	canonicalize  Canonicalizer
+	namespace     string // Key prefix, namespace and colon
+	ttlGroup      singleflight.Group
+
+	maxResourceLength int
+}
+
+// Option defines a functional option for the locker
+type Option func(*redLock)
+
+// WithVerifiedAcquire makes Acquire re-read the token from the nodes after reaching quorum and
+// fail unless a quorum still confirms ownership. Slower, but catches a node that expired and was
+// taken by someone else while the fan-out was in progress.
+func WithVerifiedAcquire() Option {
+	return func(l *redLock) {
+		l.verifyAcquire = true
+	}
+}
+
+// WithNodeTimeout bounds each call to a single Redis node, DefaultNodeTimeout when unset.
+// A short timeout abandons a stalled node quickly instead of slowing down the whole quorum.
+func WithNodeTimeout(timeout time.Duration) Option {
+	return func(l *redLock) {
+		if timeout > 0 {
+			l.nodeTimeout = timeout
+		}
+	}
+}
+
+// WithRetry makes an acquisition that doesn't reach the quorum try again up to count more times,
+// waiting delay plus a random jitter of up to delay/2 between attempts, as the Redlock algorithm
+// recommends to ride out transient node failures
+func WithRetry(count int, delay time.Duration) Option {
+	return func(l *redLock) {
+		if count > 0 {
+			l.retryCount = count
+		}
+		if delay > 0 {
+			l.retryDelay = delay
+		}
+	}
 }
 
 type RedLocker interface {
 	Acquire(ctx context.Context, resource string, ttl time.Duration) (*Locker, error)
+	AcquireWithToken(ctx context.Context, resource string, token string, ttl time.Duration) (*Locker, error)
 	Release(ctx context.Context, resource string, token string) error
-	Refresh(ctx context.Context, resource string, token string, ttl time.Duration) error
-	TTL(ctx context.Context, resource string, token string) (time.Duration, error)
+	ReleaseRemaining(ctx context.Context, resource string, token string) (int, error)
+	ReleaseByToken(ctx context.Context, token string) (int, error)
+	Refresh(ctx context.Context, resource string, token string, ttl time.Duration) (int, error)
+	TTL(ctx context.Context, resource string, token string) (time.Duration, Metadata, error)
+	TTLMulti(ctx context.Context, items []TTLQuery) (map[string]time.Duration, error)
+	AcquireAnyN(ctx context.Context, resources []string, n int, ttl time.Duration) ([]*Locker, error)
+	AcquireMulti(ctx context.Context, resources []string, ttl time.Duration) ([]*Locker, error)
+	AcquireShared(ctx context.Context, resource string, ttl time.Duration) (*Locker, error)
+	AcquireReentrant(ctx context.Context, resource string, owner string, ttl time.Duration) (*Locker, error)
+	List(ctx context.Context, prefix string) ([]LockInfo, error)
+	ValidateFence(ctx context.Context, resource string, fence int64) (bool, error)
+	Close() error
 }
 
-// TTL checks the remaining time-to-live (TTL) of a lock
-func (l *redLock) TTL(ctx context.Context, resource string, token string) (time.Duration, error) {
+// TTL checks the remaining time-to-live (TTL) of a lock, along with who took it and when.
+// Concurrent identical queries share a single fan-out to the Redis nodes.
+func (l *redLock) TTL(ctx context.Context, resource string, token string) (time.Duration, Metadata, error) {
+	resource = l.canonical(resource)
+
+	shared := l.ttlGroup.DoChan(resource+"#"+token, func() (interface{}, error) {
+		sharedCtx, cancel := context.WithTimeout(context.Background(), SharedQueryTimeout)
+		defer cancel()
+		return l.ttl(sharedCtx, resource, token)
+	})
+
+	select {
+	case <-ctx.Done():
+		return 0, Metadata{}, ctx.Err()
+	case result := <-shared:
+		if result.Err != nil {
+			return 0, Metadata{}, result.Err
+		}
+		status := result.Val.(lockStatus)
+		return status.ttl, status.meta, nil
+	}
+}
+
+// lockStatus is the outcome of a TTL query, shared by concurrent identical queries
+type lockStatus struct {
+	ttl  time.Duration
+	meta Metadata
+}
+
+// ttl queries the remaining time-to-live and the metadata of a lock on every node
+func (l *redLock) ttl(ctx context.Context, resource string, token string) (lockStatus, error) {
 	var wg sync.WaitGroup
 	var mu sync.Mutex
+	var meta Metadata
 	ttlCount := 0
-	totalTTL := int64(0)
+	ttlVotes := 0
+	totalTTL := int64(0) // milliseconds
 	errs := make([]error, 0)
 
 	// Parallelize the TTL check operation on each Redis node
@@ -49,10 +254,14 @@ func (l *redLock) TTL(ctx context.Context, resource string, token string) (time.
 		go func(node *redis.Client) {
 			defer wg.Done()
 
-			nodeCtx, cancel := context.WithTimeout(ctx, 2*time.Second) // Timeout per node
+			nodeCtx, cancel := context.WithTimeout(ctx, l.nodeTimeout) // Timeout per node
 			defer cancel()
 
-			val, err := node.Get(nodeCtx, resource).Result()
+			var val string
+			err := l.withReconnect(nodeCtx, node, "ttl", func() (err error) {
+				val, err = node.Get(nodeCtx, resource).Result()
+				return err
+			})
 			if errors.Is(err, redis.Nil) {
 				return // Key does not exist
 			} else if err != nil {
@@ -64,12 +273,25 @@ func (l *redLock) TTL(ctx context.Context, resource string, token string) (time.
 
 			// Verify if the lock belongs to the client
 			if val == token {
-				ttl, err := node.TTL(nodeCtx, resource).Result()
+				var ttl time.Duration
+				err := l.withReconnect(nodeCtx, node, "ttl", func() (err error) {
+					ttl, err = node.PTTL(nodeCtx, resource).Result()
+					return err
+				})
 				if err == nil && ttl > 0 {
+					nodeMeta, metaErr := readMetadata(nodeCtx, node, resource, token)
+					if metaErr != nil {
+						l.logger.Debug("error reading lock metadata on node", "resource", resource, "node", node.Options().Addr, "error", metaErr)
+					}
+
 					mu.Lock()
-					totalTTL += int64(ttl.Seconds())
-					log.Printf("get TTL from resource '%s#%s' on node %s\n", resource, token, node.String())
+					if meta.AcquiredAt.IsZero() {
+						meta = nodeMeta
+					}
+					totalTTL += ttl.Milliseconds()
+					l.logger.Debug("got lock ttl on node", "resource", resource, "token", token, "node", node.Options().Addr)
 					ttlCount++
+					ttlVotes += l.weight(node)
 					mu.Unlock()
 				} else if err != nil {
 					mu.Lock()
@@ -84,29 +306,74 @@ func (l *redLock) TTL(ctx context.Context, resource string, token string) (time.
 
 	// Log errors if any
 	if len(errs) > 0 {
-		log.Printf("errors while getting TTL: %v\n", errs)
+		l.logger.Warn("errors while getting TTL", "resource", resource, "errors", errs)
 	}
 
 	// Check if quorum was reached
-	if ttlCount >= l.quorum {
-		// Return the average TTL across nodes in the quorum
-		avgTTL := time.Duration(totalTTL/int64(ttlCount)) * time.Second
-		return avgTTL, nil
+	if ttlVotes >= l.quorum {
+		// Return the average TTL across nodes in the quorum, keeping millisecond precision
+		avgTTL := time.Duration(totalTTL/int64(ttlCount)) * time.Millisecond
+		return lockStatus{ttl: avgTTL, meta: meta}, nil
 	}
 
-	return 0, LockNotFoundError
+	return lockStatus{}, LockNotFoundError
 }
 
-// Acquire attempts to acquire the lock across multiple Redis nodes
+// Acquire attempts to acquire the exclusive lock across multiple Redis nodes
 func (l *redLock) Acquire(ctx context.Context, resource string, ttl time.Duration) (*Locker, error) {
-	token := uuid.New().String()
+	if err := l.validResource(resource); err != nil {
+		return nil, err
+	}
+	return l.acquire(ctx, l.canonical(resource), uuid.New().String(), ttl, exclusiveMode)
+}
+
+// AcquireWithToken acquires the exclusive lock under a token chosen by the client instead of a generated
+// one. If the resource is already held with that token the acquisition succeeds and extends the lock, so
+// a client whose response was lost can retry without ending up with a lock it can't identify.
+func (l *redLock) AcquireWithToken(ctx context.Context, resource string, token string, ttl time.Duration) (*Locker, error) {
+	if err := l.validResource(resource); err != nil {
+		return nil, err
+	}
+	if err := ValidateToken(token); err != nil {
+		return nil, err
+	}
+	return l.acquire(ctx, l.canonical(resource), token, ttl, exclusiveMode)
+}
+
+// acquire runs the Redlock fan-out and, while the quorum is not reached, retries it up to retryCount
+// times after a randomized delay. Partial locks are released by each failed attempt.
+func (l *redLock) acquire(ctx context.Context, resource string, token string, ttl time.Duration, mode lockMode) (*Locker, error) {
+	for attempt := 0; ; attempt++ {
+		lock, err := l.acquireOnce(ctx, resource, token, ttl, mode)
+		retryable := errors.Is(err, AcquireLockError) || errors.Is(err, QuorumUnavailableError)
+		if err == nil || !retryable || attempt >= l.retryCount {
+			return lock, err
+		}
+
+		// Randomize the delay so clients that collided don't retry in lockstep
+		delay := l.retryDelay + time.Duration(rand.Int63n(int64(l.retryDelay)/2+1))
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+	}
+}
+
+// acquireOnce runs the Redlock fan-out, taking the lock with token on each node the way mode does
+func (l *redLock) acquireOnce(ctx context.Context, resource string, token string, ttl time.Duration, mode lockMode) (*Locker, error) {
 	lockCount := 0
+	votes := 0
+	failedVotes := 0 // Votes of the nodes that errored rather than refused
 	startTime := time.Now()
 
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 	errs := make([]error, 0)
 	errChan := make(chan error, len(l.redisNodes))
+	holders := make([]holder, 0) // Who held the resource on the nodes that refused it
 
 	// Parallelize the lock acquisition attempt on each Redis node
 	for _, node := range l.redisNodes {
@@ -114,19 +381,34 @@ func (l *redLock) Acquire(ctx context.Context, resource string, ttl time.Duratio
 		go func(node *redis.Client) {
 			defer wg.Done()
 
-			nodeCtx, cancel := context.WithTimeout(ctx, 2*time.Second) // Timeout per node
+			nodeCtx, cancel := context.WithTimeout(ctx, l.nodeTimeout) // Timeout per node
 			defer cancel()
 
-			ok, err := node.SetNX(nodeCtx, resource, token, ttl).Result()
+			var ok bool
+			var current holder
+			attempt := 0
+			err := l.withReconnect(nodeCtx, node, "acquire", func() (err error) {
+				attempt++
+				ok, current, err = mode.take(nodeCtx, node, resource, token, ttl)
+				if err == nil && !ok && attempt > 1 {
+					// The first attempt may have been applied before the connection dropped
+					ok, _ = mode.holds(nodeCtx, node, resource, token)
+				}
+				return err
+			})
+			mu.Lock()
+			defer mu.Unlock()
 			if err != nil {
 				errChan <- fmt.Errorf("error on node %v: %w", node.Options().Addr, err)
+				failedVotes += l.weight(node)
 				return
 			}
 			if ok {
-				mu.Lock()
 				lockCount++
-				log.Printf("resource '%s#%s' locked on node %s\n", resource, token, node.String())
-				mu.Unlock()
+				votes += l.weight(node)
+				l.logger.Debug("resource locked on node", "resource", resource, "token", token, "node", node.Options().Addr)
+			} else if current.ttl > 0 {
+				holders = append(holders, current)
 			}
 		}(node)
 	}
@@ -142,96 +424,212 @@ func (l *redLock) Acquire(ctx context.Context, resource string, ttl time.Duratio
 
 	// Log errors if any
 	if len(errs) > 0 {
-		log.Printf("errors while acquiring lock: %v\n", errs)
+		l.logger.Warn("errors while acquiring lock", "resource", resource, "errors", errs)
+	}
+
+	// Confirm the token is still held by a quorum before trusting the writes
+	if votes >= l.quorum && l.verifyAcquire {
+		confirmed := l.countHolders(ctx, resource, token, mode)
+		if confirmed < l.quorum {
+			l.logger.Error("acquire verification failed", "resource", resource, "token", token, "confirmed", confirmed, "quorum", l.quorum)
+			_, _ = l.releaseKey(ctx, resource, token)
+			return nil, AcquireLockError
+		}
+	}
+
+	// Issue the fencing token while the lock is held on a quorum; its cost counts against the validity
+	var fence int64
+	if votes >= l.quorum {
+		var err error
+		fence, err = l.nextFence(ctx, resource)
+		if err != nil {
+			l.logger.Error("resource locked but no fencing token could be issued", "resource", resource, "token", token, "error", err)
+			_, _ = l.releaseKey(ctx, resource, token)
+			return nil, err
+		}
 	}
 
-	// Check if quorum was reached and TTL is still valid
+	// Check if quorum was reached and the lock is still valid once clock drift is accounted for
 	elapsed := time.Since(startTime)
-	if lockCount >= l.quorum && elapsed < ttl {
+	validity := ttl - elapsed - clockDrift(ttl)
+	if votes >= l.quorum && validity > 0 {
 		return &Locker{
-			Ttl:      ttl.Milliseconds(),
-			Token:    token,
-			Resource: resource,
+			TtlMs:      ttl.Milliseconds(),
+			Token:      token,
+			Resource:   l.resourceName(resource),
+			Elapsed:    elapsed,
+			Validity:   validity,
+			NodesAcked: lockCount,
+			Fence:      fence,
 		}, nil
 	}
 
 	// Release partial locks on failure
-	_ = l.Release(ctx, resource, token)
-	return nil, AcquireLockError
+	_, _ = l.releaseKey(ctx, resource, token)
+
+	// Quorum was reached but the acquisition and the drift allowance consumed the TTL
+	if votes >= l.quorum {
+		l.logger.Error("acquisition left no validity within ttl", "resource", resource, "token", token, "elapsed", elapsed, "ttl", ttl)
+		return nil, TTLTooShortError
+	}
+
+	// The failed nodes alone kept the quorum out of reach: had they answered, it could have been reached
+	if votes+failedVotes >= l.quorum {
+		l.logger.Error("quorum unavailable", "resource", resource, "token", token, "errors", len(errs))
+		return nil, QuorumUnavailableError
+	}
+
+	return nil, &ConflictError{Resource: l.resourceName(resource), HeldFor: heldFor(holders)}
 }
 
-// Release releases the lock on all Redis nodes
-func (l *redLock) Release(ctx context.Context, resource string, token string) error {
+// clockDrift is the share of ttl that may be lost to clock drift between the Redis nodes
+func clockDrift(ttl time.Duration) time.Duration {
+	return time.Duration(float64(ttl)*ClockDriftFactor) + MinClockDrift
+}
+
+// countHolders returns the votes of the nodes currently holding the resource with the given token
+func (l *redLock) countHolders(ctx context.Context, resource string, token string, mode lockMode) int {
 	var wg sync.WaitGroup
 	var mu sync.Mutex
-	notFoundCount := 0
-	errs := make([]error, 0)
+	holders := 0
 
-	// Parallelize the lock release on each Redis node
 	for _, node := range l.redisNodes {
 		wg.Add(1)
 		go func(node *redis.Client) {
 			defer wg.Done()
 
-			nodeCtx, cancel := context.WithTimeout(ctx, 2*time.Second) // Timeout per node
+			nodeCtx, cancel := context.WithTimeout(ctx, l.nodeTimeout) // Timeout per node
 			defer cancel()
 
-			val, err := node.Get(nodeCtx, resource).Result()
-			if errors.Is(err, redis.Nil) {
-				mu.Lock()
-				notFoundCount++
-				mu.Unlock()
-				return // Key does not exist
-			} else if err != nil {
-				mu.Lock()
-				errs = append(errs, fmt.Errorf("error on node %v: %w", node.Options().Addr, err))
-				mu.Unlock()
+			var held bool
+			err := l.withReconnect(nodeCtx, node, "verify", func() (err error) {
+				held, err = mode.holds(nodeCtx, node, resource, token)
+				return err
+			})
+			if err != nil {
+				l.logger.Warn("error verifying lock on node", "resource", resource, "node", node.Options().Addr, "error", err)
 				return
 			}
-
-			// Verify if the lock belongs to the client
-			if val == token {
-				_, err := node.Del(nodeCtx, resource).Result()
-				if err != nil {
-					mu.Lock()
-					errs = append(errs, fmt.Errorf("error deleting key on node %v: %w", node.Options().Addr, err))
-					mu.Unlock()
-				} else {
-					log.Printf("resource '%s#%s' released on node %s\n", resource, token, node.String())
-				}
-			} else {
+			if held {
 				mu.Lock()
-				errs = append(errs, fmt.Errorf("lock mismatch on node %v: token does not match", node.Options().Addr))
+				holders += l.weight(node)
 				mu.Unlock()
 			}
 		}(node)
 	}
 
 	wg.Wait()
+	return holders
+}
 
-	// Log errors if any
-	if len(errs) > 0 {
-		log.Printf("errors while releasing lock: %v\n", errs)
+// ttlMillis converts ttl for PEXPIRE, rounding sub-millisecond values up as go-redis does for SET PX,
+// since PEXPIRE 0 would delete the key
+func ttlMillis(ttl time.Duration) int64 {
+	if ttl > 0 && ttl < time.Millisecond {
+		return 1
+	}
+	return ttl.Milliseconds()
+}
+
+// Release releases the lock on all Redis nodes. When some nodes fail it returns a *ReleaseError
+// with the outcome of each node.
+func (l *redLock) Release(ctx context.Context, resource string, token string) error {
+	_, err := l.ReleaseRemaining(ctx, resource, token)
+	return err
+}
+
+// ReleaseRemaining releases the lock like Release and also returns how many other shared holders still
+// hold the resource, 0 for an exclusive lock. It is the highest count among the nodes that answered, so 0
+// means the resource is free, since no holder can be left on a quorum.
+func (l *redLock) ReleaseRemaining(ctx context.Context, resource string, token string) (int, error) {
+	return l.releaseKey(ctx, l.canonical(resource), token)
+}
+
+// releaseKey runs the release fan-out on a key that is already canonical and namespaced, as held by the
+// internal callers, so it isn't prefixed a second time
+func (l *redLock) releaseKey(ctx context.Context, resource string, token string) (int, error) {
+	releaseID := uuid.New().String()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	remaining := 0
+	deletedVotes := 0
+	notFoundVotes := 0
+	failedVotes := 0
+	results := make([]NodeRelease, 0, len(l.redisNodes))
+
+	// Parallelize the lock release on each Redis node
+	for _, node := range l.redisNodes {
+		wg.Add(1)
+		go func(node *redis.Client) {
+			defer wg.Done()
+
+			nodeCtx, cancel := context.WithTimeout(ctx, l.nodeTimeout) // Timeout per node
+			defer cancel()
+
+			// Compare and delete in a single round-trip. A retry after a dropped connection may find the
+			// key already deleted by the first attempt, which is then counted as not found; the release
+			// id keeps it from giving back a second level of a reentrant lock.
+			var reply []int64
+			err := l.withReconnect(nodeCtx, node, "release", func() (err error) {
+				reply, err = releaseScript.Run(nodeCtx, node, lockKeys(resource), token, time.Now().UnixMilli(), releaseID).Int64Slice()
+				return err
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+			result := NodeRelease{Addr: node.Options().Addr, Err: err}
+			if err != nil {
+				failedVotes += l.weight(node)
+			} else if reply[0] == 0 {
+				notFoundVotes += l.weight(node) // Key does not exist or belongs to another client
+			} else {
+				deletedVotes += l.weight(node)
+				result.Released = true
+				l.logger.Debug("resource released on node", "resource", resource, "token", token, "node", node.Options().Addr)
+			}
+			if err == nil {
+				remaining = max(remaining, int(reply[1])) // Shared holders still on this node
+			}
+			results = append(results, result)
+		}(node)
 	}
 
+	wg.Wait()
+
 	// Check if quorum indicates the lock was not found
-	if notFoundCount >= l.quorum {
-		return LockNotFoundError
+	if notFoundVotes >= l.quorum {
+		return 0, LockNotFoundError
 	}
 
-	// If there are other errors but the lock was released successfully on some nodes, return a generic error
-	if len(errs) > 0 {
-		return InternalError
+	// Only a quorum of confirmed deletions frees the lock. Otherwise report which nodes failed and
+	// whether the lock is gone from a quorum regardless.
+	if failedVotes > 0 || deletedVotes < l.quorum {
+		releaseErr := &ReleaseError{
+			Resource:      l.resourceName(resource),
+			Nodes:         results,
+			ReachedQuorum: deletedVotes+notFoundVotes >= l.quorum,
+		}
+		if releaseErr.ReachedQuorum {
+			l.logger.Warn("errors while releasing lock", "resource", resource, "token", token, "error", releaseErr)
+		} else {
+			l.logger.Error("release did not reach quorum", "resource", resource, "token", token, "error", releaseErr)
+		}
+		return 0, releaseErr
 	}
 
-	return nil
+	return remaining, nil
 }
 
-// Refresh verifies if the lock is active and extends its TTL
-func (l *redLock) Refresh(ctx context.Context, resource string, token string, ttl time.Duration) error {
+// Refresh verifies if the lock is active and extends its TTL.
+// Returns the number of nodes where the TTL was extended, which may be lower than at acquire time.
+func (l *redLock) Refresh(ctx context.Context, resource string, token string, ttl time.Duration) (int, error) {
+	resource = l.canonical(resource)
+
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 	activeCount := 0
+	votes := 0
 	errs := make([]error, 0)
 
 	// Parallelize the refresh operation on each Redis node
@@ -240,32 +638,24 @@ func (l *redLock) Refresh(ctx context.Context, resource string, token string, tt
 		go func(node *redis.Client) {
 			defer wg.Done()
 
-			nodeCtx, cancel := context.WithTimeout(ctx, 2*time.Second) // Timeout per node
+			nodeCtx, cancel := context.WithTimeout(ctx, l.nodeTimeout) // Timeout per node
 			defer cancel()
 
-			val, err := node.Get(nodeCtx, resource).Result()
-			if errors.Is(err, redis.Nil) {
-				return // Key does not exist
-			} else if err != nil {
-				mu.Lock()
-				errs = append(errs, fmt.Errorf("error checking lock on node %v: %w", node.Options().Addr, err))
-				mu.Unlock()
-				return
-			}
+			// Check the token and extend the TTL in a single round-trip
+			var extended int64
+			err := l.withReconnect(nodeCtx, node, "refresh", func() (err error) {
+				extended, err = refreshScript.Run(nodeCtx, node, lockKeys(resource), token, ttlMillis(ttl), time.Now().UnixMilli()).Int64()
+				return err
+			})
 
-			// Verify if the lock belongs to the client
-			if val == token {
-				_, err := node.Expire(nodeCtx, resource, ttl).Result()
-				if err == nil {
-					mu.Lock()
-					activeCount++
-					log.Printf("resource '%s#%s' refreshed on node %s\n", resource, token, node.String())
-					mu.Unlock()
-				} else {
-					mu.Lock()
-					errs = append(errs, fmt.Errorf("error refreshing lock on node %v: %w", node.Options().Addr, err))
-					mu.Unlock()
-				}
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("error refreshing lock on node %v: %w", node.Options().Addr, err))
+			} else if extended == 1 {
+				activeCount++
+				votes += l.weight(node)
+				l.logger.Debug("resource refreshed on node", "resource", resource, "token", token, "node", node.Options().Addr)
 			}
 		}(node)
 	}
@@ -274,22 +664,79 @@ func (l *redLock) Refresh(ctx context.Context, resource string, token string, tt
 
 	// Log errors if any
 	if len(errs) > 0 {
-		log.Printf("errors while refreshing lock: %v\n", errs)
+		l.logger.Warn("errors while refreshing lock", "resource", resource, "errors", errs)
 	}
 
 	// Check if quorum was reached
-	if activeCount >= l.quorum {
-		return nil
+	if votes >= l.quorum {
+		return activeCount, nil
+	}
+
+	return activeCount, LockNotFoundError
+}
+
+// Close closes the connection pool of every node. The operations attempted afterwards fail on every node,
+// acquisitions with QuorumUnavailableError. The nodes are shared, so the other components using them
+// (notifier, quota and nonce stores) must be done with them too.
+func (l *redLock) Close() error {
+	errs := make([]error, 0)
+	for _, node := range l.redisNodes {
+		if err := node.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("error closing connection to node %v: %w", node.Options().Addr, err))
+		}
 	}
+	return errors.Join(errs...)
+}
 
-	return LockNotFoundError
+// NewLocker creates a new RedLocker instance. It requires an odd number of nodes, at least 3, so a
+// majority quorum survives the loss of a node and can't be split evenly.
+func NewLocker(redisNodes []*redis.Client, opts ...Option) (RedLocker, error) {
+	l, err := newRedLock(redisNodes, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
 }
 
-// NewLocker creates a new RedLocker instance
-func NewLocker(redisNodes []*redis.Client) RedLocker {
-	quorum := len(redisNodes)/2 + 1
-	return &redLock{
-		redisNodes: redisNodes,
-		quorum:     quorum,
+// newRedLock validates the nodes and applies the options, see NewLocker
+func newRedLock(redisNodes []*redis.Client, opts ...Option) (*redLock, error) {
+	if len(redisNodes) <= 2 {
+		return nil, errors.New("number of Redis servers must be greater than 2")
+	}
+	if len(redisNodes)%2 == 0 {
+		return nil, errors.New("number of Redis servers must be odd")
+	}
+
+	quorum := Quorum(len(redisNodes))
+	l := &redLock{
+		redisNodes:  redisNodes,
+		quorum:      quorum,
+		nodeTimeout: DefaultNodeTimeout,
+		retryDelay:  DefaultRetryDelay,
+		logger:      slog.Default(),
+
+		maxResourceLength: DefaultMaxResourceLength,
 	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	if err := ValidateWeights(len(redisNodes), l.nodeWeights); err != nil {
+		return nil, err
+	}
+	if len(l.nodeWeights) > 0 {
+		l.weights = make(map[*redis.Client]int, len(redisNodes))
+		for i, node := range redisNodes {
+			l.weights[node] = l.nodeWeights[i]
+		}
+		l.quorum = WeightedQuorum(len(redisNodes), l.nodeWeights)
+	}
+
+	return l, nil
+}
+
+// Quorum returns the number of nodes that must agree for an operation over n nodes to succeed
+func Quorum(n int) int {
+	return n/2 + 1
 }
@@ -0,0 +1,282 @@
+package locker
+
+import (
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/net/context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// newTestNodes starts n in-memory Redis servers, closed with the test, and returns them with their clients
+func newTestNodes(t *testing.T, n int) ([]*miniredis.Miniredis, []*redis.Client) {
+	t.Helper()
+
+	servers := make([]*miniredis.Miniredis, n)
+	clients := make([]*redis.Client, n)
+	for i := range servers {
+		servers[i] = miniredis.RunT(t)
+		clients[i] = redis.NewClient(&redis.Options{Addr: servers[i].Addr(), MaxRetries: -1})
+		t.Cleanup(func() { _ = clients[i].Close() })
+	}
+	return servers, clients
+}
+
+// newTestLocker returns a locker over n in-memory Redis servers, logging nowhere
+func newTestLocker(t *testing.T, n int, opts ...Option) (*redLock, []*miniredis.Miniredis) {
+	t.Helper()
+
+	servers, clients := newTestNodes(t, n)
+	opts = append([]Option{WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))), WithNodeTimeout(time.Second)}, opts...)
+	l, err := newRedLock(clients, opts...)
+	if err != nil {
+		t.Fatalf("newRedLock: %v", err)
+	}
+	return l, servers
+}
+
+// newTestNodeSet returns the node set of a locker over n in-memory Redis servers, for the stores built on it
+func newTestNodeSet(t *testing.T, n int, opts ...Option) (*NodeSet, []*miniredis.Miniredis) {
+	t.Helper()
+
+	l, servers := newTestLocker(t, n, opts...)
+	return l.nodeSet(), servers
+}
+
+func TestAcquireSetsTheTokenOnEveryNode(t *testing.T) {
+	l, servers := newTestLocker(t, 3)
+
+	lock, err := l.Acquire(context.Background(), "item-1", time.Second)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	if lock.NodesAcked != 3 {
+		t.Errorf("NodesAcked = %d, want 3", lock.NodesAcked)
+	}
+	if lock.Validity <= 0 || lock.Validity > time.Second {
+		t.Errorf("Validity = %s, want within (0, 1s]", lock.Validity)
+	}
+	for i, server := range servers {
+		if got, _ := server.Get("item-1"); got != lock.Token {
+			t.Errorf("node %d holds %q, want the token %q", i, got, lock.Token)
+		}
+	}
+}
+
+func TestAcquireConflictReportsTheHolderTTL(t *testing.T) {
+	l, _ := newTestLocker(t, 3)
+	ctx := context.Background()
+
+	if _, err := l.Acquire(ctx, "item-1", 10*time.Second); err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+
+	_, err := l.Acquire(ctx, "item-1", time.Second)
+	if !errors.Is(err, AcquireLockError) {
+		t.Fatalf("second Acquire error = %v, want AcquireLockError", err)
+	}
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || conflict.HeldFor <= 0 || conflict.HeldFor > 10*time.Second {
+		t.Errorf("conflict = %+v, want the holder's remaining TTL", conflict)
+	}
+}
+
+func TestAcquireWithTokenIsIdempotent(t *testing.T) {
+	l, servers := newTestLocker(t, 3)
+	ctx := context.Background()
+
+	if _, err := l.AcquireWithToken(ctx, "item-1", "client-token", time.Second); err != nil {
+		t.Fatalf("AcquireWithToken: %v", err)
+	}
+	if _, err := l.AcquireWithToken(ctx, "item-1", "client-token", 5*time.Second); err != nil {
+		t.Fatalf("retried AcquireWithToken: %v", err)
+	}
+	if ttl := servers[0].TTL("item-1"); ttl != 5*time.Second {
+		t.Errorf("TTL after the retry = %s, want it extended to 5s", ttl)
+	}
+}
+
+func TestAcquireReachesQuorumWithOneNodeHeldElsewhere(t *testing.T) {
+	l, servers := newTestLocker(t, 3)
+
+	if err := servers[2].Set("item-1", "someone-else"); err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err := l.Acquire(context.Background(), "item-1", time.Second)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	if lock.NodesAcked != 2 {
+		t.Errorf("NodesAcked = %d, want 2", lock.NodesAcked)
+	}
+}
+
+func TestReleaseKeepsALockHeldWithAnotherToken(t *testing.T) {
+	l, servers := newTestLocker(t, 3)
+	ctx := context.Background()
+
+	lock, err := l.Acquire(ctx, "item-1", time.Second)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+
+	if err := l.Release(ctx, "item-1", "not-the-token"); !errors.Is(err, LockNotFoundError) {
+		t.Fatalf("Release with another token error = %v, want LockNotFoundError", err)
+	}
+	for i, server := range servers {
+		if got, _ := server.Get("item-1"); got != lock.Token {
+			t.Errorf("node %d holds %q after a foreign release, want %q", i, got, lock.Token)
+		}
+	}
+
+	if err := l.Release(ctx, "item-1", lock.Token); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	for i, server := range servers {
+		if server.Exists("item-1") {
+			t.Errorf("node %d still holds the lock after its release", i)
+		}
+	}
+}
+
+func TestRefreshExtendsOnlyTheOwnersLock(t *testing.T) {
+	l, servers := newTestLocker(t, 3)
+	ctx := context.Background()
+
+	lock, err := l.Acquire(ctx, "item-1", time.Second)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+
+	if _, err := l.Refresh(ctx, "item-1", "not-the-token", time.Minute); !errors.Is(err, LockNotFoundError) {
+		t.Fatalf("Refresh with another token error = %v, want LockNotFoundError", err)
+	}
+	if ttl := servers[0].TTL("item-1"); ttl > time.Second {
+		t.Errorf("TTL after a foreign refresh = %s, want it unchanged", ttl)
+	}
+
+	refreshed, err := l.Refresh(ctx, "item-1", lock.Token, 1500*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if refreshed != 3 {
+		t.Errorf("refreshed on %d nodes, want 3", refreshed)
+	}
+	if ttl := servers[0].TTL("item-1"); ttl != 1500*time.Millisecond {
+		t.Errorf("TTL after the refresh = %s, want 1.5s", ttl)
+	}
+}
+
+func TestRefreshFailsOnceTheLockExpired(t *testing.T) {
+	l, servers := newTestLocker(t, 3)
+	ctx := context.Background()
+
+	lock, err := l.Acquire(ctx, "item-1", time.Second)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	for _, server := range servers {
+		server.FastForward(2 * time.Second)
+	}
+
+	if _, err := l.Refresh(ctx, "item-1", lock.Token, time.Second); !errors.Is(err, LockNotFoundError) {
+		t.Errorf("Refresh of an expired lock error = %v, want LockNotFoundError", err)
+	}
+}
+
+func TestTTLKeepsMillisecondPrecision(t *testing.T) {
+	l, _ := newTestLocker(t, 3)
+	ctx := context.Background()
+
+	lock, err := l.Acquire(ctx, "item-1", 1500*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	if lock.TtlMs != 1500 {
+		t.Errorf("TtlMs = %d, want 1500", lock.TtlMs)
+	}
+
+	ttl, _, err := l.TTL(ctx, "item-1", lock.Token)
+	if err != nil {
+		t.Fatalf("TTL: %v", err)
+	}
+	if ttl != 1500*time.Millisecond {
+		t.Errorf("TTL = %s, want 1.5s rather than a whole number of seconds", ttl)
+	}
+
+	if _, _, err := l.TTL(ctx, "item-1", "not-the-token"); !errors.Is(err, LockNotFoundError) {
+		t.Errorf("TTL with another token error = %v, want LockNotFoundError", err)
+	}
+}
+
+func TestAcquireWithNoValidityLeftFailsAndReleases(t *testing.T) {
+	l, servers := newTestLocker(t, 3)
+
+	// The clock drift allowance alone exceeds a 1ms TTL
+	_, err := l.Acquire(context.Background(), "item-1", time.Millisecond)
+	if !errors.Is(err, TTLTooShortError) {
+		t.Fatalf("Acquire error = %v, want TTLTooShortError", err)
+	}
+	for i, server := range servers {
+		if server.Exists("item-1") {
+			t.Errorf("node %d still holds the lock that left no validity", i)
+		}
+	}
+}
+
+func TestReleaseFailsWhenTheQuorumWasNotCleared(t *testing.T) {
+	l, servers := newTestLocker(t, 3)
+	ctx := context.Background()
+
+	lock, err := l.Acquire(ctx, "item-1", time.Second)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	servers[1].SetError("node down")
+	servers[2].SetError("node down")
+
+	err = l.Release(ctx, "item-1", lock.Token)
+	var releaseErr *ReleaseError
+	if !errors.As(err, &releaseErr) {
+		t.Fatalf("Release error = %v, want a *ReleaseError", err)
+	}
+	if releaseErr.ReachedQuorum {
+		t.Error("ReachedQuorum = true with the lock still on two of three nodes")
+	}
+	if !errors.Is(err, InternalError) {
+		t.Error("a ReleaseError must match InternalError")
+	}
+}
+
+func TestReleaseReportsAQuorumClearedDespiteAFailedNode(t *testing.T) {
+	l, servers := newTestLocker(t, 3)
+	ctx := context.Background()
+
+	lock, err := l.Acquire(ctx, "item-1", time.Second)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	servers[2].SetError("node down")
+
+	err = l.Release(ctx, "item-1", lock.Token)
+	var releaseErr *ReleaseError
+	if !errors.As(err, &releaseErr) {
+		t.Fatalf("Release error = %v, want a *ReleaseError", err)
+	}
+	if !releaseErr.ReachedQuorum {
+		t.Error("ReachedQuorum = false with the lock deleted from two of three nodes")
+	}
+	released := 0
+	for _, node := range releaseErr.Nodes {
+		if node.Released {
+			released++
+		}
+	}
+	if released != 2 {
+		t.Errorf("%d nodes reported released, want 2", released)
+	}
+}
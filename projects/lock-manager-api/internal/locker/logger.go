@@ -0,0 +1,32 @@
+package locker
+
+import (
+	"log/slog"
+)
+
+// Logger receives the locker's leveled, structured log records; args are alternating key/value pairs.
+// *slog.Logger satisfies it.
+//
+// Per-node successes are logged at Debug, per-node errors at Warn and quorum failures at Error.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// WithLogger replaces the default logger, slog.Default()
+func WithLogger(logger Logger) Option {
+	return func(l *redLock) {
+		if logger != nil {
+			l.logger = logger
+		}
+	}
+}
+
+// ParseLogLevel converts "debug", "info", "warn" or "error" into a slog level
+func ParseLogLevel(level string) (slog.Level, error) {
+	var l slog.Level
+	err := l.UnmarshalText([]byte(level))
+	return l, err
+}
@@ -0,0 +1,458 @@
+package locker
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"golang.org/x/net/context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryLock is an exclusive lock held by the in-memory locker
+type memoryLock struct {
+	token      string
+	owner      string // Set for reentrant locks
+	count      int    // Reentrancy count, 0 for plain locks
+	acquiredAt time.Time
+	expiresAt  time.Time
+	timer      *time.Timer
+}
+
+// memoryHolder is a shared holder of a resource in the in-memory locker
+type memoryHolder struct {
+	expiresAt time.Time
+	timer     *time.Timer
+}
+
+type inMemoryLocker struct {
+	mu     sync.Mutex
+	locks  map[string]*memoryLock              // resource -> exclusive lock
+	shared map[string]map[string]*memoryHolder // resource -> token -> shared holder
+	fences map[string]int64
+	closed bool
+}
+
+// NewInMemoryLocker creates a RedLocker that keeps every lock in the memory of the process, for tests and
+// local development without Redis. It behaves like a single node that always answers: tokens are checked
+// the same way, locks expire on a timer once their TTL elapses and every acquisition reports one node.
+// Nothing is shared between processes, so it must never back more than one lock manager instance.
+func NewInMemoryLocker() RedLocker {
+	return &inMemoryLocker{
+		locks:  make(map[string]*memoryLock),
+		shared: make(map[string]map[string]*memoryHolder),
+		fences: make(map[string]int64),
+	}
+}
+
+// Acquire takes the exclusive lock under a generated token
+func (m *inMemoryLocker) Acquire(ctx context.Context, resource string, ttl time.Duration) (*Locker, error) {
+	return m.AcquireWithToken(ctx, resource, uuid.New().String(), ttl)
+}
+
+// AcquireWithToken takes the exclusive lock under the given token, extending it if already held with it
+func (m *inMemoryLocker) AcquireWithToken(ctx context.Context, resource string, token string, ttl time.Duration) (*Locker, error) {
+	if err := ValidateResource(resource, DefaultMaxResourceLength); err != nil {
+		return nil, err
+	}
+	if err := ValidateToken(token); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, QuorumUnavailableError
+	}
+	if err := m.conflict(resource, token); err != nil {
+		return nil, err
+	}
+	return m.grant(resource, token, "", ttl), nil
+}
+
+// conflict reports who keeps token from taking the exclusive lock, nil when it may take it.
+// The caller must hold m.mu.
+func (m *inMemoryLocker) conflict(resource string, token string) error {
+	if remaining := m.sharedRemaining(resource); remaining > 0 {
+		return &ConflictError{Resource: resource, HeldFor: remaining}
+	}
+	if lock := m.live(resource); lock != nil && lock.token != token {
+		return &ConflictError{Resource: resource, HeldFor: time.Until(lock.expiresAt)}
+	}
+	return nil
+}
+
+// grant takes or extends the exclusive lock and issues its fencing token. The caller must hold m.mu
+// and have checked there is no conflict.
+func (m *inMemoryLocker) grant(resource string, token string, owner string, ttl time.Duration) *Locker {
+	lock := m.live(resource)
+	if lock == nil || lock.token != token {
+		lock = &memoryLock{token: token, owner: owner, acquiredAt: time.Now()}
+		if owner != "" {
+			lock.count = 1
+		}
+		m.locks[resource] = lock
+		lock.timer = time.AfterFunc(ttl, func() { m.expire(resource, lock) })
+	} else {
+		lock.timer.Reset(ttl)
+	}
+	lock.expiresAt = time.Now().Add(ttl)
+
+	m.fences[resource]++
+	return &Locker{
+		TtlMs:      ttl.Milliseconds(),
+		Token:      token,
+		Resource:   resource,
+		Validity:   ttl - clockDrift(ttl),
+		NodesAcked: 1,
+		Fence:      m.fences[resource],
+	}
+}
+
+// live returns the exclusive lock held on the resource, nil if there is none or it already expired.
+// The caller must hold m.mu.
+func (m *inMemoryLocker) live(resource string) *memoryLock {
+	lock := m.locks[resource]
+	if lock == nil || !time.Now().Before(lock.expiresAt) {
+		return nil
+	}
+	return lock
+}
+
+// sharedRemaining returns how long the last live shared holder of the resource still holds it, zero
+// when none does. The caller must hold m.mu.
+func (m *inMemoryLocker) sharedRemaining(resource string) time.Duration {
+	var remaining time.Duration
+	for _, h := range m.shared[resource] {
+		remaining = max(remaining, time.Until(h.expiresAt))
+	}
+	return remaining
+}
+
+// expire drops the exclusive lock once its TTL elapsed, unless it was released or refreshed meanwhile
+func (m *inMemoryLocker) expire(resource string, lock *memoryLock) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.locks[resource] == lock && !time.Now().Before(lock.expiresAt) {
+		delete(m.locks, resource)
+	}
+}
+
+// expireShared drops a shared holder once its TTL elapsed, unless it was released or refreshed meanwhile
+func (m *inMemoryLocker) expireShared(resource string, token string, h *memoryHolder) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.shared[resource][token] == h && !time.Now().Before(h.expiresAt) {
+		m.dropShared(resource, token)
+	}
+}
+
+// dropShared removes a shared holder. The caller must hold m.mu.
+func (m *inMemoryLocker) dropShared(resource string, token string) {
+	if h := m.shared[resource][token]; h != nil {
+		h.timer.Stop()
+	}
+	delete(m.shared[resource], token)
+	if len(m.shared[resource]) == 0 {
+		delete(m.shared, resource)
+	}
+}
+
+// Release releases the exclusive lock or shared holder owned by token
+func (m *inMemoryLocker) Release(ctx context.Context, resource string, token string) error {
+	_, err := m.ReleaseRemaining(ctx, resource, token)
+	return err
+}
+
+// ReleaseRemaining releases like Release and returns how many other shared holders still hold the resource
+func (m *inMemoryLocker) ReleaseRemaining(ctx context.Context, resource string, token string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return 0, InternalError
+	}
+
+	if lock := m.live(resource); lock != nil && lock.token == token {
+		if lock.count > 1 {
+			lock.count--
+			return 0, nil
+		}
+		lock.timer.Stop()
+		delete(m.locks, resource)
+		return 0, nil
+	}
+
+	h := m.shared[resource][token]
+	if h == nil || !time.Now().Before(h.expiresAt) {
+		return 0, LockNotFoundError
+	}
+	m.dropShared(resource, token)
+	return len(m.shared[resource]), nil
+}
+
+// ReleaseByToken releases every exclusive lock held with token and returns how many were released
+func (m *inMemoryLocker) ReleaseByToken(ctx context.Context, token string) (int, error) {
+	if token == "" {
+		return 0, errors.New("token must not be empty")
+	}
+
+	m.mu.Lock()
+	resources := make([]string, 0)
+	for resource := range m.locks {
+		if lock := m.live(resource); lock != nil && lock.token == token {
+			resources = append(resources, resource)
+		}
+	}
+	m.mu.Unlock()
+
+	released := 0
+	for _, resource := range resources {
+		if err := m.Release(ctx, resource, token); err == nil {
+			released++
+		}
+	}
+	return released, nil
+}
+
+// Refresh extends the TTL of the exclusive lock or shared holder owned by token. Returns 1, the single
+// node, when it was extended.
+func (m *inMemoryLocker) Refresh(ctx context.Context, resource string, token string, ttl time.Duration) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return 0, InternalError
+	}
+
+	if lock := m.live(resource); lock != nil && lock.token == token {
+		lock.expiresAt = time.Now().Add(ttl)
+		lock.timer.Reset(ttl)
+		return 1, nil
+	}
+
+	h := m.shared[resource][token]
+	if h == nil || !time.Now().Before(h.expiresAt) {
+		return 0, LockNotFoundError
+	}
+	h.expiresAt = time.Now().Add(ttl)
+	h.timer.Reset(ttl)
+	return 1, nil
+}
+
+// TTL returns the remaining time-to-live of the exclusive lock held with token, and who took it and when
+func (m *inMemoryLocker) TTL(ctx context.Context, resource string, token string) (time.Duration, Metadata, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return 0, Metadata{}, InternalError
+	}
+
+	lock := m.live(resource)
+	if lock == nil || lock.token != token {
+		return 0, Metadata{}, LockNotFoundError
+	}
+	ttl := time.Until(lock.expiresAt).Truncate(time.Millisecond)
+	return ttl, Metadata{Owner: lock.owner, AcquiredAt: lock.acquiredAt.Truncate(time.Millisecond)}, nil
+}
+
+// TTLMulti checks the remaining time-to-live of several locks, leaving out those not held with their token
+func (m *inMemoryLocker) TTLMulti(ctx context.Context, items []TTLQuery) (map[string]time.Duration, error) {
+	ttls := make(map[string]time.Duration, len(items))
+	for _, item := range items {
+		ttl, _, err := m.TTL(ctx, item.Resource, item.Token)
+		if errors.Is(err, LockNotFoundError) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		ttls[item.Resource] = ttl
+	}
+	return ttls, nil
+}
+
+// AcquireAnyN locks the first n of the resources that are available, in the order they were given
+func (m *inMemoryLocker) AcquireAnyN(ctx context.Context, resources []string, n int, ttl time.Duration) ([]*Locker, error) {
+	if n <= 0 || n > len(resources) {
+		return nil, fmt.Errorf("n must be between 1 and %d", len(resources))
+	}
+	for _, resource := range resources {
+		if err := ValidateResource(resource, DefaultMaxResourceLength); err != nil {
+			return nil, err
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, QuorumUnavailableError
+	}
+
+	available := make([]string, 0, n)
+	for _, resource := range resources {
+		if len(available) < n && m.conflict(resource, "") == nil {
+			available = append(available, resource)
+		}
+	}
+	if len(available) < n {
+		return nil, AcquireLockError
+	}
+
+	locks := make([]*Locker, 0, n)
+	for _, resource := range available {
+		locks = append(locks, m.grant(resource, uuid.New().String(), "", ttl))
+	}
+	return locks, nil
+}
+
+// AcquireMulti locks every resource under a single shared token, or none of them
+func (m *inMemoryLocker) AcquireMulti(ctx context.Context, resources []string, ttl time.Duration) ([]*Locker, error) {
+	if len(resources) == 0 {
+		return nil, errors.New("no resources to lock")
+	}
+
+	sorted := make([]string, 0, len(resources))
+	seen := make(map[string]bool, len(resources))
+	for _, resource := range resources {
+		if err := ValidateResource(resource, DefaultMaxResourceLength); err != nil {
+			return nil, err
+		}
+		if seen[resource] {
+			return nil, errors.New("duplicate resource " + resource)
+		}
+		seen[resource] = true
+		sorted = append(sorted, resource)
+	}
+	sort.Strings(sorted)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, QuorumUnavailableError
+	}
+
+	token := uuid.New().String()
+	for _, resource := range sorted {
+		if err := m.conflict(resource, token); err != nil {
+			return nil, err
+		}
+	}
+
+	locks := make([]*Locker, 0, len(sorted))
+	for _, resource := range sorted {
+		locks = append(locks, m.grant(resource, token, "", ttl))
+	}
+	return locks, nil
+}
+
+// AcquireShared adds a shared holder, refused while an exclusive lock holds the resource
+func (m *inMemoryLocker) AcquireShared(ctx context.Context, resource string, ttl time.Duration) (*Locker, error) {
+	if err := ValidateResource(resource, DefaultMaxResourceLength); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, QuorumUnavailableError
+	}
+	if lock := m.live(resource); lock != nil {
+		return nil, &ConflictError{Resource: resource, HeldFor: time.Until(lock.expiresAt)}
+	}
+
+	token := uuid.New().String()
+	h := &memoryHolder{expiresAt: time.Now().Add(ttl)}
+	h.timer = time.AfterFunc(ttl, func() { m.expireShared(resource, token, h) })
+	if m.shared[resource] == nil {
+		m.shared[resource] = make(map[string]*memoryHolder)
+	}
+	m.shared[resource][token] = h
+
+	m.fences[resource]++
+	return &Locker{
+		TtlMs:      ttl.Milliseconds(),
+		Token:      token,
+		Resource:   resource,
+		Validity:   ttl - clockDrift(ttl),
+		NodesAcked: 1,
+		Fence:      m.fences[resource],
+	}, nil
+}
+
+// AcquireReentrant acquires the exclusive lock on behalf of owner, re-entering it if owner already holds it
+func (m *inMemoryLocker) AcquireReentrant(ctx context.Context, resource string, owner string, ttl time.Duration) (*Locker, error) {
+	if owner == "" {
+		return nil, errors.New("owner must not be empty")
+	}
+	if err := ValidateResource(resource, DefaultMaxResourceLength); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, QuorumUnavailableError
+	}
+
+	if lock := m.live(resource); lock != nil {
+		if lock.owner != owner || lock.count == 0 {
+			return nil, AcquireLockError
+		}
+		lock.count++
+		return m.grant(resource, lock.token, owner, ttl), nil
+	}
+	if m.sharedRemaining(resource) > 0 {
+		return nil, AcquireLockError
+	}
+	return m.grant(resource, uuid.New().String(), owner, ttl), nil
+}
+
+// List returns the exclusive locks whose resource starts with prefix, sorted by resource
+func (m *inMemoryLocker) List(ctx context.Context, prefix string) ([]LockInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, InternalError
+	}
+
+	locks := make([]LockInfo, 0)
+	for resource := range m.locks {
+		lock := m.live(resource)
+		if lock == nil || !strings.HasPrefix(resource, prefix) {
+			continue
+		}
+		ttl := time.Until(lock.expiresAt)
+		locks = append(locks, LockInfo{Resource: resource, Token: lock.token, Ttl: ttl, TtlMs: ttl.Milliseconds(), Nodes: 1})
+	}
+	sort.Slice(locks, func(i, j int) bool {
+		return locks[i].Resource < locks[j].Resource
+	})
+	return locks, nil
+}
+
+// ValidateFence reports whether fence is the latest fencing token issued for the resource
+func (m *inMemoryLocker) ValidateFence(ctx context.Context, resource string, fence int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return false, InternalError
+	}
+	return fence > 0 && fence == m.fences[resource], nil
+}
+
+// Close stops every expiry timer. The operations attempted afterwards fail, acquisitions with
+// QuorumUnavailableError, as with a closed Redis locker.
+func (m *inMemoryLocker) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, lock := range m.locks {
+		lock.timer.Stop()
+	}
+	for resource := range m.shared {
+		for _, h := range m.shared[resource] {
+			h.timer.Stop()
+		}
+	}
+	m.closed = true
+	return nil
+}
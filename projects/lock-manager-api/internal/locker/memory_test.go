@@ -0,0 +1,253 @@
+package locker
+
+import (
+	"errors"
+	"golang.org/x/net/context"
+	"testing"
+	"time"
+)
+
+func TestInMemoryLockerAcquireAndRelease(t *testing.T) {
+	l := NewInMemoryLocker()
+	ctx := context.Background()
+
+	lock, err := l.Acquire(ctx, "item-1", time.Second)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	if lock.NodesAcked != 1 || lock.TtlMs != 1000 {
+		t.Errorf("lock = %+v, want one node and a 1000ms TTL", lock)
+	}
+
+	_, err = l.Acquire(ctx, "item-1", time.Second)
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || !errors.Is(err, AcquireLockError) {
+		t.Fatalf("second Acquire error = %v, want a *ConflictError", err)
+	}
+
+	if err := l.Release(ctx, "item-1", "not-the-token"); !errors.Is(err, LockNotFoundError) {
+		t.Errorf("Release with another token error = %v, want LockNotFoundError", err)
+	}
+	if err := l.Release(ctx, "item-1", lock.Token); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if _, err := l.Acquire(ctx, "item-1", time.Second); err != nil {
+		t.Errorf("Acquire after the release: %v", err)
+	}
+}
+
+func TestInMemoryLockerExpiresLocks(t *testing.T) {
+	l := NewInMemoryLocker()
+	ctx := context.Background()
+
+	lock, err := l.Acquire(ctx, "item-1", 20*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	if _, _, err := l.TTL(ctx, "item-1", lock.Token); !errors.Is(err, LockNotFoundError) {
+		t.Errorf("TTL of an expired lock error = %v, want LockNotFoundError", err)
+	}
+	if _, err := l.Acquire(ctx, "item-1", time.Second); err != nil {
+		t.Errorf("Acquire of an expired lock: %v", err)
+	}
+}
+
+func TestInMemoryLockerRefreshAndTTL(t *testing.T) {
+	l := NewInMemoryLocker()
+	ctx := context.Background()
+
+	lock, err := l.Acquire(ctx, "item-1", 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	if _, err := l.Refresh(ctx, "item-1", "not-the-token", time.Minute); !errors.Is(err, LockNotFoundError) {
+		t.Errorf("Refresh with another token error = %v, want LockNotFoundError", err)
+	}
+	if refreshed, err := l.Refresh(ctx, "item-1", lock.Token, time.Minute); err != nil || refreshed != 1 {
+		t.Fatalf("Refresh = %d, %v, want 1 node", refreshed, err)
+	}
+
+	// Past the original TTL, the refreshed lock is still held
+	time.Sleep(60 * time.Millisecond)
+	ttl, _, err := l.TTL(ctx, "item-1", lock.Token)
+	if err != nil {
+		t.Fatalf("TTL: %v", err)
+	}
+	if ttl <= 59*time.Second || ttl > time.Minute {
+		t.Errorf("TTL = %s, want about a minute", ttl)
+	}
+}
+
+func TestInMemoryLockerIssuesIncreasingFences(t *testing.T) {
+	l := NewInMemoryLocker()
+	ctx := context.Background()
+
+	first, err := l.Acquire(ctx, "item-1", time.Second)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	if err := l.Release(ctx, "item-1", first.Token); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	second, err := l.Acquire(ctx, "item-1", time.Second)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+
+	if second.Fence <= first.Fence {
+		t.Errorf("fences %d then %d, want them increasing", first.Fence, second.Fence)
+	}
+	if valid, _ := l.ValidateFence(ctx, "item-1", first.Fence); valid {
+		t.Error("a superseded fence was reported valid")
+	}
+	if valid, _ := l.ValidateFence(ctx, "item-1", second.Fence); !valid {
+		t.Error("the latest fence was reported invalid")
+	}
+}
+
+func TestInMemoryLockerSharedHolders(t *testing.T) {
+	l := NewInMemoryLocker()
+	ctx := context.Background()
+
+	first, err := l.AcquireShared(ctx, "item-1", time.Second)
+	if err != nil {
+		t.Fatalf("AcquireShared: %v", err)
+	}
+	second, err := l.AcquireShared(ctx, "item-1", time.Second)
+	if err != nil {
+		t.Fatalf("second AcquireShared: %v", err)
+	}
+	if _, err := l.Acquire(ctx, "item-1", time.Second); !errors.Is(err, AcquireLockError) {
+		t.Errorf("exclusive Acquire under shared holders error = %v, want AcquireLockError", err)
+	}
+
+	if remaining, err := l.ReleaseRemaining(ctx, "item-1", first.Token); err != nil || remaining != 1 {
+		t.Errorf("ReleaseRemaining = %d, %v, want 1 holder left", remaining, err)
+	}
+	if remaining, err := l.ReleaseRemaining(ctx, "item-1", second.Token); err != nil || remaining != 0 {
+		t.Errorf("ReleaseRemaining = %d, %v, want no holder left", remaining, err)
+	}
+	if _, err := l.Acquire(ctx, "item-1", time.Second); err != nil {
+		t.Errorf("Acquire once the shared holders left: %v", err)
+	}
+	if _, err := l.AcquireShared(ctx, "item-1", time.Second); !errors.Is(err, AcquireLockError) {
+		t.Errorf("AcquireShared under an exclusive lock error = %v, want AcquireLockError", err)
+	}
+}
+
+func TestInMemoryLockerReentrantLock(t *testing.T) {
+	l := NewInMemoryLocker()
+	ctx := context.Background()
+
+	lock, err := l.AcquireReentrant(ctx, "item-1", "worker-1", time.Second)
+	if err != nil {
+		t.Fatalf("AcquireReentrant: %v", err)
+	}
+	if _, err := l.AcquireReentrant(ctx, "item-1", "worker-1", time.Second); err != nil {
+		t.Fatalf("re-entering AcquireReentrant: %v", err)
+	}
+	if _, err := l.AcquireReentrant(ctx, "item-1", "worker-2", time.Second); !errors.Is(err, AcquireLockError) {
+		t.Errorf("AcquireReentrant by another owner error = %v, want AcquireLockError", err)
+	}
+
+	// The first release only leaves one entry
+	if err := l.Release(ctx, "item-1", lock.Token); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if _, meta, err := l.TTL(ctx, "item-1", lock.Token); err != nil || meta.Owner != "worker-1" {
+		t.Errorf("TTL after one release = %+v, %v, want the lock still held by worker-1", meta, err)
+	}
+	if err := l.Release(ctx, "item-1", lock.Token); err != nil {
+		t.Fatalf("second Release: %v", err)
+	}
+	if _, _, err := l.TTL(ctx, "item-1", lock.Token); !errors.Is(err, LockNotFoundError) {
+		t.Errorf("TTL after both releases error = %v, want LockNotFoundError", err)
+	}
+}
+
+func TestInMemoryLockerAcquireMultiIsAllOrNone(t *testing.T) {
+	l := NewInMemoryLocker()
+	ctx := context.Background()
+
+	if _, err := l.Acquire(ctx, "item-2", time.Second); err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	if _, err := l.AcquireMulti(ctx, []string{"item-1", "item-2"}, time.Second); !errors.Is(err, AcquireLockError) {
+		t.Fatalf("AcquireMulti error = %v, want AcquireLockError", err)
+	}
+	if _, err := l.Acquire(ctx, "item-1", time.Second); err != nil {
+		t.Errorf("the refused AcquireMulti kept item-1: %v", err)
+	}
+
+	locks, err := l.AcquireMulti(ctx, []string{"item-4", "item-3"}, time.Second)
+	if err != nil {
+		t.Fatalf("AcquireMulti: %v", err)
+	}
+	if len(locks) != 2 || locks[0].Token != locks[1].Token {
+		t.Fatalf("locks = %+v, want two locks under one token", locks)
+	}
+	if released, err := l.ReleaseByToken(ctx, locks[0].Token); err != nil || released != 2 {
+		t.Errorf("ReleaseByToken = %d, %v, want 2 locks released", released, err)
+	}
+}
+
+func TestInMemoryLockerAcquireAnyN(t *testing.T) {
+	l := NewInMemoryLocker()
+	ctx := context.Background()
+
+	if _, err := l.Acquire(ctx, "slot-1", time.Second); err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+
+	locks, err := l.AcquireAnyN(ctx, []string{"slot-1", "slot-2", "slot-3", "slot-4"}, 2, time.Second)
+	if err != nil {
+		t.Fatalf("AcquireAnyN: %v", err)
+	}
+	if len(locks) != 2 || locks[0].Resource != "slot-2" || locks[1].Resource != "slot-3" {
+		t.Errorf("locks = %+v, want the first two free slots", locks)
+	}
+	if _, err := l.AcquireAnyN(ctx, []string{"slot-1", "slot-2", "slot-4"}, 2, time.Second); !errors.Is(err, AcquireLockError) {
+		t.Errorf("AcquireAnyN with one free slot error = %v, want AcquireLockError", err)
+	}
+}
+
+func TestInMemoryLockerListsLocksByPrefix(t *testing.T) {
+	l := NewInMemoryLocker()
+	ctx := context.Background()
+
+	for _, resource := range []string{"orders/2", "orders/1", "users/1"} {
+		if _, err := l.Acquire(ctx, resource, time.Second); err != nil {
+			t.Fatalf("Acquire: %v", err)
+		}
+	}
+
+	locks, err := l.List(ctx, "orders/")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(locks) != 2 || locks[0].Resource != "orders/1" || locks[1].Resource != "orders/2" {
+		t.Errorf("locks = %+v, want orders/1 and orders/2 in order", locks)
+	}
+}
+
+func TestInMemoryLockerFailsOnceClosed(t *testing.T) {
+	l := NewInMemoryLocker()
+	ctx := context.Background()
+
+	lock, err := l.Acquire(ctx, "item-1", time.Second)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if _, err := l.Acquire(ctx, "item-2", time.Second); !errors.Is(err, QuorumUnavailableError) {
+		t.Errorf("Acquire after Close error = %v, want QuorumUnavailableError", err)
+	}
+	if err := l.Release(ctx, "item-1", lock.Token); !errors.Is(err, InternalError) {
+		t.Errorf("Release after Close error = %v, want InternalError", err)
+	}
+}
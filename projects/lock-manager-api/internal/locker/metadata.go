@@ -0,0 +1,40 @@
+package locker
+
+import (
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/net/context"
+	"strconv"
+	"time"
+)
+
+// MetaKeyPrefix namespaces the per-resource hashes (token, owner, acquired_at) describing who holds an
+// exclusive lock. They expire, are refreshed and are deleted together with the lock key.
+const MetaKeyPrefix = "meta:"
+
+// Metadata describes who took a lock and when, for operators investigating a stuck lock
+type Metadata struct {
+	Owner      string    `json:"owner,omitempty"` // Set for locks acquired on behalf of an owner
+	AcquiredAt time.Time `json:"acquired_at"`
+}
+
+// readMetadata reads a node's metadata for the lock held with token, empty if it belongs to another token
+func readMetadata(ctx context.Context, node *redis.Client, resource string, token string) (Metadata, error) {
+	values, err := node.HMGet(ctx, MetaKeyPrefix+resource, "token", "owner", "acquired_at").Result()
+	if err != nil {
+		return Metadata{}, err
+	}
+	if holder, _ := values[0].(string); holder != token {
+		return Metadata{}, nil
+	}
+
+	var meta Metadata
+	meta.Owner, _ = values[1].(string)
+	if acquiredAt, _ := values[2].(string); acquiredAt != "" {
+		ms, err := strconv.ParseInt(acquiredAt, 10, 64)
+		if err != nil {
+			return Metadata{}, err
+		}
+		meta.AcquiredAt = time.UnixMilli(ms)
+	}
+	return meta, nil
+}
@@ -0,0 +1,72 @@
+package locker
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"golang.org/x/net/context"
+	"sort"
+	"time"
+)
+
+// AcquireMulti locks every resource under a single shared token, or none of them. Resources are locked
+// one at a time in sorted order, so concurrent batches over overlapping resources always contend in the
+// same order and cannot deadlock each other. On any failure the locks already taken are released.
+func (l *redLock) AcquireMulti(ctx context.Context, resources []string, ttl time.Duration) ([]*Locker, error) {
+	if len(resources) == 0 {
+		return nil, errors.New("no resources to lock")
+	}
+
+	sorted := make([]string, 0, len(resources))
+	seen := make(map[string]bool, len(resources))
+	for _, resource := range resources {
+		if err := l.validResource(resource); err != nil {
+			return nil, err
+		}
+		resource = l.canonical(resource)
+		if seen[resource] {
+			return nil, errors.New("duplicate resource " + l.resourceName(resource))
+		}
+		seen[resource] = true
+		sorted = append(sorted, resource)
+	}
+	sort.Strings(sorted)
+
+	token := uuid.New().String()
+	startTime := time.Now()
+	locks := make([]*Locker, 0, len(sorted))
+
+	for _, resource := range sorted {
+		lock, err := l.acquire(ctx, resource, token, ttl, exclusiveMode)
+		if err != nil {
+			l.releaseAll(ctx, locks)
+			return nil, err
+		}
+		locks = append(locks, lock)
+	}
+
+	// The first lock has been ticking since the batch started, so it bounds the validity of all of them
+	elapsed := time.Since(startTime)
+	validity := ttl - elapsed - clockDrift(ttl)
+	if validity <= 0 {
+		l.logger.Error("batch acquisition left no validity within ttl", "resources", sorted, "token", token, "elapsed", elapsed, "ttl", ttl)
+		l.releaseAll(ctx, locks)
+		return nil, TTLTooShortError
+	}
+
+	for _, lock := range locks {
+		lock.Elapsed = elapsed
+		lock.Validity = validity
+	}
+
+	return locks, nil
+}
+
+// releaseAll rolls back the locks taken so far by a batch. Their Resource is already canonical, so only
+// the namespace is put back instead of canonicalizing it again.
+func (l *redLock) releaseAll(ctx context.Context, locks []*Locker) {
+	for _, lock := range locks {
+		if _, err := l.releaseKey(ctx, l.namespace+lock.Resource, lock.Token); err != nil {
+			l.logger.Warn("error rolling back batch lock", "resource", lock.Resource, "token", lock.Token, "error", err)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package locker
+
+import (
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/net/context"
+	"sync"
+	"time"
+)
+
+// NodeSet is the locker's view of its Redis nodes: the votes of each node, the quorum, the timeout of a
+// call to a single node and the logger. The stores kept beside the locks (nonces, quotas, fair queues, ...)
+// are built on it so they agree with the locker on what a quorum is.
+type NodeSet struct {
+	nodes   []*redis.Client
+	weights map[*redis.Client]int
+	total   int // Sum of the votes of every node
+	quorum  int
+	timeout time.Duration
+	logger  Logger
+}
+
+// NewNodeSet builds the node set a locker created with the same nodes and options would vote over
+func NewNodeSet(redisNodes []*redis.Client, opts ...Option) (*NodeSet, error) {
+	l, err := newRedLock(redisNodes, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return l.nodeSet(), nil
+}
+
+// nodeSet returns the node set of the locker
+func (l *redLock) nodeSet() *NodeSet {
+	total := 0
+	for _, node := range l.redisNodes {
+		total += l.weight(node)
+	}
+	return &NodeSet{
+		nodes:   l.redisNodes,
+		weights: l.weights,
+		total:   total,
+		quorum:  l.quorum,
+		timeout: l.nodeTimeout,
+		logger:  l.logger,
+	}
+}
+
+// weight returns the votes of a node, 1 unless weights were configured
+func (s *NodeSet) weight(node *redis.Client) int {
+	if weight, ok := s.weights[node]; ok {
+		return weight
+	}
+	return 1
+}
+
+// run applies op to every node in parallel, each call bounded by the node timeout, and returns the votes
+// of the nodes where op succeeded reporting true and the votes of the nodes where it failed
+func (s *NodeSet) run(ctx context.Context, operation string, op func(ctx context.Context, node *redis.Client) (bool, error)) (int, int) {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	okVotes := 0
+	failedVotes := 0
+	errs := make([]error, 0)
+
+	for _, node := range s.nodes {
+		wg.Add(1)
+		go func(node *redis.Client) {
+			defer wg.Done()
+
+			nodeCtx, cancel := context.WithTimeout(ctx, s.timeout) // Timeout per node
+			defer cancel()
+
+			ok, err := op(nodeCtx, node)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failedVotes += s.weight(node)
+				errs = append(errs, fmt.Errorf("node %v: %w", node.Options().Addr, err))
+			} else if ok {
+				okVotes += s.weight(node)
+			}
+		}(node)
+	}
+
+	wg.Wait()
+
+	// Log errors if any
+	if len(errs) > 0 {
+		s.logger.Warn("errors while "+operation, "errors", errs)
+	}
+
+	return okVotes, failedVotes
+}
+
+// answered reports whether the nodes that didn't fail still hold a quorum of the votes
+func (s *NodeSet) answered(failedVotes int) bool {
+	return s.total-failedVotes >= s.quorum
+}
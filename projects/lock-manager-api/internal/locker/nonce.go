@@ -0,0 +1,78 @@
+package locker
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/net/context"
+	"time"
+)
+
+var NonceReplayError = errors.New("nonce already used")
+
+// NonceKeyPrefix namespaces nonce keys so they never collide with lock resources
+const NonceKeyPrefix = "nonce:"
+
+// forgetNonceScript deletes a nonce only while it still holds the given reservation, so undoing one
+// request never frees a nonce another request reserved.
+// KEYS[1] = nonce key, ARGV[1] = reservation
+var forgetNonceScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+end
+return 0
+`)
+
+type nonceStore struct {
+	nodes *NodeSet
+}
+
+// NonceStore reserves each nonce once. A request reserves its nonce before it runs, so two concurrent
+// replays can't both get through, and forgets it if it fails, so a failed request doesn't burn its nonce.
+type NonceStore interface {
+	Reserve(ctx context.Context, nonce string, expiry time.Duration) (string, error)
+	Forget(ctx context.Context, nonce string, reservation string) error
+}
+
+// NewNonceStore creates a Redis-backed store that remembers nonces across a quorum of nodes
+func NewNonceStore(nodes *NodeSet) NonceStore {
+	return &nonceStore{
+		nodes: nodes,
+	}
+}
+
+// Reserve records the nonce for the given expiry with SET NX on every node and returns the reservation
+// to give to Forget. It returns NonceReplayError unless a quorum of nodes recorded it for this call.
+func (s *nonceStore) Reserve(ctx context.Context, nonce string, expiry time.Duration) (string, error) {
+	reservation := uuid.New().String()
+
+	stored, failed := s.nodes.run(ctx, "reserving nonce", func(ctx context.Context, node *redis.Client) (bool, error) {
+		return node.SetNX(ctx, NonceKeyPrefix+nonce, reservation, expiry).Result()
+	})
+
+	if stored >= s.nodes.quorum {
+		return reservation, nil
+	}
+
+	// Undo the minority reservation, so a retry of a request that hit an outage can still use the nonce
+	_ = s.Forget(ctx, nonce, reservation)
+
+	// Not enough nodes answered to tell a replay from an outage
+	if !s.nodes.answered(failed) {
+		return "", InternalError
+	}
+
+	return "", NonceReplayError
+}
+
+// Forget drops the nonce reserved by Reserve, leaving it alone if another reservation holds it
+func (s *nonceStore) Forget(ctx context.Context, nonce string, reservation string) error {
+	_, failed := s.nodes.run(ctx, "forgetting nonce", func(ctx context.Context, node *redis.Client) (bool, error) {
+		return true, forgetNonceScript.Run(ctx, node, []string{NonceKeyPrefix + nonce}, reservation).Err()
+	})
+
+	if !s.nodes.answered(failed) {
+		return InternalError
+	}
+	return nil
+}
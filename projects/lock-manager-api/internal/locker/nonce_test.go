@@ -0,0 +1,92 @@
+package locker
+
+import (
+	"errors"
+	"golang.org/x/net/context"
+	"testing"
+	"time"
+)
+
+func TestNonceReservedOnce(t *testing.T) {
+	nodes, _ := newTestNodeSet(t, 3)
+	store := NewNonceStore(nodes)
+	ctx := context.Background()
+
+	if _, err := store.Reserve(ctx, "n-1", time.Minute); err != nil {
+		t.Fatalf("Reserve: %v", err)
+	}
+	if _, err := store.Reserve(ctx, "n-1", time.Minute); !errors.Is(err, NonceReplayError) {
+		t.Errorf("second Reserve error = %v, want NonceReplayError", err)
+	}
+	if _, err := store.Reserve(ctx, "n-2", time.Minute); err != nil {
+		t.Errorf("Reserve of another nonce: %v", err)
+	}
+}
+
+func TestForgottenNonceCanBeReservedAgain(t *testing.T) {
+	nodes, _ := newTestNodeSet(t, 3)
+	store := NewNonceStore(nodes)
+	ctx := context.Background()
+
+	reservation, err := store.Reserve(ctx, "n-1", time.Minute)
+	if err != nil {
+		t.Fatalf("Reserve: %v", err)
+	}
+	if err := store.Forget(ctx, "n-1", reservation); err != nil {
+		t.Fatalf("Forget: %v", err)
+	}
+	if _, err := store.Reserve(ctx, "n-1", time.Minute); err != nil {
+		t.Errorf("Reserve after Forget: %v", err)
+	}
+}
+
+func TestForgetLeavesAnotherRequestsReservation(t *testing.T) {
+	nodes, _ := newTestNodeSet(t, 3)
+	store := NewNonceStore(nodes)
+	ctx := context.Background()
+
+	if _, err := store.Reserve(ctx, "n-1", time.Minute); err != nil {
+		t.Fatalf("Reserve: %v", err)
+	}
+	if err := store.Forget(ctx, "n-1", "another-reservation"); err != nil {
+		t.Fatalf("Forget: %v", err)
+	}
+	if _, err := store.Reserve(ctx, "n-1", time.Minute); !errors.Is(err, NonceReplayError) {
+		t.Errorf("Reserve after a foreign Forget error = %v, want NonceReplayError", err)
+	}
+}
+
+func TestNonceReservationExpires(t *testing.T) {
+	nodes, servers := newTestNodeSet(t, 3)
+	store := NewNonceStore(nodes)
+	ctx := context.Background()
+
+	if _, err := store.Reserve(ctx, "n-1", time.Minute); err != nil {
+		t.Fatalf("Reserve: %v", err)
+	}
+	for _, server := range servers {
+		server.FastForward(2 * time.Minute)
+	}
+	if _, err := store.Reserve(ctx, "n-1", time.Minute); err != nil {
+		t.Errorf("Reserve after expiry: %v", err)
+	}
+}
+
+func TestNonceReserveTellsAnOutageFromAReplay(t *testing.T) {
+	nodes, servers := newTestNodeSet(t, 3)
+	store := NewNonceStore(nodes)
+	ctx := context.Background()
+
+	servers[1].SetError("node down")
+	servers[2].SetError("node down")
+	if _, err := store.Reserve(ctx, "n-1", time.Minute); !errors.Is(err, InternalError) {
+		t.Fatalf("Reserve without a quorum error = %v, want InternalError", err)
+	}
+
+	// The minority reservation was undone, so the nonce is still usable once the nodes are back
+	servers[1].SetError("")
+	servers[2].SetError("")
+	if _, err := store.Reserve(ctx, "n-1", time.Minute); err != nil {
+		t.Errorf("Reserve once the nodes are back: %v", err)
+	}
+}
@@ -0,0 +1,244 @@
+package locker
+
+import (
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/net/context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Keyspace event channels published by Redis when a key expires, is deleted or is set. The first two are
+// only emitted when the server runs with notify-keyspace-events containing "Exg", the last with "$" too.
+var releaseEventPatterns = []string{
+	"__keyevent@*__:expired",
+	"__keyevent@*__:del",
+	"__keyevent@*__:set",
+}
+
+// Lock events delivered by Watch, named after the keyspace event that produced them
+const (
+	EventAcquired = "acquired" // The lock key was set, by an exclusive acquisition
+	EventExpired  = "expired"  // The lock key reached its TTL
+	EventReleased = "released" // The lock key was deleted, by a release or an admin
+)
+
+// watchBuffer is how many events a watcher may lag behind before further events are dropped for it
+const watchBuffer = 16
+
+type releaseNotifier struct {
+	nodes        *NodeSet
+	canonicalize Canonicalizer
+	mu           sync.Mutex
+	waiters      map[string]map[chan struct{}]struct{}
+	watchers     map[string]map[chan string]struct{}
+	pending      map[string]*pendingEvent // Key and event -> the nodes that reported it so far
+}
+
+// pendingEvent gathers the nodes reporting the same event of a watched key, which is only passed on to
+// the watchers once they hold a quorum of the votes
+type pendingEvent struct {
+	since     time.Time
+	nodes     map[*redis.Client]bool
+	votes     int
+	confirmed bool
+}
+
+type ReleaseNotifier interface {
+	Start(ctx context.Context) error
+	Subscribe(resource string) (<-chan struct{}, func())
+	Watch(resource string) (<-chan string, func())
+}
+
+// NewReleaseNotifier creates a notifier that wakes waiters when a lock key disappears from any node.
+// canonicalize must be the one given to the locker, or nil, so waiters match the keys actually stored.
+func NewReleaseNotifier(nodes *NodeSet, canonicalize Canonicalizer) ReleaseNotifier {
+	return &releaseNotifier{
+		nodes:        nodes,
+		canonicalize: canonicalize,
+		waiters:      make(map[string]map[chan struct{}]struct{}),
+		watchers:     make(map[string]map[chan string]struct{}),
+		pending:      make(map[string]*pendingEvent),
+	}
+}
+
+// Start subscribes to the expired/del keyspace events on every node until ctx is cancelled, then closes
+// the channels of every watcher
+func (n *releaseNotifier) Start(ctx context.Context) error {
+	go func() {
+		<-ctx.Done()
+		n.closeWatchers()
+	}()
+
+	for _, node := range n.nodes.nodes {
+		pubsub := node.PSubscribe(ctx, releaseEventPatterns...)
+
+		// Wait for the subscription confirmation so misconfigured nodes are reported at startup
+		if _, err := pubsub.Receive(ctx); err != nil {
+			_ = pubsub.Close()
+			return err
+		}
+
+		go func(node *redis.Client, pubsub *redis.PubSub) {
+			defer pubsub.Close()
+
+			ch := pubsub.Channel()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case msg, ok := <-ch:
+					if !ok {
+						n.nodes.logger.Warn("keyspace notifications channel closed", "node", node.Options().Addr)
+						return
+					}
+					n.notify(node, msg.Channel, msg.Payload)
+				}
+			}
+		}(node, pubsub)
+	}
+
+	return nil
+}
+
+// Subscribe returns a channel closed the next time the resource key expires or is deleted on any node.
+// The returned function must be called to drop the subscription when the caller stops waiting.
+func (n *releaseNotifier) Subscribe(resource string) (<-chan struct{}, func()) {
+	if n.canonicalize != nil {
+		resource = n.canonicalize(resource)
+	}
+	ch := make(chan struct{})
+
+	n.mu.Lock()
+	if n.waiters[resource] == nil {
+		n.waiters[resource] = make(map[chan struct{}]struct{})
+	}
+	n.waiters[resource][ch] = struct{}{}
+	n.mu.Unlock()
+
+	cancel := func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		if waiters, ok := n.waiters[resource]; ok {
+			delete(waiters, ch)
+			if len(waiters) == 0 {
+				delete(n.waiters, resource)
+			}
+		}
+	}
+
+	return ch, cancel
+}
+
+// Watch returns a channel receiving EventAcquired, EventExpired or EventReleased each time the resource key
+// is set, expires or is deleted on a quorum of nodes, so an acquisition or a release that only reached a
+// minority of them is never reported. Events
+// are dropped for a watcher lagging more than a few events behind. The returned function must be called to stop watching, and the channel is closed when
+// the notifier stops.
+func (n *releaseNotifier) Watch(resource string) (<-chan string, func()) {
+	if n.canonicalize != nil {
+		resource = n.canonicalize(resource)
+	}
+	ch := make(chan string, watchBuffer)
+
+	n.mu.Lock()
+	if n.watchers[resource] == nil {
+		n.watchers[resource] = make(map[chan string]struct{})
+	}
+	n.watchers[resource][ch] = struct{}{}
+	n.mu.Unlock()
+
+	cancel := func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		if watchers, ok := n.watchers[resource]; ok {
+			delete(watchers, ch)
+			if len(watchers) == 0 {
+				delete(n.watchers, resource)
+				delete(n.pending, resource+"\x00"+EventAcquired)
+				delete(n.pending, resource+"\x00"+EventReleased)
+				delete(n.pending, resource+"\x00"+EventExpired)
+			}
+		}
+	}
+
+	return ch, cancel
+}
+
+// notify passes the event on to the watchers of the given key once a quorum of nodes reported it and, when
+// the key went away, wakes every waiter, since any node letting the key go is worth a new attempt
+func (n *releaseNotifier) notify(node *redis.Client, channel string, key string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	event := EventReleased
+	if strings.HasSuffix(channel, ":expired") {
+		event = EventExpired
+	} else if strings.HasSuffix(channel, ":set") {
+		event = EventAcquired
+	}
+	if n.confirm(node, key, event) {
+		n.send(key, event)
+	}
+	if event == EventAcquired {
+		return
+	}
+
+	waiters, ok := n.waiters[key]
+	if !ok {
+		return
+	}
+	for ch := range waiters {
+		close(ch)
+	}
+	delete(n.waiters, key)
+}
+
+// confirm records that node reported event for a watched key and reports whether that made a quorum of
+// votes. The nodes report a release within the node timeout of each other; past it, or once a node reports
+// the event again, a new release has started. The nodes reporting after the quorum are absorbed.
+func (n *releaseNotifier) confirm(node *redis.Client, key string, event string) bool {
+	if len(n.watchers[key]) == 0 {
+		return false
+	}
+
+	id := key + "\x00" + event
+	pending, ok := n.pending[id]
+	if !ok || pending.nodes[node] || time.Since(pending.since) > n.nodes.timeout {
+		pending = &pendingEvent{since: time.Now(), nodes: make(map[*redis.Client]bool)}
+		n.pending[id] = pending
+	}
+	pending.nodes[node] = true
+	pending.votes += n.nodes.weight(node)
+
+	if pending.confirmed || pending.votes < n.nodes.quorum {
+		return false
+	}
+	pending.confirmed = true
+	return true
+}
+
+// send passes an event on to the watchers of key, dropping it for those lagging behind
+func (n *releaseNotifier) send(key string, event string) {
+	for ch := range n.watchers[key] {
+		select {
+		case ch <- event:
+		default:
+			n.nodes.logger.Warn("dropping event for a slow watcher", "event", event, "key", key)
+		}
+	}
+}
+
+// closeWatchers ends every watch, once the notifier no longer receives events
+func (n *releaseNotifier) closeWatchers() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for key, watchers := range n.watchers {
+		for ch := range watchers {
+			close(ch)
+		}
+		delete(n.watchers, key)
+	}
+	n.pending = make(map[string]*pendingEvent)
+}
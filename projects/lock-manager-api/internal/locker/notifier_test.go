@@ -0,0 +1,159 @@
+package locker
+
+import (
+	"golang.org/x/net/context"
+	"testing"
+	"time"
+)
+
+func TestWatchReportsAnEventOnceAQuorumOfNodesSawIt(t *testing.T) {
+	nodes, _ := newTestNodeSet(t, 3)
+	n := NewReleaseNotifier(nodes, nil).(*releaseNotifier)
+
+	events, unwatch := n.Watch("item-1")
+	defer unwatch()
+
+	n.notify(nodes.nodes[0], "__keyevent@0__:del", "item-1")
+	assertNoEvent(t, events)
+
+	n.notify(nodes.nodes[1], "__keyevent@0__:del", "item-1")
+	assertEvent(t, events, EventReleased)
+
+	// The last node is absorbed into the release already reported
+	n.notify(nodes.nodes[2], "__keyevent@0__:del", "item-1")
+	assertNoEvent(t, events)
+}
+
+func TestWatchNamesTheEventAfterTheKeyspaceChannel(t *testing.T) {
+	nodes, _ := newTestNodeSet(t, 3)
+	n := NewReleaseNotifier(nodes, nil).(*releaseNotifier)
+
+	events, unwatch := n.Watch("item-1")
+	defer unwatch()
+
+	for channel, want := range map[string]string{
+		"__keyevent@0__:set":     EventAcquired,
+		"__keyevent@0__:expired": EventExpired,
+		"__keyevent@0__:del":     EventReleased,
+	} {
+		n.notify(nodes.nodes[0], channel, "item-1")
+		n.notify(nodes.nodes[1], channel, "item-1")
+		assertEvent(t, events, want)
+	}
+}
+
+func TestWatchCountsTheVotesOfEachNode(t *testing.T) {
+	nodes, _ := newTestNodeSet(t, 3, WithNodeWeights(3, 1, 1))
+	n := NewReleaseNotifier(nodes, nil).(*releaseNotifier)
+
+	events, unwatch := n.Watch("item-1")
+	defer unwatch()
+
+	// The two light nodes hold 2 votes out of a quorum of 3
+	n.notify(nodes.nodes[1], "__keyevent@0__:del", "item-1")
+	n.notify(nodes.nodes[2], "__keyevent@0__:del", "item-1")
+	assertNoEvent(t, events)
+
+	n.notify(nodes.nodes[0], "__keyevent@0__:expired", "item-1")
+	assertEvent(t, events, EventExpired)
+}
+
+func TestWatchTellsSuccessiveReleasesApart(t *testing.T) {
+	nodes, _ := newTestNodeSet(t, 3)
+	n := NewReleaseNotifier(nodes, nil).(*releaseNotifier)
+
+	events, unwatch := n.Watch("item-1")
+	defer unwatch()
+
+	// A node reporting the event again starts a new release, which needs its own quorum
+	n.notify(nodes.nodes[0], "__keyevent@0__:del", "item-1")
+	n.notify(nodes.nodes[0], "__keyevent@0__:del", "item-1")
+	assertNoEvent(t, events)
+	n.notify(nodes.nodes[1], "__keyevent@0__:del", "item-1")
+	assertEvent(t, events, EventReleased)
+}
+
+func TestSubscribeWakesWaitersOnAnyNodeRelease(t *testing.T) {
+	canonicalize, _ := NewCanonicalizer("lower")
+	nodes, _ := newTestNodeSet(t, 3)
+	n := NewReleaseNotifier(nodes, canonicalize).(*releaseNotifier)
+
+	released, cancel := n.Subscribe("Item-1")
+	defer cancel()
+
+	// An acquisition is no reason to retry
+	n.notify(nodes.nodes[0], "__keyevent@0__:set", "item-1")
+	select {
+	case <-released:
+		t.Fatal("the waiter was woken by an acquisition")
+	default:
+	}
+
+	n.notify(nodes.nodes[2], "__keyevent@0__:expired", "item-1")
+	select {
+	case <-released:
+	default:
+		t.Error("the waiter wasn't woken by an expiry on one node")
+	}
+}
+
+func TestStartListensToTheKeyspaceEventsOfEveryNode(t *testing.T) {
+	nodes, servers := newTestNodeSet(t, 3)
+	n := NewReleaseNotifier(nodes, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := n.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	events, unwatch := n.Watch("item-1")
+	defer unwatch()
+
+	// Published the way Redis does when notify-keyspace-events is enabled
+	servers[0].Publish("__keyevent@0__:expired", "item-1")
+	servers[2].Publish("__keyevent@0__:expired", "item-1")
+	select {
+	case event := <-events:
+		if event != EventExpired {
+			t.Errorf("event = %q, want %q", event, EventExpired)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no event received")
+	}
+
+	cancel()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Error("an event was received after the notifier stopped")
+		}
+	case <-time.After(time.Second):
+		t.Error("the watch wasn't closed when the notifier stopped")
+	}
+}
+
+// assertEvent checks the next event received by a watcher
+func assertEvent(t *testing.T, events <-chan string, want string) {
+	t.Helper()
+
+	select {
+	case event := <-events:
+		if event != want {
+			t.Errorf("event = %q, want %q", event, want)
+		}
+	default:
+		t.Errorf("no event received, want %q", want)
+	}
+}
+
+// assertNoEvent checks that a watcher has no event waiting
+func assertNoEvent(t *testing.T, events <-chan string) {
+	t.Helper()
+
+	select {
+	case event := <-events:
+		t.Errorf("unexpected event %q", event)
+	default:
+	}
+}
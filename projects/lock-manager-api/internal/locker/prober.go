@@ -0,0 +1,120 @@
+package locker
+
+import (
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/net/context"
+	"sync"
+	"time"
+)
+
+// NodeStatus is whether a node answered the last probe
+type NodeStatus struct {
+	Addr string
+	Up   bool
+}
+
+// QuorumStatus is the outcome of the last probe of every node
+type QuorumStatus struct {
+	Nodes     []NodeStatus
+	UpVotes   int
+	Quorum    int
+	Available bool // The nodes up hold a quorum of votes
+	CheckedAt time.Time
+}
+
+type quorumProber struct {
+	redisNodes []*redis.Client
+	weights    []int
+	interval   time.Duration
+	mu         sync.Mutex
+	status     QuorumStatus
+}
+
+type QuorumProber interface {
+	Start(ctx context.Context)
+	Status() QuorumStatus
+}
+
+// NewQuorumProber creates a prober that pings every node each interval, independently of the lock traffic,
+// to tell whether a quorum is currently reachable. weights are the node weights given to the locker, if any.
+func NewQuorumProber(redisNodes []*redis.Client, weights []int, interval time.Duration) QuorumProber {
+	return &quorumProber{
+		redisNodes: redisNodes,
+		weights:    weights,
+		interval:   interval,
+		status:     QuorumStatus{Quorum: WeightedQuorum(len(redisNodes), weights)},
+	}
+}
+
+// Start probes the nodes right away, then every interval until ctx is cancelled
+func (p *quorumProber) Start(ctx context.Context) {
+	p.probe(ctx)
+
+	go func() {
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				p.probe(ctx)
+			}
+		}
+	}()
+}
+
+func (p *quorumProber) Status() QuorumStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	status := p.status
+	status.Nodes = append([]NodeStatus(nil), p.status.Nodes...)
+	return status
+}
+
+// probe pings every node in parallel and records which ones answered
+func (p *quorumProber) probe(ctx context.Context) {
+	var wg sync.WaitGroup
+	nodes := make([]NodeStatus, len(p.redisNodes))
+
+	for i, node := range p.redisNodes {
+		wg.Add(1)
+		go func(i int, node *redis.Client) {
+			defer wg.Done()
+
+			nodeCtx, cancel := context.WithTimeout(ctx, DefaultNodeTimeout) // Timeout per node
+			defer cancel()
+
+			nodes[i] = NodeStatus{Addr: node.Options().Addr, Up: node.Ping(nodeCtx).Err() == nil}
+		}(i, node)
+	}
+
+	wg.Wait()
+
+	upVotes := 0
+	for i, node := range nodes {
+		if node.Up {
+			upVotes += p.weight(i)
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.status = QuorumStatus{
+		Nodes:     nodes,
+		UpVotes:   upVotes,
+		Quorum:    p.status.Quorum,
+		Available: upVotes >= p.status.Quorum,
+		CheckedAt: time.Now(),
+	}
+}
+
+// weight returns the votes of the i-th node
+func (p *quorumProber) weight(i int) int {
+	if len(p.weights) == len(p.redisNodes) {
+		return p.weights[i]
+	}
+	return 1
+}
@@ -0,0 +1,237 @@
+package locker
+
+import (
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/net/context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var QuotaExceededError = errors.New("client holds the maximum number of locks")
+
+// QuotaKeyPrefix namespaces the per-client sorted sets of held lock entries and pending reservations,
+// scored by expiry
+const QuotaKeyPrefix = "quota:"
+
+// reserveScript drops expired entries and adds every reservation only if the client stays within the cap.
+// KEYS[1] = client set, ARGV[1] = now (ms), ARGV[2] = cap, ARGV[3] = lock expiry (ms), ARGV[4..] = reservations
+var reserveScript = redis.NewScript(`
+redis.call("zremrangebyscore", KEYS[1], "-inf", ARGV[1])
+if redis.call("zcard", KEYS[1]) + #ARGV - 3 > tonumber(ARGV[2]) then
+	return 0
+end
+for i = 4, #ARGV do
+	redis.call("zadd", KEYS[1], ARGV[3], ARGV[i])
+end
+local last = redis.call("zrange", KEYS[1], -1, -1, "WITHSCORES")
+redis.call("pexpireat", KEYS[1], last[2])
+return 1
+`)
+
+// bindScript replaces each reservation with the entry of the lock acquired under it. An entry already
+// counted, because the acquisition re-entered or re-acquired a lock the client holds, is kept once.
+// KEYS[1] = client set, ARGV[1] = lock expiry (ms), ARGV[2..] = reservation and entry pairs
+var bindScript = redis.NewScript(`
+for i = 2, #ARGV, 2 do
+	redis.call("zrem", KEYS[1], ARGV[i])
+	local current = redis.call("zscore", KEYS[1], ARGV[i + 1])
+	if not current or tonumber(current) < tonumber(ARGV[1]) then
+		redis.call("zadd", KEYS[1], ARGV[1], ARGV[i + 1])
+	end
+end
+local last = redis.call("zrange", KEYS[1], -1, -1, "WITHSCORES")
+redis.call("pexpireat", KEYS[1], last[2])
+return 1
+`)
+
+// releaseTokenScript removes the entries of every lock held with a token.
+// KEYS[1] = client set, ARGV[1] = entry prefix of the token
+var releaseTokenScript = redis.NewScript(`
+for _, member in ipairs(redis.call("zrange", KEYS[1], 0, -1)) do
+	if string.sub(member, 1, #ARGV[1]) == ARGV[1] then
+		redis.call("zrem", KEYS[1], member)
+	end
+end
+return 1
+`)
+
+// QuotaEntry is the member counting the lock held on the canonical resource with token. Locks acquired
+// together share their token, so each of them is counted under its own resource.
+func QuotaEntry(resource string, token string) string {
+	return token + "\x00" + resource
+}
+
+type quotaStore struct {
+	nodes    *NodeSet
+	maxLocks int
+}
+
+// QuotaStore caps how many locks a client may hold at once, across every lock-manager instance. A slot is
+// reserved before acquiring each lock, then bound to the QuotaEntry of the lock acquired, or released if
+// none was.
+type QuotaStore interface {
+	Reserve(ctx context.Context, client string, ttl time.Duration, reservations ...string) error
+	Bind(ctx context.Context, client string, ttl time.Duration, bindings map[string]string) error
+	Extend(ctx context.Context, client string, entry string, ttl time.Duration) error
+	Release(ctx context.Context, client string, entries ...string) error
+	ReleaseToken(ctx context.Context, client string, token string) error
+	Counts(ctx context.Context) (map[string]int, error)
+}
+
+// NewQuotaStore creates a Redis-backed store allowing each client at most maxLocks held locks
+func NewQuotaStore(nodes *NodeSet, maxLocks int) QuotaStore {
+	return &quotaStore{
+		nodes:    nodes,
+		maxLocks: maxLocks,
+	}
+}
+
+// Reserve takes a slot for each lock about to be acquired, all of them or none, returning
+// QuotaExceededError when they would take the client past the cap. The reservations expire after ttl, so
+// those left behind by a failed request stop counting on their own.
+func (q *quotaStore) Reserve(ctx context.Context, client string, ttl time.Duration, reservations ...string) error {
+	now := time.Now()
+	args := []interface{}{now.UnixMilli(), q.maxLocks, now.Add(ttl).UnixMilli()}
+	for _, reservation := range reservations {
+		args = append(args, reservation)
+	}
+
+	reserved, failed := q.nodes.run(ctx, "reserving lock quota", func(nodeCtx context.Context, node *redis.Client) (bool, error) {
+		res, err := reserveScript.Run(nodeCtx, node, []string{QuotaKeyPrefix + client}, args...).Int()
+		return res == 1, err
+	})
+
+	if reserved >= q.nodes.quorum {
+		return nil
+	}
+
+	// Undo the partial reservations so the client isn't charged for refused locks. It only removes these
+	// reservations, never the entry of a lock the client already holds.
+	_ = q.Release(ctx, client, reservations...)
+
+	if !q.nodes.answered(failed) {
+		return InternalError
+	}
+	return QuotaExceededError
+}
+
+// Bind turns each reservation into the entry it maps to, that of the lock acquired under it, which expires
+// with the lock
+func (q *quotaStore) Bind(ctx context.Context, client string, ttl time.Duration, bindings map[string]string) error {
+	args := []interface{}{time.Now().Add(ttl).UnixMilli()}
+	for reservation, entry := range bindings {
+		args = append(args, reservation, entry)
+	}
+
+	_, failed := q.nodes.run(ctx, "binding lock quota", func(nodeCtx context.Context, node *redis.Client) (bool, error) {
+		return true, bindScript.Run(nodeCtx, node, []string{QuotaKeyPrefix + client}, args...).Err()
+	})
+
+	if !q.nodes.answered(failed) {
+		return InternalError
+	}
+	return nil
+}
+
+// Extend moves the expiry of a client's entry after the lock is refreshed
+func (q *quotaStore) Extend(ctx context.Context, client string, entry string, ttl time.Duration) error {
+	expiry := float64(time.Now().Add(ttl).UnixMilli())
+
+	_, failed := q.nodes.run(ctx, "extending lock quota", func(nodeCtx context.Context, node *redis.Client) (bool, error) {
+		key := QuotaKeyPrefix + client
+		if err := node.ZAddXX(nodeCtx, key, redis.Z{Score: expiry, Member: entry}).Err(); err != nil {
+			return false, err
+		}
+		return true, node.PExpireAt(nodeCtx, key, time.UnixMilli(int64(expiry))).Err()
+	})
+
+	if !q.nodes.answered(failed) {
+		return InternalError
+	}
+	return nil
+}
+
+// Release stops counting released locks, or unused reservations, against their client
+func (q *quotaStore) Release(ctx context.Context, client string, entries ...string) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	members := make([]interface{}, 0, len(entries))
+	for _, entry := range entries {
+		members = append(members, entry)
+	}
+
+	_, failed := q.nodes.run(ctx, "releasing lock quota", func(nodeCtx context.Context, node *redis.Client) (bool, error) {
+		return true, node.ZRem(nodeCtx, QuotaKeyPrefix+client, members...).Err()
+	})
+
+	if !q.nodes.answered(failed) {
+		return InternalError
+	}
+	return nil
+}
+
+// ReleaseToken stops counting every lock held with token against its client
+func (q *quotaStore) ReleaseToken(ctx context.Context, client string, token string) error {
+	_, failed := q.nodes.run(ctx, "releasing lock quota", func(nodeCtx context.Context, node *redis.Client) (bool, error) {
+		return true, releaseTokenScript.Run(nodeCtx, node, []string{QuotaKeyPrefix + client}, QuotaEntry("", token)).Err()
+	})
+
+	if !q.nodes.answered(failed) {
+		return InternalError
+	}
+	return nil
+}
+
+// Counts returns the number of unexpired locks held by each client, as agreed by a quorum of nodes
+func (q *quotaStore) Counts(ctx context.Context) (map[string]int, error) {
+	var mu sync.Mutex
+	perClient := make(map[string][]quotaCount)
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+
+	_, failed := q.nodes.run(ctx, "counting lock quota", func(nodeCtx context.Context, node *redis.Client) (bool, error) {
+		iter := node.Scan(nodeCtx, 0, QuotaKeyPrefix+"*", 100).Iterator()
+		for iter.Next(nodeCtx) {
+			count, err := node.ZCount(nodeCtx, iter.Val(), "("+now, "+inf").Result()
+			if err != nil {
+				return false, err
+			}
+			mu.Lock()
+			client := strings.TrimPrefix(iter.Val(), QuotaKeyPrefix)
+			perClient[client] = append(perClient[client], quotaCount{count: int(count), votes: q.nodes.weight(node)})
+			mu.Unlock()
+		}
+		return true, iter.Err()
+	})
+
+	if !q.nodes.answered(failed) {
+		return nil, InternalError
+	}
+
+	// A count is only trusted when a quorum of votes reports at least that much
+	counts := make(map[string]int, len(perClient))
+	for client, values := range perClient {
+		sort.Slice(values, func(i, j int) bool { return values[i].count > values[j].count })
+		votes := 0
+		for _, value := range values {
+			votes += value.votes
+			if votes >= q.nodes.quorum {
+				if value.count > 0 {
+					counts[client] = value.count
+				}
+				break
+			}
+		}
+	}
+	return counts, nil
+}
+
+// quotaCount is the number of locks a node counts for a client, with the votes of that node
+type quotaCount struct {
+	count int
+	votes int
+}
@@ -0,0 +1,168 @@
+package locker
+
+import (
+	"errors"
+	"golang.org/x/net/context"
+	"testing"
+	"time"
+)
+
+func TestQuotaReserveStopsAtTheCap(t *testing.T) {
+	nodes, _ := newTestNodeSet(t, 3)
+	quota := NewQuotaStore(nodes, 2)
+	ctx := context.Background()
+
+	if err := quota.Reserve(ctx, "client", time.Minute, "r-1"); err != nil {
+		t.Fatalf("first Reserve: %v", err)
+	}
+	if err := quota.Reserve(ctx, "client", time.Minute, "r-2"); err != nil {
+		t.Fatalf("second Reserve: %v", err)
+	}
+	if err := quota.Reserve(ctx, "client", time.Minute, "r-3"); !errors.Is(err, QuotaExceededError) {
+		t.Errorf("Reserve past the cap error = %v, want QuotaExceededError", err)
+	}
+	if err := quota.Reserve(ctx, "other-client", time.Minute, "r-1"); err != nil {
+		t.Errorf("Reserve for another client: %v", err)
+	}
+}
+
+func TestQuotaReservesABatchAllOrNone(t *testing.T) {
+	nodes, _ := newTestNodeSet(t, 3)
+	quota := NewQuotaStore(nodes, 3)
+	ctx := context.Background()
+
+	if err := quota.Reserve(ctx, "client", time.Minute, "r-1", "r-2"); err != nil {
+		t.Fatalf("Reserve of two slots: %v", err)
+	}
+	if err := quota.Reserve(ctx, "client", time.Minute, "r-3", "r-4"); !errors.Is(err, QuotaExceededError) {
+		t.Fatalf("Reserve of two more slots error = %v, want QuotaExceededError", err)
+	}
+
+	// The refused batch took no slot, so the last one is still free
+	if err := quota.Reserve(ctx, "client", time.Minute, "r-3"); err != nil {
+		t.Errorf("Reserve of the last slot: %v", err)
+	}
+}
+
+func TestQuotaCountsEachLockOfASharedToken(t *testing.T) {
+	nodes, _ := newTestNodeSet(t, 3)
+	quota := NewQuotaStore(nodes, 10)
+	ctx := context.Background()
+
+	if err := quota.Reserve(ctx, "client", time.Minute, "r-1", "r-2", "r-3"); err != nil {
+		t.Fatalf("Reserve: %v", err)
+	}
+
+	// Two locks acquired together, under one token, and a reservation left unused
+	err := quota.Bind(ctx, "client", time.Minute, map[string]string{
+		"r-1": QuotaEntry("item-1", "token"),
+		"r-2": QuotaEntry("item-2", "token"),
+	})
+	if err != nil {
+		t.Fatalf("Bind: %v", err)
+	}
+	if err := quota.Release(ctx, "client", "r-3"); err != nil {
+		t.Fatalf("Release of the unused reservation: %v", err)
+	}
+	assertQuotaCount(t, quota, "client", 2)
+
+	if err := quota.Release(ctx, "client", QuotaEntry("item-1", "token")); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	assertQuotaCount(t, quota, "client", 1)
+}
+
+func TestQuotaBindCountsAReentryOnce(t *testing.T) {
+	nodes, _ := newTestNodeSet(t, 3)
+	quota := NewQuotaStore(nodes, 10)
+	ctx := context.Background()
+
+	for _, reservation := range []string{"r-1", "r-2"} {
+		if err := quota.Reserve(ctx, "client", time.Minute, reservation); err != nil {
+			t.Fatalf("Reserve: %v", err)
+		}
+		if err := quota.Bind(ctx, "client", time.Minute, map[string]string{reservation: QuotaEntry("item-1", "token")}); err != nil {
+			t.Fatalf("Bind: %v", err)
+		}
+	}
+	assertQuotaCount(t, quota, "client", 1)
+}
+
+func TestQuotaReleaseTokenDropsEveryLockOfTheToken(t *testing.T) {
+	nodes, _ := newTestNodeSet(t, 3)
+	quota := NewQuotaStore(nodes, 10)
+	ctx := context.Background()
+
+	if err := quota.Reserve(ctx, "client", time.Minute, "r-1", "r-2", "r-3"); err != nil {
+		t.Fatalf("Reserve: %v", err)
+	}
+	err := quota.Bind(ctx, "client", time.Minute, map[string]string{
+		"r-1": QuotaEntry("item-1", "token"),
+		"r-2": QuotaEntry("item-2", "token"),
+		"r-3": QuotaEntry("item-1", "other-token"),
+	})
+	if err != nil {
+		t.Fatalf("Bind: %v", err)
+	}
+
+	if err := quota.ReleaseToken(ctx, "client", "token"); err != nil {
+		t.Fatalf("ReleaseToken: %v", err)
+	}
+	assertQuotaCount(t, quota, "client", 1)
+}
+
+func TestQuotaEntriesExpireWithTheirLock(t *testing.T) {
+	nodes, servers := newTestNodeSet(t, 3)
+	quota := NewQuotaStore(nodes, 1)
+	ctx := context.Background()
+
+	if err := quota.Reserve(ctx, "client", 50*time.Millisecond, "r-1"); err != nil {
+		t.Fatalf("Reserve: %v", err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	for _, server := range servers {
+		server.FastForward(60 * time.Millisecond)
+	}
+
+	if err := quota.Reserve(ctx, "client", time.Minute, "r-2"); err != nil {
+		t.Errorf("Reserve once the previous reservation expired: %v", err)
+	}
+}
+
+func TestQuotaExtendMovesTheExpiry(t *testing.T) {
+	nodes, servers := newTestNodeSet(t, 3)
+	quota := NewQuotaStore(nodes, 10)
+	ctx := context.Background()
+
+	entry := QuotaEntry("item-1", "token")
+	if err := quota.Reserve(ctx, "client", time.Second, "r-1"); err != nil {
+		t.Fatalf("Reserve: %v", err)
+	}
+	if err := quota.Bind(ctx, "client", time.Second, map[string]string{"r-1": entry}); err != nil {
+		t.Fatalf("Bind: %v", err)
+	}
+	if err := quota.Extend(ctx, "client", entry, time.Hour); err != nil {
+		t.Fatalf("Extend: %v", err)
+	}
+
+	score, err := servers[0].ZScore(QuotaKeyPrefix+"client", entry)
+	if err != nil {
+		t.Fatalf("ZScore: %v", err)
+	}
+	if expiry := time.UnixMilli(int64(score)); time.Until(expiry) < 59*time.Minute {
+		t.Errorf("entry expires at %s, want about an hour from now", expiry)
+	}
+}
+
+// assertQuotaCount checks the number of locks a quorum of nodes counts for client
+func assertQuotaCount(t *testing.T, quota QuotaStore, client string, want int) {
+	t.Helper()
+
+	counts, err := quota.Counts(context.Background())
+	if err != nil {
+		t.Fatalf("Counts: %v", err)
+	}
+	if counts[client] != want {
+		t.Errorf("count of '%s' = %d, want %d", client, counts[client], want)
+	}
+}
@@ -0,0 +1,40 @@
+package locker
+
+import (
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/net/context"
+	"io"
+	"net"
+	"syscall"
+)
+
+// withReconnect runs op against a node and, if it failed because the connection dropped, runs it once
+// more. go-redis discards the broken connection and dials a new one from the pool, so a transient drop
+// costs a single retry instead of counting the node as failed for the whole operation.
+// op must be safe to run twice.
+func (l *redLock) withReconnect(ctx context.Context, node *redis.Client, operation string, op func() error) error {
+	err := op()
+	if !isConnectionDrop(err) || ctx.Err() != nil {
+		return err
+	}
+
+	l.logger.Warn("connection to node dropped, retrying once", "node", node.Options().Addr, "operation", operation, "error", err)
+	return op()
+}
+
+// isConnectionDrop reports whether err means the connection was lost, as opposed to a Redis reply,
+// a missing key or the caller's deadline
+func isConnectionDrop(err error) bool {
+	if err == nil || errors.Is(err, redis.Nil) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) || errors.Is(err, net.ErrClosed) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && !netErr.Timeout()
+}
@@ -0,0 +1,163 @@
+package locker
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/net/context"
+	"sync"
+	"time"
+)
+
+// ReentryKeyPrefix namespaces the per-resource hashes (token, owner, count) of reentrant locks
+const ReentryKeyPrefix = "reentry:"
+
+// acquireReentrantScript takes the exclusive lock for owner or, if owner already holds it, increments
+// its reentrancy count and extends it. Returns the token holding the lock for owner, or false if refused.
+// KEYS[1] = resource, KEYS[2] = shared holders set, KEYS[3] = reentry hash, KEYS[4] = metadata hash,
+// ARGV[1] = new token, ARGV[2] = ttl (ms), ARGV[3] = now (ms), ARGV[4] = owner
+var acquireReentrantScript = redis.NewScript(`
+local holder = redis.call("get", KEYS[1])
+if holder then
+	if redis.call("hget", KEYS[3], "owner") ~= ARGV[4] or redis.call("hget", KEYS[3], "token") ~= holder then
+		return false
+	end
+	redis.call("hincrby", KEYS[3], "count", 1)
+	redis.call("pexpire", KEYS[1], ARGV[2])
+	redis.call("pexpire", KEYS[3], ARGV[2])
+	redis.call("pexpire", KEYS[4], ARGV[2])
+	return holder
+end
+redis.call("zremrangebyscore", KEYS[2], "-inf", ARGV[3])
+if redis.call("zcard", KEYS[2]) > 0 then
+	return false
+end
+redis.call("set", KEYS[1], ARGV[1], "PX", ARGV[2])
+redis.call("del", KEYS[3])
+redis.call("hset", KEYS[3], "token", ARGV[1], "owner", ARGV[4], "count", 1)
+redis.call("pexpire", KEYS[3], ARGV[2])
+redis.call("del", KEYS[4])
+redis.call("hset", KEYS[4], "token", ARGV[1], "owner", ARGV[4], "acquired_at", ARGV[3])
+redis.call("pexpire", KEYS[4], ARGV[2])
+return ARGV[1]
+`)
+
+// AcquireReentrant acquires the exclusive lock on behalf of owner. If owner already holds it, the
+// existing token is returned and its reentrancy count incremented instead of failing, and Release
+// must then be called once per acquisition before the lock is actually freed. Each acquisition also
+// extends the lock to ttl.
+func (l *redLock) AcquireReentrant(ctx context.Context, resource string, owner string, ttl time.Duration) (*Locker, error) {
+	if owner == "" {
+		return nil, errors.New("owner must not be empty")
+	}
+	if err := l.validResource(resource); err != nil {
+		return nil, err
+	}
+	resource = l.canonical(resource)
+
+	newToken := uuid.New().String()
+	startTime := time.Now()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	holders := make(map[*redis.Client]string) // Token each node granted the lock to
+	errs := make([]error, 0)
+
+	// Parallelize the lock acquisition attempt on each Redis node. Not retried on a dropped
+	// connection, since a second run would count the reentry twice.
+	for _, node := range l.redisNodes {
+		wg.Add(1)
+		go func(node *redis.Client) {
+			defer wg.Done()
+
+			nodeCtx, cancel := context.WithTimeout(ctx, l.nodeTimeout) // Timeout per node
+			defer cancel()
+
+			holder, err := acquireReentrantScript.Run(nodeCtx, node, lockKeys(resource),
+				newToken, ttlMillis(ttl), time.Now().UnixMilli(), owner).Text()
+			if errors.Is(err, redis.Nil) {
+				return // Held by someone else
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("error on node %v: %w", node.Options().Addr, err))
+				return
+			}
+			holders[node] = holder
+		}(node)
+	}
+
+	wg.Wait()
+
+	// Log errors if any
+	if len(errs) > 0 {
+		l.logger.Warn("errors while acquiring reentrant lock", "resource", resource, "errors", errs)
+	}
+
+	// The nodes may disagree: some granted a fresh lock while others re-entered the owner's lock.
+	// The token granted by a quorum wins and every other grant is undone.
+	votes := make(map[string]int)
+	acked := make(map[string]int)
+	for node, holder := range holders {
+		votes[holder] += l.weight(node)
+		acked[holder]++
+	}
+	token := ""
+	for holder, count := range votes {
+		if count >= l.quorum {
+			token = holder
+		}
+	}
+
+	var fence int64
+	var err error
+	if token != "" {
+		fence, err = l.nextFence(ctx, resource)
+	}
+
+	elapsed := time.Since(startTime)
+	validity := ttl - elapsed - clockDrift(ttl)
+	if token != "" && err == nil && validity > 0 {
+		l.undoGrants(ctx, resource, holders, token)
+		return &Locker{
+			TtlMs:      ttl.Milliseconds(),
+			Token:      token,
+			Resource:   l.resourceName(resource),
+			Elapsed:    elapsed,
+			Validity:   validity,
+			NodesAcked: acked[token],
+			Fence:      fence,
+		}, nil
+	}
+
+	// Undo every grant on failure
+	l.undoGrants(ctx, resource, holders, "")
+
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		l.logger.Error("acquisition left no validity within ttl", "resource", resource, "token", token, "elapsed", elapsed, "ttl", ttl)
+		return nil, TTLTooShortError
+	}
+	return nil, AcquireLockError
+}
+
+// undoGrants releases, node by node, the grants made with a token other than keep. On a node that
+// re-entered the owner's lock this gives back the reentry; on the others it deletes the fresh lock.
+func (l *redLock) undoGrants(ctx context.Context, resource string, holders map[*redis.Client]string, keep string) {
+	for node, holder := range holders {
+		if holder == keep {
+			continue
+		}
+
+		nodeCtx, cancel := context.WithTimeout(ctx, l.nodeTimeout) // Timeout per node
+		if err := releaseScript.Run(nodeCtx, node, lockKeys(resource), holder, time.Now().UnixMilli(), uuid.New().String()).Err(); err != nil {
+			l.logger.Warn("error undoing lock on node", "resource", resource, "token", holder, "node", node.Options().Addr, "error", err)
+		}
+		cancel()
+	}
+}
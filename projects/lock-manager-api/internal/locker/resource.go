@@ -0,0 +1,91 @@
+package locker
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultMaxResourceLength bounds resource names, in bytes, when WithMaxResourceLength isn't given
+const DefaultMaxResourceLength = 512
+
+// MaxTokenLength bounds the tokens clients may choose, in bytes
+const MaxTokenLength = 128
+
+// reservedKeyPrefixes are the prefixes of the keys the service keeps next to the locks. A resource
+// starting with one would share its key with the bookkeeping of another resource.
+var reservedKeyPrefixes = []string{
+	FenceKeyPrefix, NonceKeyPrefix, QuotaKeyPrefix, FairQueueKeyPrefix, SharedKeyPrefix,
+	ReentryKeyPrefix, MetaKeyPrefix,
+}
+
+var (
+	InvalidResourceError = errors.New("invalid resource name")
+	InvalidTokenError    = errors.New("invalid token")
+)
+
+// WithMaxResourceLength sets the longest resource name accepted, in bytes
+func WithMaxResourceLength(maxLength int) Option {
+	return func(l *redLock) {
+		if maxLength > 0 {
+			l.maxResourceLength = maxLength
+		}
+	}
+}
+
+// ValidateResource rejects resource names unfit to become Redis keys: empty, longer than maxLength
+// bytes, not valid UTF-8, holding non-printable characters that would also garble the logs, or starting
+// with a reserved key prefix in any case. Callers with a canonicalizer validate the canonical name.
+func ValidateResource(resource string, maxLength int) error {
+	if resource == "" {
+		return fmt.Errorf("%w: must not be empty", InvalidResourceError)
+	}
+	if len(resource) > maxLength {
+		return fmt.Errorf("%w: longer than %d bytes", InvalidResourceError, maxLength)
+	}
+	if !utf8.ValidString(resource) {
+		return fmt.Errorf("%w: not valid UTF-8", InvalidResourceError)
+	}
+	for _, r := range resource {
+		if !unicode.IsPrint(r) {
+			return fmt.Errorf("%w: non-printable character %U", InvalidResourceError, r)
+		}
+	}
+	for _, prefix := range reservedKeyPrefixes {
+		if len(resource) >= len(prefix) && strings.EqualFold(resource[:len(prefix)], prefix) {
+			return fmt.Errorf("%w: prefix '%s' is reserved", InvalidResourceError, prefix)
+		}
+	}
+	return nil
+}
+
+// ValidateToken rejects client-supplied tokens that are empty, longer than MaxTokenLength bytes or not
+// printable UTF-8
+func ValidateToken(token string) error {
+	if token == "" {
+		return fmt.Errorf("%w: must not be empty", InvalidTokenError)
+	}
+	if len(token) > MaxTokenLength {
+		return fmt.Errorf("%w: longer than %d bytes", InvalidTokenError, MaxTokenLength)
+	}
+	if !utf8.ValidString(token) {
+		return fmt.Errorf("%w: not valid UTF-8", InvalidTokenError)
+	}
+	for _, r := range token {
+		if !unicode.IsPrint(r) {
+			return fmt.Errorf("%w: non-printable character %U", InvalidTokenError, r)
+		}
+	}
+	return nil
+}
+
+// validResource applies ValidateResource with the locker's limit to the canonical name, the one that
+// becomes the key, so canonicalization can't turn an accepted name into a reserved one
+func (l *redLock) validResource(resource string) error {
+	if l.canonicalize != nil {
+		resource = l.canonicalize(resource)
+	}
+	return ValidateResource(resource, l.maxResourceLength)
+}
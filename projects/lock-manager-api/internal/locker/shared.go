@@ -0,0 +1,122 @@
+package locker
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/net/context"
+	"time"
+)
+
+// SharedKeyPrefix namespaces the per-resource sorted sets of shared holder tokens, scored by expiry (ms)
+const SharedKeyPrefix = "shared:"
+
+// acquireSharedScript adds a shared holder while no exclusive lock exists, expiring the set with its last holder.
+// Like acquireScript it returns {1}, or {0, holder token, holder pttl} when refused.
+// KEYS[1] = resource, KEYS[2] = shared holders set, ARGV[1] = token, ARGV[2] = ttl (ms), ARGV[3] = now (ms)
+var acquireSharedScript = redis.NewScript(`
+local current = redis.call("get", KEYS[1])
+if current then
+	return {0, current, redis.call("pttl", KEYS[1])}
+end
+redis.call("zremrangebyscore", KEYS[2], "-inf", ARGV[3])
+redis.call("zadd", KEYS[2], tonumber(ARGV[3]) + tonumber(ARGV[2]), ARGV[1])
+local last = redis.call("zrange", KEYS[2], -1, -1, "WITHSCORES")
+redis.call("pexpireat", KEYS[2], last[2])
+return {1}
+`)
+
+// lockMode tells the acquire fan-out how to take a lock on a node and how to check it is held
+type lockMode struct {
+	take  func(ctx context.Context, node *redis.Client, resource string, token string, ttl time.Duration) (bool, holder, error)
+	holds func(ctx context.Context, node *redis.Client, resource string, token string) (bool, error)
+}
+
+// exclusiveMode is a writer lock: a single holder, refused while readers hold the resource
+var exclusiveMode = lockMode{
+	take: func(ctx context.Context, node *redis.Client, resource string, token string, ttl time.Duration) (bool, holder, error) {
+		return takeResult(acquireScript.Run(ctx, node, []string{resource, SharedKeyPrefix + resource, MetaKeyPrefix + resource},
+			token, ttlMillis(ttl), time.Now().UnixMilli()).Slice())
+	},
+	holds: func(ctx context.Context, node *redis.Client, resource string, token string) (bool, error) {
+		val, err := node.Get(ctx, resource).Result()
+		if errors.Is(err, redis.Nil) {
+			return false, nil
+		}
+		return val == token, err
+	},
+}
+
+// sharedMode is a reader lock: any number of holders, refused while a writer holds the resource
+var sharedMode = lockMode{
+	take: func(ctx context.Context, node *redis.Client, resource string, token string, ttl time.Duration) (bool, holder, error) {
+		return takeResult(acquireSharedScript.Run(ctx, node, []string{resource, SharedKeyPrefix + resource},
+			token, ttlMillis(ttl), time.Now().UnixMilli()).Slice())
+	},
+	holds: func(ctx context.Context, node *redis.Client, resource string, token string) (bool, error) {
+		expiry, err := node.ZScore(ctx, SharedKeyPrefix+resource, token).Result()
+		if errors.Is(err, redis.Nil) {
+			return false, nil
+		}
+		return err == nil && int64(expiry) > time.Now().UnixMilli(), err
+	},
+}
+
+// holder is who kept a node from granting a lock, and for how long it still holds it there
+type holder struct {
+	token string // Empty for shared holders
+	ttl   time.Duration
+}
+
+// takeResult decodes the {1} or {0, holder token, holder pttl} reply of the acquire scripts
+func takeResult(reply []interface{}, err error) (bool, holder, error) {
+	if err != nil || len(reply) == 0 {
+		return false, holder{}, err
+	}
+	if acquired, _ := reply[0].(int64); acquired == 1 {
+		return true, holder{}, nil
+	}
+
+	var current holder
+	if len(reply) == 3 {
+		current.token, _ = reply[1].(string)
+		if pttl, ok := reply[2].(int64); ok && pttl > 0 {
+			current.ttl = time.Duration(pttl) * time.Millisecond
+		}
+	}
+	return false, current, nil
+}
+
+// heldFor estimates how long the resource stays held: the shortest remaining TTL of the holder seen on
+// the most nodes, the earliest moment a retry may find it released. Zero when no holder was seen.
+func heldFor(holders []holder) time.Duration {
+	seen := make(map[string]int)
+	for _, h := range holders {
+		seen[h.token]++
+	}
+
+	var top string
+	for token, count := range seen {
+		if count > seen[top] || (count == seen[top] && token < top) {
+			top = token
+		}
+	}
+
+	var shortest time.Duration
+	for _, h := range holders {
+		if h.token == top && (shortest == 0 || h.ttl < shortest) {
+			shortest = h.ttl
+		}
+	}
+	return shortest
+}
+
+// AcquireShared acquires a shared (read) lock. Shared holders coexist with each other, while an
+// exclusive lock taken with Acquire excludes them and is excluded by them. Shared locks are released
+// and refreshed with Release and Refresh, like exclusive ones.
+func (l *redLock) AcquireShared(ctx context.Context, resource string, ttl time.Duration) (*Locker, error) {
+	if err := l.validResource(resource); err != nil {
+		return nil, err
+	}
+	return l.acquire(ctx, l.canonical(resource), uuid.New().String(), ttl, sharedMode)
+}
@@ -0,0 +1,51 @@
+package locker
+
+import (
+	"errors"
+	"golang.org/x/net/context"
+	"sync"
+	"time"
+)
+
+// TTLQuery identifies a lock whose remaining time-to-live is queried by TTLMulti
+type TTLQuery struct {
+	Resource string `json:"resource"`
+	Token    string `json:"token"`
+}
+
+// TTLMulti checks the remaining time-to-live of several locks at once, each queried in parallel with the
+// same per-node fan-out as TTL. The result is keyed by resource; a lock that expired, doesn't exist or
+// isn't held with its token is left out of it. Any other failure fails the whole query.
+func (l *redLock) TTLMulti(ctx context.Context, items []TTLQuery) (map[string]time.Duration, error) {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	ttls := make(map[string]time.Duration, len(items))
+	errs := make([]error, 0)
+
+	for _, item := range items {
+		wg.Add(1)
+		go func(item TTLQuery) {
+			defer wg.Done()
+
+			ttl, _, err := l.TTL(ctx, item.Resource, item.Token)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if errors.Is(err, LockNotFoundError) {
+				return
+			} else if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			ttls[item.Resource] = ttl
+		}(item)
+	}
+
+	wg.Wait()
+
+	if len(errs) > 0 {
+		l.logger.Warn("errors while getting TTLs", "resources", len(items), "errors", errs)
+		return nil, errors.Join(errs...)
+	}
+	return ttls, nil
+}
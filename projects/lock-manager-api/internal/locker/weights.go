@@ -0,0 +1,52 @@
+package locker
+
+import (
+	"fmt"
+	"github.com/redis/go-redis/v9"
+)
+
+// WithNodeWeights gives each node, in the order given to NewLocker, a number of votes toward the quorum,
+// so a reliable node can count more than a flaky one. The quorum becomes a majority of the total weight.
+// Without weights every node has one vote.
+func WithNodeWeights(weights ...int) Option {
+	return func(l *redLock) {
+		l.nodeWeights = weights
+	}
+}
+
+// ValidateWeights checks that weights, when given, has one positive weight per node
+func ValidateWeights(nodes int, weights []int) error {
+	if len(weights) == 0 {
+		return nil
+	}
+	if len(weights) != nodes {
+		return fmt.Errorf("got %d node weights for %d Redis servers", len(weights), nodes)
+	}
+	for i, weight := range weights {
+		if weight <= 0 {
+			return fmt.Errorf("weight of Redis server %d must be greater than zero", i+1)
+		}
+	}
+	return nil
+}
+
+// WeightedQuorum returns the votes needed for an operation over nodes with the given weights to succeed,
+// the same as Quorum(nodes) when no weights are given
+func WeightedQuorum(nodes int, weights []int) int {
+	if len(weights) == 0 {
+		return Quorum(nodes)
+	}
+	total := 0
+	for _, weight := range weights {
+		total += weight
+	}
+	return Quorum(total)
+}
+
+// weight returns the votes of a node
+func (l *redLock) weight(node *redis.Client) int {
+	if weight, ok := l.weights[node]; ok {
+		return weight
+	}
+	return 1
+}
@@ -0,0 +1,92 @@
+package locker
+
+import (
+	"errors"
+	"golang.org/x/net/context"
+	"testing"
+	"time"
+)
+
+func TestWeightedQuorum(t *testing.T) {
+	tests := []struct {
+		nodes   int
+		weights []int
+		want    int
+	}{
+		{nodes: 3, want: 2},
+		{nodes: 5, want: 3},
+		{nodes: 3, weights: []int{1, 1, 1}, want: 2},
+		{nodes: 3, weights: []int{3, 1, 1}, want: 3},
+		{nodes: 3, weights: []int{2, 2, 1}, want: 3},
+	}
+	for _, tt := range tests {
+		if got := WeightedQuorum(tt.nodes, tt.weights); got != tt.want {
+			t.Errorf("WeightedQuorum(%d, %v) = %d, want %d", tt.nodes, tt.weights, got, tt.want)
+		}
+	}
+}
+
+func TestValidateWeights(t *testing.T) {
+	if err := ValidateWeights(3, nil); err != nil {
+		t.Errorf("no weights: %v", err)
+	}
+	if err := ValidateWeights(3, []int{2, 1, 1}); err != nil {
+		t.Errorf("one weight per node: %v", err)
+	}
+	if err := ValidateWeights(3, []int{1, 1}); err == nil {
+		t.Error("fewer weights than nodes were accepted")
+	}
+	if err := ValidateWeights(3, []int{1, 0, 1}); err == nil {
+		t.Error("a zero weight was accepted")
+	}
+}
+
+func TestNewLockerRejectsAnEvenOrTooSmallNodeCount(t *testing.T) {
+	for _, n := range []int{1, 2, 4} {
+		_, clients := newTestNodes(t, n)
+		if _, err := NewLocker(clients); err == nil {
+			t.Errorf("NewLocker accepted %d nodes", n)
+		}
+	}
+}
+
+func TestAcquireCountsTheVotesOfEachNode(t *testing.T) {
+	ctx := context.Background()
+
+	// The heavy node alone holds the quorum of 3 votes out of 5
+	l, servers := newTestLocker(t, 3, WithNodeWeights(3, 1, 1))
+	servers[1].SetError("node down")
+	servers[2].SetError("node down")
+	if _, err := l.Acquire(ctx, "item-1", time.Second); err != nil {
+		t.Errorf("Acquire with the heavy node up: %v", err)
+	}
+
+	// Without it, the two light nodes can't make up a quorum
+	l, servers = newTestLocker(t, 3, WithNodeWeights(3, 1, 1))
+	servers[0].SetError("node down")
+	if _, err := l.Acquire(ctx, "item-1", time.Second); !errors.Is(err, QuorumUnavailableError) {
+		t.Errorf("Acquire with the heavy node down error = %v, want QuorumUnavailableError", err)
+	}
+}
+
+func TestAcquireTellsAnOutageFromAConflict(t *testing.T) {
+	l, servers := newTestLocker(t, 3)
+
+	if err := servers[0].Set("item-1", "someone-else"); err != nil {
+		t.Fatal(err)
+	}
+	servers[1].SetError("node down")
+
+	// Had the failed node answered, the quorum could have been reached
+	if _, err := l.Acquire(context.Background(), "item-1", time.Second); !errors.Is(err, QuorumUnavailableError) {
+		t.Errorf("Acquire error = %v, want QuorumUnavailableError", err)
+	}
+
+	servers[1].SetError("")
+	if err := servers[1].Set("item-1", "someone-else"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Acquire(context.Background(), "item-1", time.Second); !errors.Is(err, AcquireLockError) {
+		t.Errorf("Acquire error = %v, want AcquireLockError", err)
+	}
+}
@@ -0,0 +1,140 @@
+package metrics
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultLatencyWindow is the number of samples kept per endpoint when no window size is given
+const DefaultLatencyWindow = 1024
+
+// LatencySummary holds the percentiles computed over the samples currently in the window
+type LatencySummary struct {
+	Count int    `json:"count"`
+	P50   string `json:"p50"`
+	P90   string `json:"p90"`
+	P99   string `json:"p99"`
+}
+
+type window struct {
+	samples []time.Duration
+	next    int
+	full    bool
+}
+
+type latencyTracker struct {
+	mu         sync.Mutex
+	windowSize int
+	endpoints  map[string]*window
+}
+
+type LatencyTracker interface {
+	Observe(endpoint string, latency time.Duration)
+	Percentile(endpoint string, p float64) time.Duration
+	Snapshot() map[string]LatencySummary
+	Middleware(endpoint string) func(http.Handler) http.Handler
+}
+
+// NewLatencyTracker creates a tracker that keeps the last windowSize samples of each endpoint.
+// Memory is bounded by windowSize times the number of instrumented endpoints.
+func NewLatencyTracker(windowSize int) LatencyTracker {
+	if windowSize <= 0 {
+		windowSize = DefaultLatencyWindow
+	}
+	return &latencyTracker{
+		windowSize: windowSize,
+		endpoints:  make(map[string]*window),
+	}
+}
+
+// Observe records a latency sample, overwriting the oldest one when the window is full
+func (t *latencyTracker) Observe(endpoint string, latency time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	w, ok := t.endpoints[endpoint]
+	if !ok {
+		w = &window{samples: make([]time.Duration, t.windowSize)}
+		t.endpoints[endpoint] = w
+	}
+
+	w.samples[w.next] = latency
+	w.next = (w.next + 1) % t.windowSize
+	if w.next == 0 {
+		w.full = true
+	}
+}
+
+// Percentile returns the p-th percentile (0-100) of the samples in the endpoint window
+func (t *latencyTracker) Percentile(endpoint string, p float64) time.Duration {
+	t.mu.Lock()
+	sorted := t.sortedSamples(endpoint)
+	t.mu.Unlock()
+
+	return percentile(sorted, p)
+}
+
+// Snapshot returns the p50/p90/p99 of every endpoint observed so far
+func (t *latencyTracker) Snapshot() map[string]LatencySummary {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	summaries := make(map[string]LatencySummary, len(t.endpoints))
+	for endpoint := range t.endpoints {
+		sorted := t.sortedSamples(endpoint)
+		summaries[endpoint] = LatencySummary{
+			Count: len(sorted),
+			P50:   percentile(sorted, 50).String(),
+			P90:   percentile(sorted, 90).String(),
+			P99:   percentile(sorted, 99).String(),
+		}
+	}
+	return summaries
+}
+
+// Middleware measures the time spent serving each request and records it under endpoint
+func (t *latencyTracker) Middleware(endpoint string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			next.ServeHTTP(w, r)
+			t.Observe(endpoint, time.Since(start))
+		})
+	}
+}
+
+// sortedSamples copies and sorts the samples of an endpoint. Caller must hold t.mu.
+func (t *latencyTracker) sortedSamples(endpoint string) []time.Duration {
+	w, ok := t.endpoints[endpoint]
+	if !ok {
+		return nil
+	}
+
+	size := w.next
+	if w.full {
+		size = len(w.samples)
+	}
+
+	sorted := make([]time.Duration, size)
+	copy(sorted, w.samples[:size])
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted
+}
+
+// percentile uses the nearest-rank method over an already sorted slice
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPercentileUsesTheNearestRank(t *testing.T) {
+	tracker := NewLatencyTracker(100)
+	for i := 1; i <= 100; i++ {
+		tracker.Observe("/lock", time.Duration(i)*time.Millisecond)
+	}
+
+	tests := map[float64]time.Duration{
+		0:   time.Millisecond,
+		50:  50 * time.Millisecond,
+		90:  90 * time.Millisecond,
+		99:  99 * time.Millisecond,
+		100: 100 * time.Millisecond,
+	}
+	for p, want := range tests {
+		if got := tracker.Percentile("/lock", p); got != want {
+			t.Errorf("p%v = %s, want %s", p, got, want)
+		}
+	}
+}
+
+func TestPercentileOfAnUnknownEndpointIsZero(t *testing.T) {
+	if got := NewLatencyTracker(10).Percentile("/unlock", 50); got != 0 {
+		t.Errorf("p50 = %s, want 0", got)
+	}
+}
+
+func TestObserveKeepsOnlyTheLastWindow(t *testing.T) {
+	tracker := NewLatencyTracker(3)
+
+	// The slow sample is overwritten by the three after it
+	for _, ms := range []int{500, 1, 2, 3} {
+		tracker.Observe("/lock", time.Duration(ms)*time.Millisecond)
+	}
+
+	summary := tracker.Snapshot()["/lock"]
+	if summary.Count != 3 {
+		t.Errorf("Count = %d, want the window of 3", summary.Count)
+	}
+	if got := tracker.Percentile("/lock", 100); got != 3*time.Millisecond {
+		t.Errorf("max = %s, want 3ms once the oldest sample was overwritten", got)
+	}
+}
+
+func TestSnapshotSummarizesEachEndpoint(t *testing.T) {
+	tracker := NewLatencyTracker(0)
+	tracker.Observe("/lock", 10*time.Millisecond)
+	tracker.Observe("/unlock", 20*time.Millisecond)
+
+	snapshot := tracker.Snapshot()
+	if len(snapshot) != 2 {
+		t.Fatalf("snapshot = %+v, want two endpoints", snapshot)
+	}
+	want := LatencySummary{Count: 1, P50: "20ms", P90: "20ms", P99: "20ms"}
+	if snapshot["/unlock"] != want {
+		t.Errorf("summary = %+v, want %+v", snapshot["/unlock"], want)
+	}
+}
+
+func TestMiddlewareObservesEachRequest(t *testing.T) {
+	tracker := NewLatencyTracker(10)
+	handler := tracker.Middleware("/lock")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/lock", nil))
+	if summary := tracker.Snapshot()["/lock"]; summary.Count != 1 {
+		t.Fatalf("Count = %d, want 1", summary.Count)
+	}
+	if got := tracker.Percentile("/lock", 50); got < 5*time.Millisecond {
+		t.Errorf("latency = %s, want at least the 5ms spent serving", got)
+	}
+}
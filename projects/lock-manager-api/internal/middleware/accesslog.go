@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/go-chi/chi/v5/middleware"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Supported access log formats
+const (
+	AccessLogText = "text"
+	AccessLogJSON = "json"
+)
+
+// outcomes maps the status code of each operation to its lock-specific outcome. A 429 is named by the
+// handler, as either OutcomeRateLimited or OutcomeOverQuota.
+var outcomes = map[string]map[int]string{
+	"acquire":          {http.StatusOK: "acquired", http.StatusConflict: "conflict"},
+	"acquire_shared":   {http.StatusOK: "acquired", http.StatusConflict: "conflict"},
+	"acquire_any":      {http.StatusOK: "acquired", http.StatusConflict: "conflict"},
+	"acquire_batch":    {http.StatusOK: "acquired", http.StatusConflict: "conflict"},
+	"release":          {http.StatusOK: "released", http.StatusNotFound: "not-found", http.StatusConflict: "replayed"},
+	"release_by_token": {http.StatusOK: "released", http.StatusConflict: "replayed"},
+	"refresh":          {http.StatusOK: "refreshed", http.StatusNotFound: "not-found", http.StatusConflict: "replayed"},
+	"ttl":              {http.StatusOK: "found", http.StatusNotFound: "not-found"},
+}
+
+type accessLogEntry struct {
+	Operation string  `json:"op"`
+	Resource  string  `json:"resource,omitempty"`
+	Outcome   string  `json:"outcome"`
+	Status    int     `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+}
+
+type accessLogger struct {
+	logger *log.Logger
+	format string
+}
+
+type AccessLogger interface {
+	Middleware(operation string) func(http.Handler) http.Handler
+}
+
+// NewAccessLogger creates a logger writing one line per lock request to out, in text or json format
+func NewAccessLogger(out io.Writer, format string) (AccessLogger, error) {
+	if format != AccessLogText && format != AccessLogJSON {
+		return nil, fmt.Errorf("unsupported access log format '%s'", format)
+	}
+	return &accessLogger{
+		logger: log.New(out, "", log.LstdFlags),
+		format: format,
+	}, nil
+}
+
+// Middleware logs the resource, outcome and latency of every request served for operation
+func (a *accessLogger) Middleware(operation string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			r, details := withDetails(r)
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			a.write(accessLogEntry{
+				Operation: operation,
+				Resource:  details.resourceOf(r),
+				Outcome:   details.outcomeOf(operation, status),
+				Status:    status,
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+			})
+		})
+	}
+}
+
+func (a *accessLogger) write(entry accessLogEntry) {
+	if a.format == AccessLogJSON {
+		line, err := json.Marshal(entry)
+		if err == nil {
+			a.logger.Println(string(line))
+		}
+		return
+	}
+
+	a.logger.Printf("op=%s resource=%s outcome=%s status=%d latency_ms=%s\n",
+		entry.Operation, strconv.Quote(entry.Resource), entry.Outcome, entry.Status,
+		strconv.FormatFloat(entry.LatencyMs, 'f', 3, 64))
+}
+
+// outcome names the result of a request, falling back to the status class for unmapped codes
+func outcome(operation string, status int) string {
+	if name, ok := outcomes[operation][status]; ok {
+		return name
+	}
+	switch {
+	case status >= 500:
+		return "error"
+	case status >= 400:
+		return "rejected"
+	default:
+		return "ok"
+	}
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"golang.org/x/net/context"
+	"net/http"
+)
+
+// Outcomes a handler reports for the rejections sharing the 429 status code
+const (
+	OutcomeRateLimited = "rate-limited"
+	OutcomeOverQuota   = "over-quota"
+)
+
+type detailsKey struct{}
+
+// requestDetails is what the handler learns about a request that the request itself doesn't tell the
+// middlewares: the resource read from a JSON body and the outcome of a rejection
+type requestDetails struct {
+	resource string
+	outcome  string
+}
+
+// withDetails returns the request carrying the details shared by every middleware of the chain, adding
+// them to the context of the outermost one
+func withDetails(r *http.Request) (*http.Request, *requestDetails) {
+	if details, ok := r.Context().Value(detailsKey{}).(*requestDetails); ok {
+		return r, details
+	}
+	details := &requestDetails{}
+	return r.WithContext(context.WithValue(r.Context(), detailsKey{}, details)), details
+}
+
+// SetResource reports the resource the handler parsed, for the access log and the span of the request
+func SetResource(ctx context.Context, resource string) {
+	if details, ok := ctx.Value(detailsKey{}).(*requestDetails); ok {
+		details.resource = resource
+	}
+}
+
+// SetOutcome reports why the handler rejected the request, when its status code alone can't tell
+func SetOutcome(ctx context.Context, outcome string) {
+	if details, ok := ctx.Value(detailsKey{}).(*requestDetails); ok {
+		details.outcome = outcome
+	}
+}
+
+// resourceOf returns the resource the handler parsed, or the one in the query string if it reported none
+func (d *requestDetails) resourceOf(r *http.Request) string {
+	if d.resource != "" {
+		return d.resource
+	}
+	return r.URL.Query().Get("resource")
+}
+
+// outcomeOf names the result of the request, preferring the outcome reported by the handler
+func (d *requestDetails) outcomeOf(operation string, status int) string {
+	if d.outcome != "" {
+		return d.outcome
+	}
+	return outcome(operation, status)
+}
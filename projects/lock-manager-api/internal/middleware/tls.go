@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+)
+
+// RequireTLS refuses with 426 Upgrade Required the requests that did not arrive over TLS. Behind a
+// TLS-terminating proxy the connection is plaintext, so the proxy's X-Forwarded-Proto header is
+// honored when trustForwardedProto is set. Only set it when every request goes through that proxy,
+// since a client reaching the service directly can send the header itself.
+func RequireTLS(trustForwardedProto bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !isSecure(r, trustForwardedProto) {
+				w.Header().Set("Upgrade", "TLS/1.2, HTTP/1.1")
+				w.Header().Set("Connection", "Upgrade")
+				writeError(w, "secure transport required: tokens must not travel over plaintext HTTP", http.StatusUpgradeRequired)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// isSecure tells whether the request reached the service, or the proxy in front of it, over TLS
+func isSecure(r *http.Request, trustForwardedProto bool) bool {
+	if r.TLS != nil {
+		return true
+	}
+	if !trustForwardedProto {
+		return false
+	}
+
+	// Each proxy appends to the list, which may also span several header lines. Only the last entry, set
+	// by the trusted proxy in front of the service, counts: the earlier ones may come from the client.
+	values := r.Header.Values("X-Forwarded-Proto")
+	if len(values) == 0 {
+		return false
+	}
+	entries := strings.Split(values[len(values)-1], ",")
+	return strings.EqualFold(strings.TrimSpace(entries[len(entries)-1]), "https")
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireTLS(t *testing.T) {
+	tests := []struct {
+		name                string
+		overTLS             bool
+		forwardedProto      []string
+		trustForwardedProto bool
+		want                int
+	}{
+		{name: "plaintext", want: http.StatusUpgradeRequired},
+		{name: "over TLS", overTLS: true, want: http.StatusOK},
+		{name: "forwarded https, trusted", forwardedProto: []string{"https"}, trustForwardedProto: true, want: http.StatusOK},
+		{name: "forwarded https, untrusted", forwardedProto: []string{"https"}, want: http.StatusUpgradeRequired},
+		{name: "forwarded http", forwardedProto: []string{"http"}, trustForwardedProto: true, want: http.StatusUpgradeRequired},
+		{name: "https sent by the client", forwardedProto: []string{"https, http"}, trustForwardedProto: true, want: http.StatusUpgradeRequired},
+		{name: "https appended by the proxy", forwardedProto: []string{"http, HTTPS"}, trustForwardedProto: true, want: http.StatusOK},
+		{name: "https on an earlier header line", forwardedProto: []string{"https", "http"}, trustForwardedProto: true, want: http.StatusUpgradeRequired},
+	}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/lock", nil)
+			if tt.overTLS {
+				r.TLS = &tls.ConnectionState{}
+			}
+			for _, proto := range tt.forwardedProto {
+				r.Header.Add("X-Forwarded-Proto", proto)
+			}
+
+			w := httptest.NewRecorder()
+			RequireTLS(tt.trustForwardedProto)(next).ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+			if tt.want == http.StatusUpgradeRequired && w.Header().Get("Upgrade") == "" {
+				t.Error("a 426 without an Upgrade header")
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+)
+
+// tracerName identifies the spans created by the lock manager
+const tracerName = "github.com/Waelson/lock-manager-service/lock-manager-api"
+
+// propagator reads the W3C traceparent header sent by the SDK
+var propagator = propagation.TraceContext{}
+
+// Tracing wraps every request served for operation in a server span continuing the caller's trace.
+// Handlers add the lock details (resource, nodes) to the span found in the request context.
+func Tracing(provider trace.TracerProvider, operation string) func(http.Handler) http.Handler {
+	tracer := provider.Tracer(tracerName)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r, details := withDetails(r)
+			ctx := propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+			ctx, span := tracer.Start(ctx, "lock-manager."+operation,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(attribute.String("lock.operation", operation)))
+			defer span.End()
+
+			if resource := r.URL.Query().Get("resource"); resource != "" {
+				span.SetAttributes(attribute.String("lock.resource", resource))
+			}
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(ctx))
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			// A resource sent in a JSON body is only known once the handler parsed it
+			if details.resource != "" {
+				span.SetAttributes(attribute.String("lock.resource", details.resource))
+			}
+			span.SetAttributes(
+				attribute.Int("http.status_code", status),
+				attribute.String("lock.outcome", details.outcomeOf(operation, status)))
+			if status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(status))
+			}
+		})
+	}
+}
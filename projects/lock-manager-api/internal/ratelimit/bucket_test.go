@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAllowLetsABurstThrough(t *testing.T) {
+	l := NewKeyedLimiter(1, 3)
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("item-1"); !ok {
+			t.Fatalf("request %d of the burst was refused", i+1)
+		}
+	}
+	ok, retryAfter := l.Allow("item-1")
+	if ok {
+		t.Fatal("a request past the burst was allowed")
+	}
+	if retryAfter <= 0 || retryAfter > time.Second {
+		t.Errorf("retry after %s, want within (0, 1s] at one token per second", retryAfter)
+	}
+}
+
+func TestAllowKeepsOneBucketPerKey(t *testing.T) {
+	l := NewKeyedLimiter(1, 1)
+
+	if ok, _ := l.Allow("item-1"); !ok {
+		t.Fatal("the first request for item-1 was refused")
+	}
+	if ok, _ := l.Allow("item-2"); !ok {
+		t.Error("item-2 was limited by the requests for item-1")
+	}
+}
+
+func TestAllowRefillsOverTime(t *testing.T) {
+	l := NewKeyedLimiter(100, 1)
+
+	if ok, _ := l.Allow("item-1"); !ok {
+		t.Fatal("the first request was refused")
+	}
+	if ok, _ := l.Allow("item-1"); ok {
+		t.Fatal("the bucket wasn't empty after the burst")
+	}
+
+	// One token every 10ms
+	time.Sleep(20 * time.Millisecond)
+	if ok, _ := l.Allow("item-1"); !ok {
+		t.Error("the bucket wasn't refilled")
+	}
+}
+
+func TestNewKeyedLimiterRaisesTheBurstToOne(t *testing.T) {
+	l := NewKeyedLimiter(1, 0)
+
+	if ok, _ := l.Allow("item-1"); !ok {
+		t.Error("a zero burst refused every request")
+	}
+}
+
+func TestSweepDropsFullBuckets(t *testing.T) {
+	l := NewKeyedLimiter(1, 2).(*keyedLimiter)
+
+	l.Allow("idle")
+	l.Allow("busy")
+	l.Allow("busy")
+
+	// A second later the idle bucket is full again, the busy one isn't
+	l.sweep(time.Now().Add(time.Second))
+	if _, ok := l.buckets["idle"]; ok {
+		t.Error("the full bucket was kept")
+	}
+	if _, ok := l.buckets["busy"]; !ok {
+		t.Error("the bucket still refilling was dropped")
+	}
+}
@@ -0,0 +1,195 @@
+package rpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"github.com/Waelson/lock-manager-service/lock-manager-api/internal/handler"
+	"github.com/Waelson/lock-manager-service/lock-manager-api/internal/locker"
+	"github.com/Waelson/lock-manager-service/lock-manager-api/pkg/lockpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"strings"
+	"time"
+)
+
+type lockServer struct {
+	lockpb.UnimplementedLockServiceServer
+	redlock  locker.RedLocker
+	minTTL   time.Duration
+	maxTTL   time.Duration
+	clampTTL bool
+}
+
+// NewLockServer serves the lock operations over gRPC on top of the same RedLocker as the HTTP API,
+// rejecting acquisitions below minTTL and capping TTLs at maxTTL like the HTTP handlers do
+func NewLockServer(redlock locker.RedLocker, minTTL time.Duration, maxTTL time.Duration, clampTTL bool) lockpb.LockServiceServer {
+	return &lockServer{redlock: redlock, minTTL: minTTL, maxTTL: maxTTL, clampTTL: clampTTL}
+}
+
+// Lock acquires an exclusive lock, reentrant when the request carries an owner
+func (s *lockServer) Lock(ctx context.Context, req *lockpb.LockRequest) (*lockpb.LockResponse, error) {
+	if req.GetResource() == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing 'resource'")
+	}
+	ttl := time.Duration(req.GetTtlMs()) * time.Millisecond
+	if ttl <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "'ttl_ms' must be greater than zero")
+	}
+	if ttl < s.minTTL {
+		return nil, status.Errorf(codes.InvalidArgument, "'ttl_ms' %s is below the minimum of %s", ttl, s.minTTL)
+	}
+	ttl, err := handler.CapTTL(ttl, s.maxTTL, s.clampTTL)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, handler.RequestTimeout)
+	defer cancel()
+
+	var lock *locker.Locker
+	if req.GetOwner() != "" {
+		lock, err = s.redlock.AcquireReentrant(ctx, req.GetResource(), req.GetOwner(), ttl)
+	} else {
+		lock, err = s.redlock.Acquire(ctx, req.GetResource(), ttl)
+	}
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &lockpb.LockResponse{
+		Token:      lock.Token,
+		Resource:   lock.Resource,
+		ValidityMs: lock.Validity.Milliseconds(),
+		Fence:      lock.Fence,
+	}, nil
+}
+
+// Unlock releases a lock held with the request's token
+func (s *lockServer) Unlock(ctx context.Context, req *lockpb.UnlockRequest) (*lockpb.UnlockResponse, error) {
+	if err := requireLock(req.GetResource(), req.GetToken()); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, handler.RequestTimeout)
+	defer cancel()
+
+	if err := s.redlock.Release(ctx, req.GetResource(), req.GetToken()); err != nil {
+		return nil, statusError(err)
+	}
+	return &lockpb.UnlockResponse{}, nil
+}
+
+// Refresh extends the TTL of a lock held with the request's token
+func (s *lockServer) Refresh(ctx context.Context, req *lockpb.RefreshRequest) (*lockpb.RefreshResponse, error) {
+	if err := requireLock(req.GetResource(), req.GetToken()); err != nil {
+		return nil, err
+	}
+	ttl := time.Duration(req.GetTtlMs()) * time.Millisecond
+	if ttl <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "'ttl_ms' must be greater than zero")
+	}
+	ttl, err := handler.CapTTL(ttl, s.maxTTL, s.clampTTL)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, handler.RequestTimeout)
+	defer cancel()
+
+	refreshedOn, err := s.redlock.Refresh(ctx, req.GetResource(), req.GetToken(), ttl)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &lockpb.RefreshResponse{RefreshedOn: int32(refreshedOn)}, nil
+}
+
+// TTL returns the remaining time of a lock held with the request's token, and who took it
+func (s *lockServer) TTL(ctx context.Context, req *lockpb.TTLRequest) (*lockpb.TTLResponse, error) {
+	if err := requireLock(req.GetResource(), req.GetToken()); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, handler.RequestTimeout)
+	defer cancel()
+
+	ttl, meta, err := s.redlock.TTL(ctx, req.GetResource(), req.GetToken())
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	response := &lockpb.TTLResponse{TtlMs: ttl.Milliseconds(), Owner: meta.Owner}
+	if !meta.AcquiredAt.IsZero() {
+		response.AcquiredAtMs = meta.AcquiredAt.UnixMilli()
+	}
+	return response, nil
+}
+
+// requireLock checks the parameters identifying a held lock
+func requireLock(resource string, token string) error {
+	if resource == "" {
+		return status.Error(codes.InvalidArgument, "missing 'resource'")
+	}
+	if token == "" {
+		return status.Error(codes.InvalidArgument, "missing 'token'")
+	}
+	return nil
+}
+
+// statusError maps the locker errors to gRPC codes, the way the HTTP handlers map them to status codes
+func statusError(err error) error {
+	switch {
+	case errors.Is(err, locker.AcquireLockError):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, locker.InvalidResourceError), errors.Is(err, locker.InvalidTokenError):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, locker.LockNotFoundError):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, locker.TTLTooShortError), errors.Is(err, locker.DrainingError),
+		errors.Is(err, locker.QuorumUnavailableError):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+// RequireAPIKey is the gRPC counterpart of the HTTP API key check: with keys configured, every call must
+// carry one of them in the "x-api-key" or "authorization: Bearer <key>" metadata
+func RequireAPIKey(keys ...string) grpc.UnaryServerInterceptor {
+	allowed := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if key != "" {
+			allowed = append(allowed, key)
+		}
+	}
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (interface{}, error) {
+		if len(allowed) == 0 {
+			return next(ctx, req)
+		}
+
+		presented := ""
+		md, _ := metadata.FromIncomingContext(ctx)
+		if values := md.Get("x-api-key"); len(values) > 0 {
+			presented = strings.TrimSpace(values[0])
+		} else if values := md.Get("authorization"); len(values) > 0 && len(values[0]) > 7 && strings.EqualFold(values[0][:7], "Bearer ") {
+			presented = strings.TrimSpace(values[0][7:])
+		}
+		if presented == "" {
+			return nil, status.Error(codes.Unauthenticated, "missing API key")
+		}
+
+		for _, key := range allowed {
+			if subtle.ConstantTimeCompare([]byte(presented), []byte(key)) == 1 {
+				return next(ctx, req)
+			}
+		}
+		return nil, status.Error(codes.Unauthenticated, "invalid API key")
+	}
+}
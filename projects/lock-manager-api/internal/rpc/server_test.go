@@ -0,0 +1,138 @@
+package rpc
+
+import (
+	"context"
+	"github.com/Waelson/lock-manager-service/lock-manager-api/internal/locker"
+	"github.com/Waelson/lock-manager-service/lock-manager-api/pkg/lockpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+func TestLockServerRoundTrip(t *testing.T) {
+	s := NewLockServer(locker.NewInMemoryLocker(), 0, time.Minute, true)
+	ctx := context.Background()
+
+	lock, err := s.Lock(ctx, &lockpb.LockRequest{Resource: "item-1", TtlMs: 1000})
+	if err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+	if lock.GetToken() == "" || lock.GetFence() <= 0 {
+		t.Errorf("lock = %v, want a token and a fence", lock)
+	}
+
+	_, err = s.Lock(ctx, &lockpb.LockRequest{Resource: "item-1", TtlMs: 1000})
+	assertCode(t, err, codes.AlreadyExists)
+
+	refreshed, err := s.Refresh(ctx, &lockpb.RefreshRequest{Resource: "item-1", Token: lock.GetToken(), TtlMs: time.Hour.Milliseconds()})
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if refreshed.GetRefreshedOn() != 1 {
+		t.Errorf("refreshed on %d nodes, want 1", refreshed.GetRefreshedOn())
+	}
+
+	// Clamped to the one minute maximum
+	ttl, err := s.TTL(ctx, &lockpb.TTLRequest{Resource: "item-1", Token: lock.GetToken()})
+	if err != nil {
+		t.Fatalf("TTL: %v", err)
+	}
+	if ttl.GetTtlMs() > time.Minute.Milliseconds() {
+		t.Errorf("ttl_ms = %d, want it capped to a minute", ttl.GetTtlMs())
+	}
+
+	if _, err := s.Unlock(ctx, &lockpb.UnlockRequest{Resource: "item-1", Token: lock.GetToken()}); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	_, err = s.Unlock(ctx, &lockpb.UnlockRequest{Resource: "item-1", Token: lock.GetToken()})
+	assertCode(t, err, codes.NotFound)
+}
+
+func TestLockServerValidatesTheRequest(t *testing.T) {
+	s := NewLockServer(locker.NewInMemoryLocker(), time.Second, time.Minute, false)
+	ctx := context.Background()
+
+	_, err := s.Lock(ctx, &lockpb.LockRequest{TtlMs: 1000})
+	assertCode(t, err, codes.InvalidArgument)
+	_, err = s.Lock(ctx, &lockpb.LockRequest{Resource: "item-1"})
+	assertCode(t, err, codes.InvalidArgument)
+	_, err = s.Lock(ctx, &lockpb.LockRequest{Resource: "item-1", TtlMs: 500})
+	assertCode(t, err, codes.InvalidArgument)
+	_, err = s.Lock(ctx, &lockpb.LockRequest{Resource: "item-1", TtlMs: time.Hour.Milliseconds()})
+	assertCode(t, err, codes.InvalidArgument)
+	_, err = s.Unlock(ctx, &lockpb.UnlockRequest{Resource: "item-1"})
+	assertCode(t, err, codes.InvalidArgument)
+}
+
+func TestLockServerReentrantLock(t *testing.T) {
+	s := NewLockServer(locker.NewInMemoryLocker(), 0, time.Minute, false)
+	ctx := context.Background()
+
+	first, err := s.Lock(ctx, &lockpb.LockRequest{Resource: "item-1", TtlMs: 1000, Owner: "worker-1"})
+	if err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+	again, err := s.Lock(ctx, &lockpb.LockRequest{Resource: "item-1", TtlMs: 1000, Owner: "worker-1"})
+	if err != nil {
+		t.Fatalf("re-entering Lock: %v", err)
+	}
+	if again.GetToken() != first.GetToken() {
+		t.Errorf("re-entered token = %q, want %q", again.GetToken(), first.GetToken())
+	}
+
+	ttl, err := s.TTL(ctx, &lockpb.TTLRequest{Resource: "item-1", Token: first.GetToken()})
+	if err != nil {
+		t.Fatalf("TTL: %v", err)
+	}
+	if ttl.GetOwner() != "worker-1" || ttl.GetAcquiredAtMs() == 0 {
+		t.Errorf("ttl = %v, want the owner and when it acquired the lock", ttl)
+	}
+}
+
+func TestLockServerReportsAClosedLockerAsUnavailable(t *testing.T) {
+	redlock := locker.NewInMemoryLocker()
+	_ = redlock.Close()
+
+	_, err := NewLockServer(redlock, 0, time.Minute, false).Lock(context.Background(), &lockpb.LockRequest{Resource: "item-1", TtlMs: 1000})
+	assertCode(t, err, codes.Unavailable)
+}
+
+func TestRequireAPIKey(t *testing.T) {
+	next := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "served", nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/lockpb.LockService/Lock"}
+
+	tests := []struct {
+		name     string
+		keys     []string
+		metadata []string
+		want     codes.Code
+	}{
+		{name: "no keys configured", keys: []string{""}, want: codes.OK},
+		{name: "x-api-key", keys: []string{"k-1", "k-2"}, metadata: []string{"x-api-key", "k-2"}, want: codes.OK},
+		{name: "bearer", keys: []string{"k-1"}, metadata: []string{"authorization", "bearer k-1"}, want: codes.OK},
+		{name: "missing", keys: []string{"k-1"}, want: codes.Unauthenticated},
+		{name: "invalid", keys: []string{"k-1"}, metadata: []string{"x-api-key", "k-3"}, want: codes.Unauthenticated},
+		{name: "other scheme", keys: []string{"k-1"}, metadata: []string{"authorization", "Basic k-1"}, want: codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(tt.metadata...))
+		_, err := RequireAPIKey(tt.keys...)(ctx, nil, info, next)
+		if code := status.Code(err); code != tt.want {
+			t.Errorf("%s: code = %s, want %s", tt.name, code, tt.want)
+		}
+	}
+}
+
+// assertCode checks the gRPC status code of err
+func assertCode(t *testing.T, err error, want codes.Code) {
+	t.Helper()
+
+	if code := status.Code(err); code != want {
+		t.Errorf("code = %s (%v), want %s", code, err, want)
+	}
+}
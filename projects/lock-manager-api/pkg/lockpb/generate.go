@@ -0,0 +1,5 @@
+// Package lockpb holds the gRPC interface of the lock manager, generated from lock.proto, along with the
+// generated client (NewLockServiceClient).
+package lockpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative lock.proto
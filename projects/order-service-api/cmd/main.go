@@ -36,14 +36,16 @@ func main() {
 
 	// Instância do cliente de lock
 	lockServiceUrl := getEnv("LOCK_SERVICE_URL", "http://localhost:8181")
-	lockClient := locker.NewLockClient(lockServiceUrl)
+	lockClient := locker.NewLockClient(lockServiceUrl, locker.WithAPIKey(getEnv("LOCK_SERVICE_API_KEY", "")))
 
 	// Configuração do router
 	r := chi.NewRouter()
 	r.Use(middleware.Logger)
 
 	// Registro dos handlers
-	r.Post("/order", handler.NewOrderHandler(inventoryRepo, lockClient))
+	r.Post("/order", handler.NewOrderHandler(inventoryRepo, lockClient,
+		handler.WithFailOpen(getEnvAsBool("LOCK_FAIL_OPEN", false)),
+	))
 
 	// Inicialização do servidor
 	log.Println("Starting order-service-api on :9090...")
@@ -69,3 +71,13 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+// getEnvAsBool retorna o valor da variável de ambiente como bool ou um valor padrão
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value, exists := os.LookupEnv(key); exists {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
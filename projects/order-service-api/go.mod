@@ -5,4 +5,12 @@ go 1.22.2
 require (
 	github.com/go-chi/chi/v5 v5.2.0
 	github.com/lib/pq v1.10.9
+	go.opentelemetry.io/otel v1.28.0
+	go.opentelemetry.io/otel/trace v1.28.0
+)
+
+require (
+	github.com/go-logr/logr v1.4.2 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	go.opentelemetry.io/otel/metric v1.28.0 // indirect
 )
@@ -3,8 +3,10 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"github.com/Waelson/lock-manager-service/order-service-api/internal/repository"
 	"github.com/Waelson/lock-manager-service/order-service-api/pkg/sdk/locker"
+	"log"
 	"net/http"
 	"time"
 )
@@ -18,8 +20,37 @@ type OrderResponse struct {
 	Message string `json:"message"`
 }
 
+// Tempo de vida do lock do item e tempo máximo de espera para adquiri-lo
+const (
+	orderLockTTL    = 500 * time.Millisecond
+	orderLockExpire = 100 * time.Millisecond
+)
+
+// Prazo da liberação do lock, independente do prazo do pedido, que pode já ter acabado
+const orderReleaseTimeout = 500 * time.Millisecond
+
+// Option define uma opção funcional para o handler de pedidos
+type Option func(*orderHandler)
+
+type orderHandler struct {
+	failOpen bool
+}
+
+// WithFailOpen permite processar o pedido sem lock quando o serviço de lock está indisponível.
+// Nesse modo a consistência do estoque depende apenas do banco de dados.
+func WithFailOpen(failOpen bool) Option {
+	return func(h *orderHandler) {
+		h.failOpen = failOpen
+	}
+}
+
 // NewOrderHandler cria um handler para o endpoint /order
-func NewOrderHandler(repo *repository.InventoryRepository, lockClient *locker.LockClient) http.HandlerFunc {
+func NewOrderHandler(repo *repository.InventoryRepository, lockClient *locker.LockClient, opts ...Option) http.HandlerFunc {
+	h := &orderHandler{}
+	for _, opt := range opts {
+		opt(h)
+	}
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req OrderRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -31,20 +62,52 @@ func NewOrderHandler(repo *repository.InventoryRepository, lockClient *locker.Lo
 		defer cancelFunc()
 
 		// Adquire o lock para o item
-		lock, releaseFunc, err := lockClient.Acquire(ctx, req.ItemName, "50ms", "100ms")
+		lock, _, err := lockClient.AcquireDuration(ctx, req.ItemName, orderLockTTL, orderLockExpire)
 		if err != nil {
-			http.Error(w, "Failed to acquire lock", http.StatusConflict)
-			return
+			// Item disputado por outros pedidos durante toda a janela de espera
+			if errors.Is(err, locker.ErrTimeout) {
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, "Item busy, try again later", http.StatusTooManyRequests)
+				return
+			}
+			// O prazo do próprio pedido acabou antes de o lock ser obtido
+			if errors.Is(err, context.DeadlineExceeded) {
+				http.Error(w, "Timed out acquiring lock", http.StatusGatewayTimeout)
+				return
+			}
+			if !isLockServiceDown(err) {
+				http.Error(w, "Failed to acquire lock", http.StatusConflict)
+				return
+			}
+			if !h.failOpen {
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, "Lock service unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			log.Printf("Lock service unavailable, processing order for '%s' without lock: %v", req.ItemName, err)
+		} else {
+			//Vamos garantir que o lock seja sempre liberado, mesmo depois de o prazo do pedido acabar
+			defer func() {
+				releaseCtx, cancel := context.WithTimeout(context.Background(), orderReleaseTimeout)
+				defer cancel()
+				if err := lockClient.Release(releaseCtx, lock); err != nil {
+					log.Printf("Failed to release lock of '%s': %v", req.ItemName, err)
+				}
+			}()
 		}
 
-		//Vamos garantir que o lock seja sempre liberado
-		defer releaseFunc()
-
 		// Verifica a quantidade disponível
-		availableQuantity, err := repo.GetAvailableQuantity(ctx, req.ItemName)
-		if err != nil {
+		var availableQuantity int
+		err = withDBRetry(ctx, lockClient, lock, func() (err error) {
+			availableQuantity, err = repo.GetAvailableQuantity(ctx, req.ItemName)
+			return err
+		})
+		if errors.Is(err, repository.ErrItemNotFound) {
 			http.Error(w, err.Error(), http.StatusNotFound)
 			return
+		} else if err != nil {
+			http.Error(w, "Failed to read inventory", http.StatusInternalServerError)
+			return
 		}
 
 		// Verifica se a quantidade solicitada está disponível
@@ -54,13 +117,33 @@ func NewOrderHandler(repo *repository.InventoryRepository, lockClient *locker.Lo
 		}
 
 		// Atualiza a quantidade no banco de dados
-		if err := repo.DecrementQuantity(ctx, req.ItemName, req.Quantity); err != nil {
+		decrement := func() error {
+			err := repo.DecrementQuantity(ctx, req.ItemName, req.Quantity)
+			if err == nil || errors.Is(err, repository.ErrInsufficientStock) {
+				return err
+			}
+			// A falha pode ter ocorrido depois de o UPDATE ser aplicado. Com o lock mantido ninguém mais
+			// altera o item, então a quantidade atual diz se ele foi aplicado e evita decrementar duas vezes.
+			if current, readErr := repo.GetAvailableQuantity(ctx, req.ItemName); readErr == nil && current == availableQuantity-req.Quantity {
+				return nil
+			}
+			return err
+		}
+		if lock != nil {
+			err = withDBRetry(ctx, lockClient, lock, decrement)
+		} else {
+			// Sem lock (fail-open) outro pedido pode alterar o item ao mesmo tempo, e a quantidade atual não
+			// diz se um UPDATE que falhou foi aplicado: o erro é devolvido, sem conferir nem repetir
+			err = repo.DecrementQuantity(ctx, req.ItemName, req.Quantity)
+		}
+		if errors.Is(err, repository.ErrInsufficientStock) {
+			http.Error(w, "Insufficient quantity available", http.StatusConflict)
+			return
+		} else if err != nil {
 			http.Error(w, "Failed to update inventory", http.StatusInternalServerError)
 			return
 		}
 
-		lockClient.Release(ctx, lock)
-
 		// Retorna resposta de sucesso
 		res := OrderResponse{
 			Message: "Order successfully placed",
@@ -69,3 +152,9 @@ func NewOrderHandler(repo *repository.InventoryRepository, lockClient *locker.Lo
 		json.NewEncoder(w).Encode(res)
 	}
 }
+
+// isLockServiceDown indica se o erro veio da indisponibilidade do serviço de lock e não de um conflito
+func isLockServiceDown(err error) bool {
+	return errors.Is(err, locker.ErrUnavailable) || errors.Is(err, locker.ErrServerError) ||
+		errors.Is(err, locker.ErrCircuitOpen)
+}
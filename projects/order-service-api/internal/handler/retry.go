@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Waelson/lock-manager-service/order-service-api/internal/repository"
+	"github.com/Waelson/lock-manager-service/order-service-api/pkg/sdk/locker"
+	"math/rand"
+	"time"
+)
+
+// Novas tentativas das operações no banco feitas enquanto o lock do item é mantido
+const (
+	dbMaxAttempts     = 3
+	dbInitialBackoff  = 10 * time.Millisecond
+	dbMaxJitter       = 10 * time.Millisecond
+	lockRefreshMargin = 50 * time.Millisecond // Folga antes da expiração do lock para renová-lo
+)
+
+// withDBRetry executa op até dbMaxAttempts vezes, com backoff exponencial e jitter entre as tentativas,
+// para que uma falha momentânea do banco não desperdice o lock já adquirido. Se a próxima tentativa
+// puder ultrapassar a validade do lock, ele é renovado antes; se a renovação falhar, as tentativas param,
+// pois o item não estaria mais protegido. Item inexistente ou sem estoque não é repetido. lock pode ser nil (fail-open).
+func withDBRetry(ctx context.Context, lockClient *locker.LockClient, lock *locker.Lock, op func() error) error {
+	backoff := dbInitialBackoff
+
+	for attempt := 1; ; attempt++ {
+		err := op()
+		if err == nil || attempt >= dbMaxAttempts || errors.Is(err, repository.ErrItemNotFound) ||
+			errors.Is(err, repository.ErrInsufficientStock) {
+			return err
+		}
+
+		delay := backoff + time.Duration(rand.Int63n(int64(dbMaxJitter)))
+		backoff *= 2
+
+		if lock != nil && time.Until(lockExpiry(lock)) < delay+lockRefreshMargin {
+			if refreshErr := lockClient.RefreshDuration(ctx, lock, orderLockTTL); refreshErr != nil {
+				return fmt.Errorf("%w (lock not refreshed for a new attempt: %v)", err, refreshErr)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// lockExpiry estima até quando o lock pode ser considerado seguro
+func lockExpiry(lock *locker.Lock) time.Time {
+	if lock.Validity > 0 {
+		return lock.StartTime.Add(lock.Validity)
+	}
+	return lock.StartTime.Add(lock.TTL)
+}
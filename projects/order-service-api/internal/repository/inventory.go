@@ -3,9 +3,17 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 )
 
+var (
+	// ErrItemNotFound indica que o item não existe no estoque
+	ErrItemNotFound = errors.New("item not found")
+	// ErrInsufficientStock indica que o estoque não cobre a quantidade pedida
+	ErrInsufficientStock = errors.New("insufficient stock")
+)
+
 // InventoryRepository representa o repositório para manipulação do estoque
 type InventoryRepository struct {
 	db *sql.DB
@@ -21,15 +29,29 @@ func (r *InventoryRepository) GetAvailableQuantity(ctx context.Context, itemName
 	var quantity int
 	err := r.db.QueryRowContext(ctx, "SELECT quantity FROM tb_inventory WHERE item_name = $1", itemName).Scan(&quantity)
 	if err == sql.ErrNoRows {
-		return 0, fmt.Errorf("item '%s' not found", itemName)
+		return 0, fmt.Errorf("%w: '%s'", ErrItemNotFound, itemName)
 	} else if err != nil {
 		return 0, err
 	}
 	return quantity, nil
 }
 
-// DecrementQuantity decrementa a quantidade de um item no estoque
+// DecrementQuantity decrementa a quantidade de um item no estoque. O próprio UPDATE exige estoque
+// suficiente, de modo que o estoque nunca fica negativo mesmo que dois pedidos escapem do lock
+// (ex.: lock expirado no meio da operação); nesse caso retorna ErrInsufficientStock.
 func (r *InventoryRepository) DecrementQuantity(ctx context.Context, itemName string, quantity int) error {
-	_, err := r.db.ExecContext(ctx, "UPDATE tb_inventory SET quantity = quantity - $1 WHERE item_name = $2", quantity, itemName)
-	return err
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE tb_inventory SET quantity = quantity - $1 WHERE item_name = $2 AND quantity >= $1", quantity, itemName)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("%w: '%s'", ErrInsufficientStock, itemName)
+	}
+	return nil
 }
@@ -0,0 +1,34 @@
+package locker
+
+import "net/http"
+
+// WithAPIKey sends key as "X-API-Key" on every request, for lock services started with API_KEYS
+func WithAPIKey(key string) Option {
+	return func(sdk *LockClient) {
+		sdk.apiKey = key
+	}
+}
+
+// apiKeyTransport adds the API key to each request before handing it to the wrapped transport
+type apiKeyTransport struct {
+	key  string
+	base http.RoundTripper
+}
+
+func (t *apiKeyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context()) // A RoundTripper must not modify the caller's request
+	req.Header.Set("X-API-Key", t.key)
+	return t.base.RoundTrip(req)
+}
+
+// withAPIKeyTransport returns a copy of client sending key, leaving a client shared by the caller untouched
+func withAPIKeyTransport(client *http.Client, key string) *http.Client {
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	withKey := *client
+	withKey.Transport = &apiKeyTransport{key: key, base: base}
+	return &withKey
+}
@@ -0,0 +1,66 @@
+package locker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("lock service circuit open, failing fast")
+
+// circuitBreaker stops calling the lock service after threshold consecutive connection failures,
+// letting a single probe through once cooldown has elapsed
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openedAt  time.Time
+	probing   bool
+}
+
+// WithCircuitBreaker makes Acquire fail fast with ErrCircuitOpen after threshold consecutive failures to
+// reach the lock service, until cooldown elapses; then one half-open probe decides whether to close it
+func WithCircuitBreaker(threshold int, cooldown time.Duration) Option {
+	return func(sdk *LockClient) {
+		if threshold > 0 && cooldown > 0 {
+			sdk.breaker = &circuitBreaker{threshold: threshold, cooldown: cooldown}
+		}
+	}
+}
+
+// allow reports whether a call may reach the lock service
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true // Closed
+	}
+	if b.probing || time.Since(b.openedAt) < b.cooldown {
+		return false // Open, or half-open with the probe in flight
+	}
+	b.probing = true
+	return true
+}
+
+// record updates the breaker with the outcome of a call. Only failures to reach the service count;
+// any response from it, even a conflict or a 5xx, proves it is reachable.
+func (b *circuitBreaker) record(ctx context.Context, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	switch {
+	case errors.Is(err, ErrUnavailable):
+		b.failures++
+		if b.failures >= b.threshold {
+			b.openedAt = time.Now()
+		}
+	case err != nil && ctx.Err() != nil:
+		// The caller gave up, this says nothing about the service
+	default:
+		b.failures = 0
+	}
+}
@@ -0,0 +1,114 @@
+package locker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+// unavailable is the error of a call that never reached the lock service
+var unavailable = fmt.Errorf("%w: connection refused", ErrUnavailable)
+
+func TestBreakerOpensAfterConsecutiveFailures(t *testing.T) {
+	b := &circuitBreaker{threshold: 3, cooldown: time.Minute}
+	ctx := context.Background()
+
+	b.record(ctx, unavailable)
+	b.record(ctx, unavailable)
+	b.record(ctx, nil) // A success resets the count
+	b.record(ctx, unavailable)
+	b.record(ctx, unavailable)
+	if !b.allow() {
+		t.Fatal("the breaker opened before three consecutive failures")
+	}
+
+	b.record(ctx, unavailable)
+	if b.allow() {
+		t.Error("the breaker stayed closed after three consecutive failures")
+	}
+}
+
+func TestBreakerCountsOnlyFailuresToReachTheService(t *testing.T) {
+	b := &circuitBreaker{threshold: 1, cooldown: time.Minute}
+
+	// The service answered, even if not with a lock
+	b.record(context.Background(), ErrLockConflict)
+	b.record(context.Background(), fmt.Errorf("%w: HTTP 500", ErrServerError))
+
+	// The caller gave up, which says nothing about the service
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	b.record(cancelled, context.Canceled)
+
+	if !b.allow() {
+		t.Error("the breaker opened without a failure to reach the service")
+	}
+}
+
+func TestBreakerLetsASingleProbeThroughAfterTheCooldown(t *testing.T) {
+	b := &circuitBreaker{threshold: 1, cooldown: 20 * time.Millisecond}
+	ctx := context.Background()
+
+	b.record(ctx, unavailable)
+	if b.allow() {
+		t.Fatal("the breaker let a call through right after opening")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if !b.allow() {
+		t.Fatal("no probe was let through after the cooldown")
+	}
+	if b.allow() {
+		t.Fatal("a second call was let through while the probe was in flight")
+	}
+
+	// The probe failed: open for another cooldown
+	b.record(ctx, unavailable)
+	if b.allow() {
+		t.Fatal("the breaker closed after a failed probe")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	b.allow()
+	b.record(ctx, nil)
+	if !b.allow() || !b.allow() {
+		t.Error("the breaker stayed open after a successful probe")
+	}
+}
+
+func TestAcquireFailsFastWhileTheCircuitIsOpen(t *testing.T) {
+	// A port nothing listens on anymore
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	sdk := NewLockClient("http://"+addr, WithCircuitBreaker(1, time.Minute))
+	ctx := context.Background()
+
+	if _, _, err := sdk.TryAcquire(ctx, "item-1", time.Second); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("TryAcquire error = %v, want ErrUnavailable", err)
+	}
+	if _, _, err := sdk.TryAcquire(ctx, "item-1", time.Second); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("TryAcquire error = %v, want ErrCircuitOpen", err)
+	}
+	if _, _, err := sdk.AcquireDuration(ctx, "item-1", time.Second, time.Second); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Acquire error = %v, want ErrCircuitOpen", err)
+	}
+}
+
+func TestWithCircuitBreakerIgnoresAnInvalidConfiguration(t *testing.T) {
+	for _, sdk := range []*LockClient{
+		NewLockClient("http://localhost", WithCircuitBreaker(0, time.Minute)),
+		NewLockClient("http://localhost", WithCircuitBreaker(3, 0)),
+	} {
+		if sdk.breaker != nil {
+			t.Error("a breaker was set up without a positive threshold and cooldown")
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package locker
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// waitWindow is the number of recent waits kept per resource
+	waitWindow = 128
+	// maxTrackedResources bounds the memory used by the wait tracker
+	maxTrackedResources = 1024
+	// minStarvationSamples avoids flagging starvation before a typical wait is known
+	minStarvationSamples = 10
+	// defaultStarvationMultiple flags waits longer than this multiple of the typical wait
+	defaultStarvationMultiple = 5.0
+)
+
+// WaitStats summarizes how long acquisitions of a resource waited before succeeding or giving up
+type WaitStats struct {
+	Count   int           // Acquisitions observed in the window
+	Typical time.Duration // Median wait in the window
+	Max     time.Duration // Longest wait ever observed
+	Starved int           // Acquisitions that waited beyond the starvation threshold
+}
+
+type resourceWaits struct {
+	samples []time.Duration
+	next    int
+	max     time.Duration
+	starved int
+}
+
+type waitTracker struct {
+	mu        sync.Mutex
+	multiple  float64
+	resources map[string]*resourceWaits
+}
+
+func newWaitTracker(multiple float64) *waitTracker {
+	if multiple <= 1 {
+		multiple = defaultStarvationMultiple
+	}
+	return &waitTracker{
+		multiple:  multiple,
+		resources: make(map[string]*resourceWaits),
+	}
+}
+
+// WithStarvationThreshold sets how many times the typical wait an acquisition may wait before it is reported as starved
+func WithStarvationThreshold(multiple float64) Option {
+	return func(sdk *LockClient) {
+		sdk.waits = newWaitTracker(multiple)
+	}
+}
+
+// WaitStats returns the wait distribution observed by this client for a resource
+func (sdk *LockClient) WaitStats(resource string) WaitStats {
+	return sdk.waits.stats(resource)
+}
+
+// record adds a wait sample and reports whether it exceeded the starvation threshold, along with the
+// typical wait it was compared to
+func (t *waitTracker) record(resource string, wait time.Duration) (bool, time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	rw, ok := t.resources[resource]
+	if !ok {
+		if len(t.resources) >= maxTrackedResources {
+			// Drop an arbitrary resource to keep memory bounded
+			for key := range t.resources {
+				delete(t.resources, key)
+				break
+			}
+		}
+		rw = &resourceWaits{samples: make([]time.Duration, 0, waitWindow)}
+		t.resources[resource] = rw
+	}
+
+	typical := median(rw.samples)
+	starved := len(rw.samples) >= minStarvationSamples && float64(wait) > t.multiple*float64(typical)
+
+	if len(rw.samples) < waitWindow {
+		rw.samples = append(rw.samples, wait)
+	} else {
+		rw.samples[rw.next] = wait
+		rw.next = (rw.next + 1) % waitWindow
+	}
+	if wait > rw.max {
+		rw.max = wait
+	}
+	if starved {
+		rw.starved++
+	}
+
+	return starved, typical
+}
+
+// recordWait adds the wait of an acquisition to the tracker, reporting it to the observer when it starved
+func (sdk *LockClient) recordWait(ctx context.Context, resource string, wait time.Duration) {
+	if starved, typical := sdk.waits.record(resource, wait); starved {
+		sdk.observer.OnStarved(ctx, resource, wait, typical)
+	}
+}
+
+func (t *waitTracker) stats(resource string) WaitStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	rw, ok := t.resources[resource]
+	if !ok {
+		return WaitStats{}
+	}
+	return WaitStats{
+		Count:   len(rw.samples),
+		Typical: median(rw.samples),
+		Max:     rw.max,
+		Starved: rw.starved,
+	}
+}
+
+func median(samples []time.Duration) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+	sorted := make([]time.Duration, len(samples))
+	copy(sorted, samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[len(sorted)/2]
+}
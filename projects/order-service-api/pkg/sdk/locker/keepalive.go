@@ -0,0 +1,77 @@
+package locker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// KeepaliveErrors returns the refresh failures of a lock acquired with AcquireWithKeepalive, or nil
+// for other locks. The channel is closed once the keepalive stops.
+func (l *Lock) KeepaliveErrors() <-chan error {
+	return l.keepaliveErrs
+}
+
+// AcquireWithKeepalive behaves like AcquireDuration and then refreshes the lock every ttl/3 in the
+// background, so it doesn't expire in the middle of a slow job. The keepalive stops when ctx is
+// cancelled, when the returned release function is called, or when the lock is found to be lost.
+// Refresh failures are sent to Lock.KeepaliveErrors; a failure is dropped if the previous one
+// was not read yet.
+func (sdk *LockClient) AcquireWithKeepalive(ctx context.Context, resource string, ttl time.Duration, expire time.Duration) (*Lock, func() error, error) {
+	lock, release, err := sdk.AcquireDuration(ctx, resource, ttl, expire)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keepaliveCtx, stop := context.WithCancel(ctx)
+	errs := make(chan error, 1)
+	done := make(chan struct{})
+	lock.keepaliveErrs = errs
+
+	go func() {
+		defer close(done)
+		defer close(errs)
+
+		ticker := time.NewTicker(max(ttl/3, time.Millisecond))
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-keepaliveCtx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			err := sdk.RefreshDuration(keepaliveCtx, lock, ttl)
+			if err == nil {
+				continue
+			}
+			if keepaliveCtx.Err() != nil {
+				return
+			}
+
+			select {
+			case errs <- err:
+			default:
+			}
+
+			// Nothing left to keep alive
+			if errors.Is(err, ErrReleaseNotFound) {
+				return
+			}
+		}
+	}()
+
+	// Stop refreshing before releasing, so a late refresh can't race with the release
+	var once sync.Once
+	releaseFunc := func() error {
+		once.Do(func() {
+			stop()
+			<-done
+		})
+		return release()
+	}
+
+	return lock, releaseFunc, nil
+}
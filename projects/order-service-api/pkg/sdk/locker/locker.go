@@ -2,12 +2,19 @@ package locker
 
 import (
 	"context"
+	crand "crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"math/rand"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -16,24 +23,48 @@ var (
 	ErrTimeout         = errors.New("operation timed out")
 	ErrServerError     = errors.New("internal server error")
 	ErrReleaseNotFound = errors.New("lock not found or already released (HTTP 404)")
+	ErrUnavailable     = errors.New("lock service unavailable")
+	ErrInvalidTTL      = errors.New("ttl must be greater than zero")
+	ErrAttemptTimeout  = errors.New("acquire attempt timed out")
+	ErrRateLimited     = errors.New("too many acquire attempts (HTTP 429)")
+	ErrInvalidBackoff  = errors.New("invalid backoff configuration")
+)
+
+// Common TTL and expire values, usable with AcquireDuration and RefreshDuration
+const (
+	DefaultTTL    = 10 * time.Second // Same TTL the server applies when refresh omits it
+	DefaultExpire = 5 * time.Second
+)
+
+// Backoff applied when the client isn't given one
+const (
+	defaultBackoffInitial   = 100 * time.Millisecond
+	defaultBackoffMax       = 5 * time.Second
+	defaultBackoffMaxJitter = 500 * time.Millisecond
 )
 
 type Lock struct {
 	Token     string
 	Resource  string
+	Fence     int64         // Fencing token, pass it to downstream resources so they can reject stale writers
+	TTL       time.Duration // TTL of the lock keys, as granted by the server, with millisecond precision
+	Validity  time.Duration // Time the lock could safely be relied on from StartTime, as granted by the server
 	StartTime time.Time
+
+	keepaliveErrs chan error
 }
 
-func newLock(token string, resource string) *Lock {
+func newLock(token string, resource string, fence int64) *Lock {
 	return &Lock{
 		Token:     token,
 		Resource:  resource,
+		Fence:     fence,
 		StartTime: time.Now(),
 	}
 }
 
 func (l *Lock) String() string {
-	return fmt.Sprintf("Token: %s Resource: %s StartTime: %s", l.Token, l.Resource, l.StartTime.String())
+	return fmt.Sprintf("Token: %s Resource: %s Fence: %d StartTime: %s", l.Token, l.Resource, l.Fence, l.StartTime.String())
 }
 
 // ExponentialBackoff represents the configuration for exponential backoff with jitter
@@ -47,7 +78,20 @@ type ExponentialBackoff struct {
 type LockClient struct {
 	baseURL       string
 	httpClient    *http.Client
+	timeout       time.Duration
+	attempt       time.Duration // Bound of each acquire attempt, 0 for none
 	backoffConfig *ExponentialBackoff
+	concurrency   int
+	waits         *waitTracker
+	startupJitter time.Duration
+	startupAt     time.Time
+	retryOn5xx    bool
+	breaker       *circuitBreaker
+	tracer        trace.Tracer
+	apiKey        string
+	jitterMu      sync.Mutex // rand.Rand isn't safe for concurrent use
+	jitterSource  *rand.Rand
+	observer      Observer
 }
 
 // Option defines a functional option for LockClient
@@ -60,6 +104,71 @@ func WithExponentialBackoff(backoff *ExponentialBackoff) Option {
 	}
 }
 
+// WithHTTPClient replaces the default HTTP client (10s timeout) entirely, e.g. to share a tuned
+// transport, set a proxy or TLS configuration, or use another timeout
+func WithHTTPClient(client *http.Client) Option {
+	return func(sdk *LockClient) {
+		if client != nil {
+			sdk.httpClient = client
+		}
+	}
+}
+
+// WithTimeout bounds each HTTP request to the lock service, 10s by default. Keep it within the caller's
+// latency budget, so a hung lock service fails fast even when the context has no deadline. It also
+// applies to a client given with WithHTTPClient, without changing that client.
+func WithTimeout(timeout time.Duration) Option {
+	return func(sdk *LockClient) {
+		if timeout > 0 {
+			sdk.timeout = timeout
+		}
+	}
+}
+
+// WithAttemptTimeout bounds each acquire attempt made by Acquire, so a slow attempt is abandoned and
+// retried within the expire window instead of eating it up. The attempts of one acquisition share a
+// token, so a retry gets back the lock an abandoned attempt may have taken on the server.
+func WithAttemptTimeout(timeout time.Duration) Option {
+	return func(sdk *LockClient) {
+		if timeout > 0 {
+			sdk.attempt = timeout
+		}
+	}
+}
+
+// WithConcurrency bounds how many HTTP requests the multi-lock operations run in parallel
+func WithConcurrency(n int) Option {
+	return func(sdk *LockClient) {
+		sdk.concurrency = n
+	}
+}
+
+// WithStartupJitter delays the first acquire attempts by a random duration up to max, so a fleet
+// booting at once doesn't contend for the same lock at the same instant
+func WithStartupJitter(max time.Duration) Option {
+	return func(sdk *LockClient) {
+		sdk.startupJitter = max
+	}
+}
+
+// WithRetryOnServerError makes Acquire retry 5xx responses with the same backoff used for conflicts,
+// within the expire window, instead of failing on the first one. Other errors still fail fast.
+func WithRetryOnServerError() Option {
+	return func(sdk *LockClient) {
+		sdk.retryOn5xx = true
+	}
+}
+
+// WithJitterSource sets the random source used for the backoff and startup jitter, e.g. a fixed seed
+// for reproducible tests. By default each client seeds its own, avoiding the lock on the global source.
+func WithJitterSource(source *rand.Rand) Option {
+	return func(sdk *LockClient) {
+		if source != nil {
+			sdk.jitterSource = source
+		}
+	}
+}
+
 // NewLockClient initializes a new instance of LockClient with optional functional options
 func NewLockClient(baseURL string, opts ...Option) *LockClient {
 	sdk := &LockClient{
@@ -71,13 +180,46 @@ func NewLockClient(baseURL string, opts ...Option) *LockClient {
 		opt(sdk)
 	}
 
-	// Set default backoff if not provided
-	if sdk.backoffConfig == nil {
-		sdk.backoffConfig = &ExponentialBackoff{
-			Initial:   100 * time.Millisecond,
-			Max:       5 * time.Second,
-			MaxJitter: 500 * time.Millisecond,
-		}
+	if sdk.jitterSource == nil {
+		sdk.jitterSource = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+
+	if sdk.startupJitter > 0 {
+		sdk.startupAt = time.Now().Add(sdk.randomJitter(sdk.startupJitter))
+	}
+
+	if sdk.waits == nil {
+		sdk.waits = newWaitTracker(defaultStarvationMultiple)
+	}
+
+	if sdk.concurrency <= 0 {
+		sdk.concurrency = defaultConcurrency
+	}
+
+	if sdk.tracer == nil {
+		sdk.tracer = otel.GetTracerProvider().Tracer(tracerName)
+	}
+
+	if sdk.observer == nil {
+		sdk.observer = NoopObserver{}
+	}
+
+	// Set default backoff if not provided, and fill in whatever a custom one leaves unusable, reporting
+	// the adjustments to the observer
+	backoff, err := normalizeBackoff(sdk.backoffConfig)
+	if err != nil {
+		sdk.observer.OnError(context.Background(), "", "configure", err)
+	}
+	sdk.backoffConfig = backoff
+
+	if sdk.timeout > 0 {
+		withTimeout := *sdk.httpClient
+		withTimeout.Timeout = sdk.timeout
+		sdk.httpClient = &withTimeout
+	}
+
+	if sdk.apiKey != "" {
+		sdk.httpClient = withAPIKeyTransport(sdk.httpClient, sdk.apiKey)
 	}
 
 	return sdk
@@ -86,10 +228,6 @@ func NewLockClient(baseURL string, opts ...Option) *LockClient {
 // Acquire tries to acquire a lock, retrying if the API returns HTTP 409, within the "expire" duration.
 // Returns the token and a release function.
 func (sdk *LockClient) Acquire(ctx context.Context, resource string, ttl string, expire string) (*Lock, func() error, error) {
-	if resource == "" {
-		return nil, nil, errors.New("resource must not be empty")
-	}
-
 	ttlDuration, err := time.ParseDuration(ttl)
 	if err != nil {
 		return nil, nil, fmt.Errorf("invalid TTL value: %w", err)
@@ -100,10 +238,49 @@ func (sdk *LockClient) Acquire(ctx context.Context, resource string, ttl string,
 		return nil, nil, fmt.Errorf("invalid expire value: %w", err)
 	}
 
-	endTime := time.Now().Add(expireDuration)
+	return sdk.AcquireDuration(ctx, resource, ttlDuration, expireDuration)
+}
+
+// AcquireDuration behaves like Acquire but takes the TTL and expire window as time.Duration values
+func (sdk *LockClient) AcquireDuration(ctx context.Context, resource string, ttl time.Duration, expire time.Duration) (*Lock, func() error, error) {
+	ctx, span := sdk.startSpan(ctx, "LockClient.Acquire", resource)
+	startTime := time.Now()
+	lock, releaseFunc, err := sdk.acquireDuration(ctx, resource, ttl, expire)
+	if lock != nil {
+		span.SetAttributes(attribute.Int64("lock.fence", lock.Fence))
+	}
+	endSpan(span, err)
+	sdk.observeAcquire(ctx, resource, lock, err, startTime)
+	return lock, releaseFunc, err
+}
+
+func (sdk *LockClient) acquireDuration(ctx context.Context, resource string, ttl time.Duration, expire time.Duration) (*Lock, func() error, error) {
+	if resource == "" {
+		return nil, nil, errors.New("resource must not be empty")
+	}
+	if ttl <= 0 {
+		return nil, nil, ErrInvalidTTL
+	}
+
+	if err := sdk.waitStartupJitter(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	startTime := time.Now()
+	endTime := startTime.Add(expire)
 	backoff := sdk.backoffConfig.Initial
 
-	var token string
+	var lock *Lock
+	var err error
+	attempt := 0
+
+	// Retried attempts must not leave behind a lock taken by an abandoned one
+	token := ""
+	if sdk.attempt > 0 {
+		if token, err = newToken(); err != nil {
+			return nil, nil, err
+		}
+	}
 
 	for {
 		select {
@@ -112,27 +289,60 @@ func (sdk *LockClient) Acquire(ctx context.Context, resource string, ttl string,
 		default:
 		}
 
-		token, err = sdk.tryAcquire(ctx, resource, ttlDuration)
+		if sdk.breaker != nil && !sdk.breaker.allow() {
+			return nil, nil, ErrCircuitOpen
+		}
+
+		attempt++
+		sdk.observer.OnAttempt(ctx, resource, attempt)
+		attemptCtx, cancel := sdk.attemptContext(ctx)
+		lock, err = sdk.tryAcquire(attemptCtx, resource, ttl, token)
+		if err != nil && ctx.Err() == nil && attemptCtx.Err() != nil {
+			err = fmt.Errorf("%w: %w", ErrAttemptTimeout, err)
+		}
+		if sdk.breaker != nil {
+			sdk.breaker.record(attemptCtx, err) // An abandoned attempt neither trips nor resets the breaker
+		}
+		cancel()
 		if err == nil {
 			break
 		}
+		if errors.Is(err, ErrLockConflict) {
+			sdk.observer.OnConflict(ctx, resource, attempt)
+		}
 
-		if !errors.Is(err, ErrLockConflict) {
+		serverError := sdk.retryOn5xx && errors.Is(err, ErrServerError)
+		if !errors.Is(err, ErrLockConflict) && !serverError && !errors.Is(err, ErrAttemptTimeout) &&
+			!errors.Is(err, ErrRateLimited) {
 			return nil, nil, err
 		}
 
 		// Check if we are out of time
 		if time.Now().After(endTime) {
+			sdk.recordWait(ctx, resource, time.Since(startTime))
+			if serverError {
+				return nil, nil, err // The service never answered properly, it isn't a plain timeout
+			}
 			return nil, nil, ErrTimeout
 		}
 
-		// Apply exponential backoff with jitter
+		// Apply exponential backoff with jitter, or wait longer if the server asked to, within the window
 		backoff = sdk.calculateBackoff(backoff)
-		fmt.Printf("Resource '%s' locked. Let's wait...\n", resource)
-		time.Sleep(backoff)
+		wait := backoff
+		var hint *retryAfterError
+		if errors.As(err, &hint) && hint.after > wait {
+			wait = max(wait, min(hint.after, time.Until(endTime)))
+		}
+
+		// Give up as soon as the caller does, instead of sleeping through the backoff
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-time.After(wait):
+		}
 	}
 
-	lock := newLock(token, resource)
+	sdk.recordWait(ctx, resource, time.Since(startTime))
 
 	// Release function
 	releaseFunc := func() error {
@@ -142,6 +352,94 @@ func (sdk *LockClient) Acquire(ctx context.Context, resource string, ttl string,
 	return lock, releaseFunc, nil
 }
 
+// TryAcquire makes a single acquire attempt and returns ErrLockConflict right away if the resource is
+// held, without backoff or startup jitter, for callers that prefer to reject rather than wait
+func (sdk *LockClient) TryAcquire(ctx context.Context, resource string, ttl time.Duration) (*Lock, func() error, error) {
+	ctx, span := sdk.startSpan(ctx, "LockClient.TryAcquire", resource)
+	startTime := time.Now()
+	lock, releaseFunc, err := sdk.tryAcquireOnce(ctx, resource, ttl)
+	if lock != nil {
+		span.SetAttributes(attribute.Int64("lock.fence", lock.Fence))
+	}
+	endSpan(span, err)
+	sdk.observeAcquire(ctx, resource, lock, err, startTime)
+	return lock, releaseFunc, err
+}
+
+func (sdk *LockClient) tryAcquireOnce(ctx context.Context, resource string, ttl time.Duration) (*Lock, func() error, error) {
+	if resource == "" {
+		return nil, nil, errors.New("resource must not be empty")
+	}
+	if ttl <= 0 {
+		return nil, nil, ErrInvalidTTL
+	}
+
+	if sdk.breaker != nil && !sdk.breaker.allow() {
+		return nil, nil, ErrCircuitOpen
+	}
+
+	sdk.observer.OnAttempt(ctx, resource, 1)
+	lock, err := sdk.tryAcquire(ctx, resource, ttl, "")
+	if sdk.breaker != nil {
+		sdk.breaker.record(ctx, err)
+	}
+	if errors.Is(err, ErrLockConflict) {
+		sdk.observer.OnConflict(ctx, resource, 1)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	releaseFunc := func() error {
+		return sdk.Release(ctx, lock)
+	}
+
+	return lock, releaseFunc, nil
+}
+
+// waitStartupJitter blocks until the client's randomized start instant, if it is still ahead
+func (sdk *LockClient) waitStartupJitter(ctx context.Context) error {
+	delay := time.Until(sdk.startupAt)
+	if delay <= 0 {
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(delay):
+		return nil
+	}
+}
+
+// normalizeBackoff returns a copy of backoff safe to use: a non-positive Initial falls back to the
+// default, Max is never below Initial, and a negative MaxJitter means no jitter. The error, wrapping
+// ErrInvalidBackoff, describes the values that had to be replaced.
+func normalizeBackoff(backoff *ExponentialBackoff) (*ExponentialBackoff, error) {
+	if backoff == nil {
+		return &ExponentialBackoff{
+			Initial:   defaultBackoffInitial,
+			Max:       defaultBackoffMax,
+			MaxJitter: defaultBackoffMaxJitter,
+		}, nil
+	}
+
+	normalized := *backoff
+	errs := make([]error, 0)
+	if normalized.Initial <= 0 {
+		errs = append(errs, fmt.Errorf("%w: initial %s, using %s", ErrInvalidBackoff, normalized.Initial, defaultBackoffInitial))
+		normalized.Initial = defaultBackoffInitial
+	}
+	if normalized.Max < normalized.Initial {
+		errs = append(errs, fmt.Errorf("%w: max %s below initial %s, using the initial", ErrInvalidBackoff, normalized.Max, normalized.Initial))
+		normalized.Max = normalized.Initial
+	}
+	if normalized.MaxJitter < 0 {
+		normalized.MaxJitter = 0
+	}
+	return &normalized, errors.Join(errs...)
+}
+
 func (sdk *LockClient) calculateBackoff(currentBackoff time.Duration) time.Duration {
 	nextBackoff := currentBackoff * 2
 	if nextBackoff > sdk.backoffConfig.Max {
@@ -149,53 +447,133 @@ func (sdk *LockClient) calculateBackoff(currentBackoff time.Duration) time.Durat
 	}
 
 	// Add jitter
-	jitter := time.Duration(rand.Int63n(int64(sdk.backoffConfig.MaxJitter)))
-	return nextBackoff + jitter
+	return nextBackoff + sdk.randomJitter(sdk.backoffConfig.MaxJitter)
+}
+
+// randomJitter draws a duration in [0, max) from the client's source, or 0 when max isn't positive
+// (rand.Int63n panics on a non-positive bound)
+func (sdk *LockClient) randomJitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+
+	sdk.jitterMu.Lock()
+	defer sdk.jitterMu.Unlock()
+	return time.Duration(sdk.jitterSource.Int63n(int64(max)))
+}
+
+// attemptContext derives the context of a single acquire attempt, bounded by WithAttemptTimeout
+func (sdk *LockClient) attemptContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if sdk.attempt > 0 {
+		return context.WithTimeout(ctx, sdk.attempt)
+	}
+	return context.WithCancel(ctx)
+}
+
+// newToken generates a random lock token, for acquisitions that choose their own
+func newToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := crand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// newNonce generates the replay-protection nonce of a release or refresh request, prefixed with its
+// creation time as the server requires when REQUIRE_NONCE is set
+func newNonce() (string, error) {
+	random, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(time.Now().Unix(), 10) + "." + random, nil
 }
 
-func (sdk *LockClient) tryAcquire(ctx context.Context, resource string, ttl time.Duration) (string, error) {
+// tryAcquire makes one acquire request, with the given token unless it is empty
+func (sdk *LockClient) tryAcquire(ctx context.Context, resource string, ttl time.Duration, token string) (*Lock, error) {
 	url := fmt.Sprintf("%s/lock", sdk.baseURL)
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	query := req.URL.Query()
 	query.Add("resource", resource)
 	query.Add("ttl", ttl.String())
+	if token != "" {
+		query.Add("token", token)
+	}
 	req.URL.RawQuery = query.Encode()
+	injectTrace(ctx, req)
 
 	resp, err := sdk.httpClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to make request: %w", err)
+		return nil, requestError(ctx, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode == http.StatusConflict {
-		return "", ErrLockConflict
+		return nil, withRetryAfter(ErrLockConflict, retryAfter(resp))
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, withRetryAfter(ErrRateLimited, retryAfter(resp))
+	}
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return nil, fmt.Errorf("%w: HTTP %d", ErrServerError, resp.StatusCode)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", ErrServerError
+		return nil, fmt.Errorf("failed to acquire lock: HTTP %d", resp.StatusCode)
 	}
 
 	var res struct {
 		Token string `json:"token"`
+		Ttl   string `json:"ttl"`
+		TtlMs int64  `json:"ttl_ms"`
+		Fence int64  `json:"fence"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
-		return "", fmt.Errorf("failed to parse response: %w", err)
+		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
 	if res.Token == "" {
-		return "", errors.New("no token returned from server")
+		return nil, errors.New("no token returned from server")
+	}
+
+	lock := newLock(res.Token, resource, res.Fence)
+	lock.Validity, _ = time.ParseDuration(res.Ttl) // Zero if the server didn't report it
+	lock.TTL = time.Duration(res.TtlMs) * time.Millisecond
+	if lock.TTL == 0 {
+		lock.TTL = ttl // Older servers don't report it, the requested TTL is what they granted
 	}
+	return lock, nil
+}
 
-	return res.Token, nil
+// requestError wraps a transport failure, flagging it with ErrUnavailable unless the caller gave up first
+func requestError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	return fmt.Errorf("%w: failed to make request: %w", ErrUnavailable, err)
 }
 
 // Release releases a lock associated with the given resource and token
 func (sdk *LockClient) Release(ctx context.Context, lock *Lock) error {
+	ctx, span := sdk.startSpan(ctx, "LockClient.Release", lock.Resource)
+	err := sdk.release(ctx, lock)
+	endSpan(span, err)
+	if err != nil {
+		sdk.observer.OnError(ctx, lock.Resource, "release", err)
+	} else {
+		sdk.observer.OnReleased(ctx, lock)
+	}
+	return err
+}
+
+func (sdk *LockClient) release(ctx context.Context, lock *Lock) error {
 	if lock.Resource == "" {
 		return errors.New("resource must not be empty")
 	}
@@ -210,14 +588,21 @@ func (sdk *LockClient) Release(ctx context.Context, lock *Lock) error {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 
+	nonce, err := newNonce()
+	if err != nil {
+		return err
+	}
+
 	query := req.URL.Query()
 	query.Add("resource", lock.Resource)
 	query.Add("token", lock.Token)
+	query.Add("nonce", nonce)
 	req.URL.RawQuery = query.Encode()
+	injectTrace(ctx, req)
 
 	resp, err := sdk.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to make request: %w", err)
+		return requestError(ctx, err)
 	}
 	defer resp.Body.Close()
 
@@ -231,15 +616,15 @@ func (sdk *LockClient) Release(ctx context.Context, lock *Lock) error {
 
 	// Optional: Decode response for additional logging or validation
 	var res struct {
-		Code    int    `json:"code"`
-		Message string `json:"message,omitempty"`
+		Code  int    `json:"code"`
+		Error string `json:"error,omitempty"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
 		return fmt.Errorf("failed to parse response: %w", err)
 	}
 
 	if res.Code != http.StatusOK {
-		return fmt.Errorf("unexpected response code: %d, message: %s", res.Code, res.Message)
+		return fmt.Errorf("unexpected response code: %d, error: %s", res.Code, res.Error)
 	}
 
 	return nil
@@ -247,16 +632,34 @@ func (sdk *LockClient) Release(ctx context.Context, lock *Lock) error {
 
 // Refresh extends the TTL of a lock to keep it active
 func (sdk *LockClient) Refresh(ctx context.Context, lock *Lock, ttl string) error {
+	ttlDuration, err := time.ParseDuration(ttl)
+	if err != nil {
+		return fmt.Errorf("invalid TTL value: %w", err)
+	}
+
+	return sdk.RefreshDuration(ctx, lock, ttlDuration)
+}
+
+// RefreshDuration behaves like Refresh but takes the TTL as a time.Duration value
+func (sdk *LockClient) RefreshDuration(ctx context.Context, lock *Lock, ttl time.Duration) error {
+	ctx, span := sdk.startSpan(ctx, "LockClient.Refresh", lock.Resource)
+	err := sdk.refreshDuration(ctx, lock, ttl)
+	endSpan(span, err)
+	if err != nil {
+		sdk.observer.OnError(ctx, lock.Resource, "refresh", err)
+	}
+	return err
+}
+
+func (sdk *LockClient) refreshDuration(ctx context.Context, lock *Lock, ttl time.Duration) error {
 	if lock.Resource == "" {
 		return errors.New("resource must not be empty")
 	}
 	if lock.Token == "" {
 		return errors.New("token must not be empty")
 	}
-
-	ttlDuration, err := time.ParseDuration(ttl)
-	if err != nil {
-		return fmt.Errorf("invalid TTL value: %w", err)
+	if ttl <= 0 {
+		return ErrInvalidTTL
 	}
 
 	url := fmt.Sprintf("%s/refresh", sdk.baseURL)
@@ -266,15 +669,24 @@ func (sdk *LockClient) Refresh(ctx context.Context, lock *Lock, ttl string) erro
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 
+	nonce, err := newNonce()
+	if err != nil {
+		return err
+	}
+
 	query := req.URL.Query()
 	query.Add("resource", lock.Resource)
 	query.Add("token", lock.Token)
-	query.Add("ttl", ttlDuration.String())
+	query.Add("ttl", ttl.String())
+	query.Add("nonce", nonce)
 	req.URL.RawQuery = query.Encode()
+	injectTrace(ctx, req)
 
+	// The new TTL starts ticking on the server after the request is sent, never before
+	sentAt := time.Now()
 	resp, err := sdk.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to make request: %w", err)
+		return requestError(ctx, err)
 	}
 	defer resp.Body.Close()
 
@@ -286,21 +698,77 @@ func (sdk *LockClient) Refresh(ctx context.Context, lock *Lock, ttl string) erro
 		return fmt.Errorf("failed to refresh lock: HTTP %d", resp.StatusCode)
 	}
 
-	// Optional: Decode response for logging or validation
 	var res struct {
-		Code    int    `json:"code"`
-		Message string `json:"message,omitempty"`
+		Code  int    `json:"code"`
+		Ttl   string `json:"ttl"`
+		Error string `json:"error,omitempty"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
 		return fmt.Errorf("failed to parse response: %w", err)
 	}
 
 	if res.Code != http.StatusOK {
-		return fmt.Errorf("unexpected response code: %d, message: %s", res.Code, res.Message)
+		return fmt.Errorf("unexpected response code: %d, error: %s", res.Code, res.Error)
 	}
 
-	// Update lock start time after refresh
-	lock.StartTime = time.Now()
+	// The server may have granted less than asked for, clamped to its MAX_TTL
+	granted, err := time.ParseDuration(res.Ttl)
+	if err != nil || granted <= 0 {
+		granted = ttl // Older servers don't report it, the requested TTL is what they granted
+	}
+	lock.StartTime = sentAt
+	lock.TTL = granted
+	lock.Validity = granted
 
 	return nil
 }
+
+// TTL returns how long the lock has left before it expires, so the caller can decide whether to refresh it
+func (sdk *LockClient) TTL(ctx context.Context, lock *Lock) (time.Duration, error) {
+	if lock.Resource == "" {
+		return 0, errors.New("resource must not be empty")
+	}
+	if lock.Token == "" {
+		return 0, errors.New("token must not be empty")
+	}
+
+	url := fmt.Sprintf("%s/ttl", sdk.baseURL)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	query := req.URL.Query()
+	query.Add("resource", lock.Resource)
+	query.Add("token", lock.Token)
+	req.URL.RawQuery = query.Encode()
+
+	resp, err := sdk.httpClient.Do(req)
+	if err != nil {
+		return 0, requestError(ctx, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return 0, ErrReleaseNotFound
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("failed to check lock ttl: HTTP %d", resp.StatusCode)
+	}
+
+	var res struct {
+		Ttl string `json:"ttl"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return 0, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	ttl, err := time.ParseDuration(res.Ttl)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ttl in response: %w", err)
+	}
+
+	return ttl, nil
+}
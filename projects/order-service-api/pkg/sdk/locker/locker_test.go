@@ -0,0 +1,223 @@
+package locker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newConflictServer answers every acquire attempt with a conflict and counts them
+func newConflictServer(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusConflict)
+	}))
+	t.Cleanup(server.Close)
+	return server, &attempts
+}
+
+func TestAcquireStopsBackingOffOnceTheContextIsCancelled(t *testing.T) {
+	server, attempts := newConflictServer(t)
+	sdk := NewLockClient(server.URL, WithExponentialBackoff(&ExponentialBackoff{Initial: time.Second, Max: time.Second}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, _, err := sdk.AcquireDuration(ctx, "item-1", time.Second, time.Minute)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Acquire error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Acquire returned after %s, want it to stop sleeping when the context ended", elapsed)
+	}
+	if attempts.Load() != 1 {
+		t.Errorf("%d attempts, want 1", attempts.Load())
+	}
+}
+
+func TestAcquireTimesOutAfterTheExpireWindow(t *testing.T) {
+	server, attempts := newConflictServer(t)
+	sdk := NewLockClient(server.URL, WithExponentialBackoff(&ExponentialBackoff{Initial: 5 * time.Millisecond, Max: 10 * time.Millisecond}))
+
+	_, _, err := sdk.AcquireDuration(context.Background(), "item-1", time.Second, 100*time.Millisecond)
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Acquire error = %v, want ErrTimeout", err)
+	}
+	if attempts.Load() < 2 {
+		t.Errorf("%d attempts, want the conflict retried within the window", attempts.Load())
+	}
+}
+
+func TestNormalizeBackoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		backoff *ExponentialBackoff
+		want    ExponentialBackoff
+		wantErr bool
+	}{
+		{
+			name: "default",
+			want: ExponentialBackoff{Initial: defaultBackoffInitial, Max: defaultBackoffMax, MaxJitter: defaultBackoffMaxJitter},
+		},
+		{
+			name:    "valid",
+			backoff: &ExponentialBackoff{Initial: time.Second, Max: 2 * time.Second, MaxJitter: time.Second},
+			want:    ExponentialBackoff{Initial: time.Second, Max: 2 * time.Second, MaxJitter: time.Second},
+		},
+		{
+			name:    "zero initial",
+			backoff: &ExponentialBackoff{Max: time.Second},
+			want:    ExponentialBackoff{Initial: defaultBackoffInitial, Max: time.Second},
+			wantErr: true,
+		},
+		{
+			name:    "max below initial",
+			backoff: &ExponentialBackoff{Initial: time.Second, Max: time.Millisecond},
+			want:    ExponentialBackoff{Initial: time.Second, Max: time.Second},
+			wantErr: true,
+		},
+		{
+			name:    "negative jitter",
+			backoff: &ExponentialBackoff{Initial: time.Second, Max: time.Second, MaxJitter: -time.Second},
+			want:    ExponentialBackoff{Initial: time.Second, Max: time.Second},
+		},
+	}
+	for _, tt := range tests {
+		got, err := normalizeBackoff(tt.backoff)
+		if *got != tt.want {
+			t.Errorf("%s: normalizeBackoff() = %+v, want %+v", tt.name, *got, tt.want)
+		}
+		if (err != nil) != tt.wantErr || (err != nil && !errors.Is(err, ErrInvalidBackoff)) {
+			t.Errorf("%s: normalizeBackoff() error = %v, want an ErrInvalidBackoff: %t", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestNormalizeBackoffLeavesTheCallersConfigAlone(t *testing.T) {
+	backoff := &ExponentialBackoff{Initial: time.Second, Max: time.Millisecond}
+	_, _ = normalizeBackoff(backoff)
+
+	if backoff.Max != time.Millisecond {
+		t.Errorf("Max = %s, want the caller's value unchanged", backoff.Max)
+	}
+}
+
+func TestNewLockClientReportsAnInvalidBackoff(t *testing.T) {
+	observer := &recordingObserver{}
+	NewLockClient("http://localhost", WithObserver(observer), WithExponentialBackoff(&ExponentialBackoff{Initial: -time.Second}))
+
+	if errs := observer.observed(); len(errs) != 1 || !errors.Is(errs[0], ErrInvalidBackoff) {
+		t.Errorf("errors observed = %v, want one ErrInvalidBackoff", errs)
+	}
+}
+
+func TestCalculateBackoffStaysWithinItsBounds(t *testing.T) {
+	sdk := NewLockClient("http://localhost", WithExponentialBackoff(&ExponentialBackoff{
+		Initial:   10 * time.Millisecond,
+		Max:       100 * time.Millisecond,
+		MaxJitter: 5 * time.Millisecond,
+	}))
+
+	backoff := sdk.backoffConfig.Initial
+	for i := 0; i < 10; i++ {
+		previous := backoff
+		backoff = sdk.calculateBackoff(backoff)
+
+		// Doubled up to the max, then jittered
+		base := min(previous*2, 100*time.Millisecond)
+		if backoff < base || backoff >= base+5*time.Millisecond {
+			t.Fatalf("backoff after %s = %s, want within [%s, %s)", previous, backoff, base, base+5*time.Millisecond)
+		}
+		backoff = base
+	}
+}
+
+func TestRandomJitterWithoutABound(t *testing.T) {
+	sdk := NewLockClient("http://localhost")
+
+	for _, bound := range []time.Duration{0, -time.Second} {
+		if jitter := sdk.randomJitter(bound); jitter != 0 {
+			t.Errorf("randomJitter(%s) = %s, want 0", bound, jitter)
+		}
+	}
+}
+
+// recordingObserver keeps the errors reported to it
+type recordingObserver struct {
+	NoopObserver
+	mu     sync.Mutex
+	errors []error
+}
+
+func (o *recordingObserver) OnError(ctx context.Context, resource string, operation string, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.errors = append(o.errors, err)
+}
+
+// observed returns the errors reported so far
+func (o *recordingObserver) observed() []error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]error(nil), o.errors...)
+}
+
+func TestRefreshKeepsTheTTLTheServerGranted(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("nonce") == "" {
+			t.Error("refresh sent without a nonce")
+		}
+		// Clamped to the server's MAX_TTL
+		fmt.Fprint(w, `{"code":200,"ttl":"30s"}`)
+	}))
+	defer server.Close()
+
+	sdk := NewLockClient(server.URL)
+	lock := newLock("t-1", "item-1", 1)
+	before := time.Now()
+	if err := sdk.RefreshDuration(context.Background(), lock, time.Hour); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+
+	if lock.TTL != 30*time.Second || lock.Validity != 30*time.Second {
+		t.Errorf("TTL = %s, Validity = %s, want the 30s granted", lock.TTL, lock.Validity)
+	}
+	if lock.StartTime.Before(before) {
+		t.Error("StartTime wasn't moved to the refresh")
+	}
+}
+
+func TestRefreshFallsBackToTheRequestedTTL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"code":200}`) // An older server, reporting no TTL
+	}))
+	defer server.Close()
+
+	lock := newLock("t-1", "item-1", 1)
+	if err := NewLockClient(server.URL).RefreshDuration(context.Background(), lock, time.Minute); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if lock.TTL != time.Minute {
+		t.Errorf("TTL = %s, want the minute requested", lock.TTL)
+	}
+}
+
+func TestRefreshOfAnExpiredLock(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	err := NewLockClient(server.URL).RefreshDuration(context.Background(), newLock("t-1", "item-1", 1), time.Minute)
+	if !errors.Is(err, ErrReleaseNotFound) {
+		t.Errorf("Refresh error = %v, want ErrReleaseNotFound", err)
+	}
+}
@@ -0,0 +1,97 @@
+package locker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// defaultConcurrency is the number of parallel HTTP requests used by multi-lock operations
+const defaultConcurrency = 8
+
+// AcquireMany acquires a lock for every resource, running at most the configured concurrency in parallel.
+// If any acquisition fails the locks already obtained are released and the aggregated error is returned.
+func (sdk *LockClient) AcquireMany(ctx context.Context, resources []string, ttl string, expire string) ([]*Lock, error) {
+	locks := make([]*Lock, len(resources))
+
+	err := sdk.forEach(ctx, len(resources), func(i int) error {
+		lock, _, err := sdk.Acquire(ctx, resources[i], ttl, expire)
+		if err != nil {
+			return fmt.Errorf("resource '%s': %w", resources[i], err)
+		}
+		locks[i] = lock
+		return nil
+	})
+	if err == nil {
+		return locks, nil
+	}
+
+	// Roll back the partial acquisition
+	acquired := make([]*Lock, 0, len(locks))
+	for _, lock := range locks {
+		if lock != nil {
+			acquired = append(acquired, lock)
+		}
+	}
+	if releaseErr := sdk.ReleaseAll(ctx, acquired); releaseErr != nil {
+		err = errors.Join(err, releaseErr)
+	}
+
+	return nil, err
+}
+
+// RefreshAll extends the TTL of every lock, returning the aggregated errors of the ones that failed
+func (sdk *LockClient) RefreshAll(ctx context.Context, locks []*Lock, ttl string) error {
+	return sdk.forEach(ctx, len(locks), func(i int) error {
+		if err := sdk.Refresh(ctx, locks[i], ttl); err != nil {
+			return fmt.Errorf("resource '%s': %w", locks[i].Resource, err)
+		}
+		return nil
+	})
+}
+
+// ReleaseAll releases every lock, returning the aggregated errors of the ones that failed
+func (sdk *LockClient) ReleaseAll(ctx context.Context, locks []*Lock) error {
+	return sdk.forEach(ctx, len(locks), func(i int) error {
+		if err := sdk.Release(ctx, locks[i]); err != nil {
+			return fmt.Errorf("resource '%s': %w", locks[i].Resource, err)
+		}
+		return nil
+	})
+}
+
+// forEach runs fn for indexes [0, n) with at most sdk.concurrency calls in flight and joins their errors
+func (sdk *LockClient) forEach(ctx context.Context, n int, fn func(i int) error) error {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	errs := make([]error, 0)
+	sem := make(chan struct{}, sdk.concurrency)
+
+	for i := 0; i < n; i++ {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			mu.Lock()
+			errs = append(errs, ctx.Err())
+			mu.Unlock()
+			wg.Wait()
+			return errors.Join(errs...)
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := fn(i); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	return errors.Join(errs...)
+}
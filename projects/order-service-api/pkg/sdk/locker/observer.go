@@ -0,0 +1,55 @@
+package locker
+
+import (
+	"context"
+	"time"
+)
+
+// Observer is notified of the lock operations of a LockClient, e.g. to feed a metrics backend or logs.
+// Its methods are called synchronously, from the goroutine running the operation, and must be quick.
+// Embed NoopObserver to implement only some of them.
+type Observer interface {
+	// OnAttempt is called before each acquire request, attempt starting at 1
+	OnAttempt(ctx context.Context, resource string, attempt int)
+	// OnAcquired is called once the lock is granted, elapsed covering every attempt and backoff
+	OnAcquired(ctx context.Context, lock *Lock, elapsed time.Duration)
+	// OnConflict is called when an attempt finds the resource held by another client
+	OnConflict(ctx context.Context, resource string, attempt int)
+	// OnError is called when an operation ("acquire", "release" or "refresh") finally fails, with
+	// operation "watch" each time a Watch stream drops or carries an invalid event, and once from
+	// NewLockClient with operation "configure" and an empty resource when an option had to be corrected
+	OnError(ctx context.Context, resource string, operation string, err error)
+	// OnReleased is called once the lock is released
+	OnReleased(ctx context.Context, lock *Lock)
+	// OnStarved is called when an acquisition waited past the starvation threshold, typical being the
+	// median wait of the resource
+	OnStarved(ctx context.Context, resource string, wait, typical time.Duration)
+}
+
+// NoopObserver ignores every event, the default Observer
+type NoopObserver struct{}
+
+func (NoopObserver) OnAttempt(ctx context.Context, resource string, attempt int)                 {}
+func (NoopObserver) OnAcquired(ctx context.Context, lock *Lock, elapsed time.Duration)           {}
+func (NoopObserver) OnConflict(ctx context.Context, resource string, attempt int)                {}
+func (NoopObserver) OnError(ctx context.Context, resource string, operation string, err error)   {}
+func (NoopObserver) OnReleased(ctx context.Context, lock *Lock)                                  {}
+func (NoopObserver) OnStarved(ctx context.Context, resource string, wait, typical time.Duration) {}
+
+// WithObserver sets the Observer notified of Acquire, Release and Refresh
+func WithObserver(observer Observer) Option {
+	return func(sdk *LockClient) {
+		if observer != nil {
+			sdk.observer = observer
+		}
+	}
+}
+
+// observeAcquire reports the outcome of an acquisition started at startTime
+func (sdk *LockClient) observeAcquire(ctx context.Context, resource string, lock *Lock, err error, startTime time.Time) {
+	if err != nil {
+		sdk.observer.OnError(ctx, resource, "acquire", err)
+		return
+	}
+	sdk.observer.OnAcquired(ctx, lock, time.Since(startTime))
+}
@@ -0,0 +1,33 @@
+package locker
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// RenewOrReacquire keeps a long-lived lock, such as a leader lock, alive. It refreshes the lock and, if
+// it was lost (expired or taken over), makes one fresh acquire attempt for the same resource.
+//
+// retained is true when the refresh succeeded, so the lock was held without interruption. When it is
+// false and err is nil the lock was re-won: lock now carries the new token and fence and there may have been a
+// gap during which another client held the resource. ErrLockConflict means someone else holds it now, and
+// ErrCircuitOpen that the breaker kept the acquire attempt from being made.
+func (sdk *LockClient) RenewOrReacquire(ctx context.Context, lock *Lock, ttl time.Duration) (retained bool, err error) {
+	err = sdk.RefreshDuration(ctx, lock, ttl)
+	if err == nil {
+		return true, nil
+	}
+	if !errors.Is(err, ErrReleaseNotFound) {
+		return false, err
+	}
+
+	// Through the public path, so the attempt is traced, observed and subject to the circuit breaker
+	reacquired, _, err := sdk.TryAcquire(ctx, lock.Resource, ttl)
+	if err != nil {
+		return false, err
+	}
+
+	*lock = *reacquired
+	return false, nil
+}
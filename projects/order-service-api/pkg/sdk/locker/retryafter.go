@@ -0,0 +1,56 @@
+package locker
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// retryAfterError carries how long the server asked the client to wait before trying again
+type retryAfterError struct {
+	err   error
+	after time.Duration
+}
+
+func (e *retryAfterError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryAfterError) Unwrap() error {
+	return e.err
+}
+
+// withRetryAfter attaches the server's retry hint to err, left as is without a hint
+func withRetryAfter(err error, after time.Duration) error {
+	if after <= 0 {
+		return err
+	}
+	return &retryAfterError{err: err, after: after}
+}
+
+// retryAfter reads how long the server asked to wait before retrying, 0 if it didn't. The retry_after_ms
+// of a conflict body is preferred over the Retry-After header, which is rounded up to whole seconds.
+func retryAfter(resp *http.Response) time.Duration {
+	if resp.StatusCode == http.StatusConflict {
+		var res struct {
+			RetryAfterMs int64 `json:"retry_after_ms"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&res); err == nil && res.RetryAfterMs > 0 {
+			return time.Duration(res.RetryAfterMs) * time.Millisecond
+		}
+	}
+
+	value := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
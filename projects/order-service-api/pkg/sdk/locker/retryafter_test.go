@@ -0,0 +1,105 @@
+package locker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		header string
+		body   string
+		want   time.Duration
+	}{
+		{name: "none", status: http.StatusTooManyRequests},
+		{name: "seconds", status: http.StatusTooManyRequests, header: "3", want: 3 * time.Second},
+		{name: "invalid", status: http.StatusTooManyRequests, header: "soon"},
+		{name: "conflict body", status: http.StatusConflict, header: "1", body: `{"retry_after_ms":250}`, want: 250 * time.Millisecond},
+		{name: "conflict without a hint in the body", status: http.StatusConflict, header: "2", body: `{}`, want: 2 * time.Second},
+		{name: "body of a 429", status: http.StatusTooManyRequests, body: `{"retry_after_ms":250}`},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{StatusCode: tt.status, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(tt.body))}
+		if tt.header != "" {
+			resp.Header.Set("Retry-After", tt.header)
+		}
+		if got := retryAfter(resp); got != tt.want {
+			t.Errorf("%s: retryAfter() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRetryAfterAtAnHTTPDate(t *testing.T) {
+	resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}, Body: http.NoBody}
+	resp.Header.Set("Retry-After", time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+
+	if got := retryAfter(resp); got <= 58*time.Second || got > time.Minute {
+		t.Errorf("retryAfter() = %s, want about a minute", got)
+	}
+}
+
+func TestWithRetryAfterKeepsTheError(t *testing.T) {
+	err := withRetryAfter(ErrRateLimited, time.Second)
+	if !errors.Is(err, ErrRateLimited) {
+		t.Errorf("error = %v, want it to match ErrRateLimited", err)
+	}
+	if withRetryAfter(ErrRateLimited, 0) != ErrRateLimited {
+		t.Error("an error without a hint was wrapped")
+	}
+}
+
+func TestAcquireWaitsAsLongAsTheServerAsks(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusConflict)
+			fmt.Fprint(w, `{"retry_after_ms":200}`)
+			return
+		}
+		fmt.Fprint(w, `{"token":"t-1","ttl":"1s","ttl_ms":1000}`)
+	}))
+	defer server.Close()
+
+	sdk := NewLockClient(server.URL, WithExponentialBackoff(&ExponentialBackoff{Initial: time.Millisecond, Max: time.Millisecond}))
+
+	start := time.Now()
+	lock, _, err := sdk.AcquireDuration(context.Background(), "item-1", time.Second, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	if lock.Token != "t-1" {
+		t.Errorf("Token = %q, want t-1", lock.Token)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("retried after %s, want the 200ms the server asked for", elapsed)
+	}
+}
+
+func TestAcquireWaitsNoLongerThanTheExpireWindow(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	sdk := NewLockClient(server.URL, WithExponentialBackoff(&ExponentialBackoff{Initial: time.Millisecond, Max: time.Millisecond}))
+
+	start := time.Now()
+	_, _, err := sdk.AcquireDuration(context.Background(), "item-1", time.Second, 100*time.Millisecond)
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Acquire error = %v, want ErrTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Acquire returned after %s, want it within the expire window", elapsed)
+	}
+}
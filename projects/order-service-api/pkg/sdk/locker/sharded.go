@@ -0,0 +1,110 @@
+package locker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultVirtualNodes is the number of points each cluster places on the hash ring, evening out
+// the share of resources each one receives
+const defaultVirtualNodes = 128
+
+// ShardedClient spreads resources across independent lock-manager clusters, each one with its own
+// Redis quorum, through consistent hashing. A resource always routes to the same cluster for a given
+// set of clusters, so its mutual exclusion is still decided by a single quorum.
+type ShardedClient struct {
+	clients map[string]*LockClient
+	ring    []ringPoint
+}
+
+type ringPoint struct {
+	hash    uint32
+	baseURL string
+}
+
+// NewShardedClient creates one LockClient per cluster base URL, all sharing the given options
+func NewShardedClient(baseURLs []string, opts ...Option) (*ShardedClient, error) {
+	if len(baseURLs) == 0 {
+		return nil, errors.New("at least one cluster base URL is required")
+	}
+
+	sharded := &ShardedClient{
+		clients: make(map[string]*LockClient, len(baseURLs)),
+		ring:    make([]ringPoint, 0, len(baseURLs)*defaultVirtualNodes),
+	}
+
+	for _, baseURL := range baseURLs {
+		baseURL = strings.TrimRight(baseURL, "/")
+		if baseURL == "" {
+			return nil, errors.New("cluster base URL must not be empty")
+		}
+		if _, exists := sharded.clients[baseURL]; exists {
+			return nil, fmt.Errorf("duplicate cluster base URL '%s'", baseURL)
+		}
+
+		sharded.clients[baseURL] = NewLockClient(baseURL, opts...)
+		for i := 0; i < defaultVirtualNodes; i++ {
+			sharded.ring = append(sharded.ring, ringPoint{
+				hash:    crc32.ChecksumIEEE([]byte(baseURL + "#" + strconv.Itoa(i))),
+				baseURL: baseURL,
+			})
+		}
+	}
+
+	sort.Slice(sharded.ring, func(i, j int) bool {
+		if sharded.ring[i].hash == sharded.ring[j].hash {
+			return sharded.ring[i].baseURL < sharded.ring[j].baseURL
+		}
+		return sharded.ring[i].hash < sharded.ring[j].hash
+	})
+
+	return sharded, nil
+}
+
+// ClusterFor returns the base URL of the cluster owning resource
+func (s *ShardedClient) ClusterFor(resource string) string {
+	hash := crc32.ChecksumIEEE([]byte(resource))
+	i := sort.Search(len(s.ring), func(i int) bool {
+		return s.ring[i].hash >= hash
+	})
+	if i == len(s.ring) {
+		i = 0
+	}
+	return s.ring[i].baseURL
+}
+
+// ClientFor returns the client of the cluster owning resource
+func (s *ShardedClient) ClientFor(resource string) *LockClient {
+	return s.clients[s.ClusterFor(resource)]
+}
+
+// Acquire acquires the lock on the cluster owning resource, see LockClient.Acquire
+func (s *ShardedClient) Acquire(ctx context.Context, resource string, ttl string, expire string) (*Lock, func() error, error) {
+	return s.ClientFor(resource).Acquire(ctx, resource, ttl, expire)
+}
+
+// AcquireDuration acquires the lock on the cluster owning resource, see LockClient.AcquireDuration
+func (s *ShardedClient) AcquireDuration(ctx context.Context, resource string, ttl time.Duration, expire time.Duration) (*Lock, func() error, error) {
+	return s.ClientFor(resource).AcquireDuration(ctx, resource, ttl, expire)
+}
+
+// Release releases the lock on the cluster owning its resource
+func (s *ShardedClient) Release(ctx context.Context, lock *Lock) error {
+	return s.ClientFor(lock.Resource).Release(ctx, lock)
+}
+
+// Refresh extends the lock on the cluster owning its resource
+func (s *ShardedClient) Refresh(ctx context.Context, lock *Lock, ttl string) error {
+	return s.ClientFor(lock.Resource).Refresh(ctx, lock, ttl)
+}
+
+// RefreshDuration extends the lock on the cluster owning its resource
+func (s *ShardedClient) RefreshDuration(ctx context.Context, lock *Lock, ttl time.Duration) error {
+	return s.ClientFor(lock.Resource).RefreshDuration(ctx, lock, ttl)
+}
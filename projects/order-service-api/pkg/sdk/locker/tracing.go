@@ -0,0 +1,47 @@
+package locker
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+)
+
+// tracerName identifies the spans created by the SDK
+const tracerName = "github.com/Waelson/lock-manager-service/order-service-api/pkg/sdk/locker"
+
+// propagator carries the trace context to the lock service in the W3C traceparent header
+var propagator = propagation.TraceContext{}
+
+// WithTracerProvider sets the OpenTelemetry provider of the spans around Acquire, Release and Refresh.
+// By default the global provider is used, which creates no spans until one is registered.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(sdk *LockClient) {
+		if provider != nil {
+			sdk.tracer = provider.Tracer(tracerName)
+		}
+	}
+}
+
+// startSpan starts a client span for an operation on resource
+func (sdk *LockClient) startSpan(ctx context.Context, name string, resource string) (context.Context, trace.Span) {
+	return sdk.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("lock.resource", resource)))
+}
+
+// endSpan records err, if any, and ends span
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// injectTrace propagates the span in ctx to the lock service through the request headers
+func injectTrace(ctx context.Context, req *http.Request) {
+	propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+}
@@ -0,0 +1,137 @@
+package locker
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Lock state changes delivered by Watch
+const (
+	EventAcquired = "acquired"
+	EventReleased = "released"
+	EventExpired  = "expired"
+)
+
+// LockEvent is a lock state change of a resource, as sent by the server events stream
+type LockEvent struct {
+	Resource string `json:"resource"`
+	Event    string `json:"event"`
+}
+
+// Watch subscribes to the lock state changes of a resource through the server events stream (SSE).
+// The stream is reopened with exponential backoff when it drops, and the returned channel is
+// closed once ctx is cancelled.
+func (sdk *LockClient) Watch(ctx context.Context, resource string) (<-chan LockEvent, error) {
+	if resource == "" {
+		return nil, fmt.Errorf("resource must not be empty")
+	}
+
+	// Streams are long-lived, so they can't share the per-request timeout of the default client
+	streamClient := *sdk.httpClient
+	streamClient.Timeout = 0
+
+	resp, err := sdk.openEventStream(ctx, &streamClient, resource)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan LockEvent)
+	go func() {
+		defer close(events)
+
+		backoff := sdk.backoffConfig.Initial
+		for {
+			if sdk.readEventStream(ctx, resp, resource, events) {
+				// Events flowed, so the next drop starts from the initial backoff again
+				backoff = sdk.backoffConfig.Initial
+			}
+			if ctx.Err() != nil {
+				return
+			}
+
+			err = errors.New("events stream dropped")
+			for {
+				sdk.observer.OnError(ctx, resource, "watch", fmt.Errorf("%w, reconnecting", err))
+				backoff = sdk.calculateBackoff(backoff)
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(backoff):
+				}
+
+				resp, err = sdk.openEventStream(ctx, &streamClient, resource)
+				if err == nil {
+					break
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// openEventStream connects to the events endpoint filtered to a resource
+func (sdk *LockClient) openEventStream(ctx context.Context, client *http.Client, resource string) (*http.Response, error) {
+	url := fmt.Sprintf("%s/events", sdk.baseURL)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	query := req.URL.Query()
+	query.Add("resource", resource)
+	req.URL.RawQuery = query.Encode()
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, requestError(ctx, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to open events stream: HTTP %d", resp.StatusCode)
+	}
+
+	return resp, nil
+}
+
+// readEventStream forwards the events of an open stream until it ends, reporting whether any event was read
+func (sdk *LockClient) readEventStream(ctx context.Context, resp *http.Response, resource string, events chan<- LockEvent) bool {
+	defer resp.Body.Close()
+
+	received := false
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue // Comments, event names and frame separators
+		}
+
+		var event LockEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &event); err != nil {
+			sdk.observer.OnError(ctx, resource, "watch", fmt.Errorf("invalid event: %w", err))
+			continue
+		}
+		if event.Resource != resource {
+			continue
+		}
+
+		select {
+		case events <- event:
+			received = true
+		case <-ctx.Done():
+			return received
+		}
+	}
+
+	return received
+}
@@ -0,0 +1,115 @@
+package locker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWatchDeliversTheEventsOfTheResourceAcrossReconnections(t *testing.T) {
+	var connections atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("resource") != "item-1" {
+			t.Errorf("watching %q, want item-1", r.URL.Query().Get("resource"))
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+
+		// The first stream drops after a few frames, the second stays open
+		if connections.Add(1) == 1 {
+			fmt.Fprint(w, ": keepalive\n\n")
+			fmt.Fprint(w, "data: {\"resource\":\"item-2\",\"event\":\"acquired\"}\n\n")
+			fmt.Fprint(w, "data: not json\n\n")
+			fmt.Fprint(w, "data: {\"resource\":\"item-1\",\"event\":\"acquired\"}\n\n")
+			return
+		}
+		fmt.Fprint(w, "data: {\"resource\":\"item-1\",\"event\":\"released\"}\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	observer := &recordingObserver{}
+	sdk := NewLockClient(server.URL, WithObserver(observer),
+		WithExponentialBackoff(&ExponentialBackoff{Initial: time.Millisecond, Max: 10 * time.Millisecond}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := sdk.Watch(ctx, "item-1")
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+
+	for _, want := range []string{EventAcquired, EventReleased} {
+		select {
+		case event := <-events:
+			if event != (LockEvent{Resource: "item-1", Event: want}) {
+				t.Errorf("event = %+v, want item-1 %s", event, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no %s event received", want)
+		}
+	}
+
+	// The invalid frame and the dropped stream were both reported
+	if errs := observer.observed(); len(errs) != 2 {
+		t.Errorf("errors observed = %v, want the invalid event and the drop", errs)
+	}
+
+	cancel()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Error("an event was received after the watch was cancelled")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("the events channel wasn't closed once the watch was cancelled")
+	}
+}
+
+func TestWatchFailsWhenTheStreamCannotBeOpened(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	sdk := NewLockClient(server.URL)
+	if _, err := sdk.Watch(context.Background(), "item-1"); err == nil {
+		t.Error("Watch succeeded without an events stream")
+	}
+	if _, err := sdk.Watch(context.Background(), ""); err == nil {
+		t.Error("Watch succeeded without a resource")
+	}
+}
+
+func TestWatchOutlivesTheRequestTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.(http.Flusher).Flush()
+		time.Sleep(100 * time.Millisecond)
+		fmt.Fprint(w, "data: {\"resource\":\"item-1\",\"event\":\"expired\"}\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	sdk := NewLockClient(server.URL, WithTimeout(20*time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := sdk.Watch(ctx, "item-1")
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+
+	select {
+	case event := <-events:
+		if event.Event != EventExpired {
+			t.Errorf("event = %+v, want expired", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received past the request timeout")
+	}
+}